@@ -4,18 +4,20 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"time"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/ybonda/gcp-auditor/internal/config"
 	"github.com/ybonda/gcp-auditor/internal/domain"
-	"github.com/ybonda/gcp-auditor/internal/report"
+	"github.com/ybonda/gcp-auditor/internal/progress"
 	"github.com/ybonda/gcp-auditor/internal/repository/gcp"
 	"github.com/ybonda/gcp-auditor/internal/service"
 	"github.com/ybonda/gcp-auditor/pkg/logging"
 )
 
-var logger *logging.Logger
+// logger is the logger of the running command, created by setupLogger
+var logger = logging.Discard()
 
 // auditCmd represents the audit command
 var auditCmd = &cobra.Command{
@@ -24,15 +26,47 @@ var auditCmd = &cobra.Command{
 	Long: `Performs a comprehensive audit of GCP services across all accessible projects.
 
 Examples:
-  # Run audit with default settings (generates both markdown and JSON)
+  # Run audit with default settings (generates every report format)
   gcp-auditor audit
 
   # Run audit for the last 60 days
   gcp-auditor audit --days 60
 
-  # Run audit with specific format
+  # Run audit with specific formats
+  gcp-auditor audit --format markdown,json
   gcp-auditor audit --format markdown
   gcp-auditor audit --format json
+  gcp-auditor audit --format csv
+  gcp-auditor audit --format html
+  gcp-auditor audit --format xlsx
+  gcp-auditor audit --format ndjson
+  gcp-auditor audit --format openmetrics
+
+  # Write OpenMetrics results for the node_exporter textfile collector
+  gcp-auditor audit --format openmetrics --metrics-textfile /var/lib/node_exporter/textfile/gcp_auditor.prom
+
+  # Roll up projects per team label (or per parent folder with --group-by folder)
+  gcp-auditor audit --group-by label:team
+
+  # Email every project owner a digest of their findings (see "digest" in the config file)
+  gcp-auditor audit --digest
+
+  # Write the digests as .eml files to the report directory instead of sending them
+  gcp-auditor audit --digest --dry-run
+
+  # Only audit production projects, at most 20 API requests per second
+  gcp-auditor audit --include-project 'prod-*' --label env:prod --rate-limit 20
+
+  # Scan 10 projects at a time and adapt the API requests in flight to quota errors
+  gcp-auditor audit --concurrency 10 --adaptive --max-in-flight 100
+
+  # Impersonate a service account through a delegation chain, billing Monitoring quota to another project
+  gcp-auditor audit --impersonate-service-account delegate@ops.iam.gserviceaccount.com,auditor@ops.iam.gserviceaccount.com \
+    --quota-project ops-monitoring
+
+  # Record the API responses of a run, then reproduce the run offline
+  gcp-auditor audit --record fixtures/
+  gcp-auditor audit --replay fixtures/
 
   # Run audit with verbose output
   gcp-auditor audit --verbose`,
@@ -41,74 +75,140 @@ Examples:
 
 func init() {
 	rootCmd.AddCommand(auditCmd)
-	auditCmd.Flags().Bool("verbose", false, "Enable verbose output")
-	auditCmd.Flags().String("format", "", "Report format (markdown, json, all)")
+	addAuditFlags(auditCmd)
+	auditCmd.Flags().Bool("digest", false, "Email each project owner a digest of their findings")
+	auditCmd.Flags().Bool("dry-run", false, "Write digest emails as .eml files instead of sending them")
+	addReplayFlags(auditCmd)
+}
+
+// addAuditFlags defines the flags of the settings that control an audit
+func addAuditFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	addLogFlags(cmd)
+	config.AddFlag(flags, "log.progress", "progress")
+	config.AddFlag(flags, "output.formats", "format")
+	config.AddFlag(flags, "output.metrics_textfile", "metrics-textfile")
+	config.AddFlag(flags, "output.stream", "stream")
+	config.AddFlag(flags, "audit.group_by", "group-by")
+	config.AddFlag(flags, "audit.concurrency", "concurrency")
+	config.AddFlag(flags, "audit.worker_count", "worker-count")
+	config.AddFlag(flags, "audit.adaptive", "adaptive")
+	config.AddFlag(flags, "audit.max_in_flight", "max-in-flight")
+	config.AddFlag(flags, "audit.timeout", "timeout")
+	config.AddFlag(flags, "audit.usage_timeout", "usage-timeout")
+	config.AddFlag(flags, "filters.include_projects", "include-project")
+	config.AddFlag(flags, "filters.exclude_projects", "exclude-project")
+	config.AddFlag(flags, "filters.labels", "label")
+	config.AddFlag(flags, "rate_limits.requests_per_second", "rate-limit")
+	config.AddFlag(flags, "rate_limits.burst", "rate-limit-burst")
+	addAuthFlags(cmd)
+	addTracingFlags(cmd)
+}
+
+// addLogFlags defines the flags that control the log output
+func addLogFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	config.AddFlag(flags, "log.verbose", "verbose")
+	config.AddFlag(flags, "log.level", "log-level")
+	config.AddFlag(flags, "log.format", "log-format")
+	config.AddFlag(flags, "log.file", "log-file")
+}
+
+// addTracingFlags defines the flags that export the spans of audits
+func addTracingFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	config.AddFlag(flags, "tracing.exporter", "trace-exporter")
+	config.AddFlag(flags, "tracing.endpoint", "trace-endpoint")
+	config.AddFlag(flags, "tracing.insecure", "trace-insecure")
+	config.AddFlag(flags, "tracing.file", "trace-file")
+}
+
+// addAuthFlags defines the flags that select the credentials of the GCP API clients
+func addAuthFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	config.AddFlag(flags, "auth.credentials_file", "credentials-file")
+	config.AddFlag(flags, "auth.impersonate_service_account", "impersonate-service-account")
+	config.AddFlag(flags, "auth.quota_project", "quota-project")
 }
 
 func runAudit(cmd *cobra.Command, args []string) error {
-	verbose, _ := cmd.Flags().GetBool("verbose")
-	format, _ := cmd.Flags().GetString("format")
-
-	// Create configuration with default values
-	cfg := config.NewConfig(
-		config.WithOutputDir(outputDir),
-		config.WithDays(daysToAudit),
-		config.WithVerbose(verbose),
-		config.WithConcurrency(3),
-	)
+	sendDigest, _ := cmd.Flags().GetBool("digest")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
 
-	// Only override format if explicitly specified
-	if format != "" {
-		// Validate format
-		switch format {
-		case "markdown", "json", "all":
-			cfg = config.NewConfig(
-				config.WithOutputDir(outputDir),
-				config.WithDays(daysToAudit),
-				config.WithVerbose(verbose),
-				config.WithConcurrency(3),
-				config.WithFormat(format),
-			)
-		default:
-			return fmt.Errorf("invalid format %q. Must be one of: markdown, json, all", format)
-		}
+	if dryRun && !sendDigest {
+		return fmt.Errorf("--dry-run requires --digest")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
 	}
 
 	// Ensure output directory exists
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	logger = logging.NewLogger(cfg.Verbose)
-	logger.Debug("Configured audit period: %d days (%s)", daysToAudit, cfg.Period)
-	logger.Debug("Using report format: %s", cfg.Format)
+	closeLog, err := setupLogger(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	logger.Debug("Configured audit", "days", cfg.DaysToAudit, "period", cfg.Period, "formats", strings.Join(cfg.Formats, ","))
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.AuditTimeout)
 	defer cancel()
 
-	// Initialize GCP client
-	gcpClient, err := gcp.NewClient(ctx)
+	shutdownTracing, err := setupTracing(ctx, cfg)
 	if err != nil {
-		logger.Error("Failed to initialize GCP client: %v", err)
 		return err
 	}
-	defer gcpClient.Close()
+	defer shutdownTracing()
 
-	// Initialize repositories
-	projectRepo := gcp.NewProjectRepository(gcpClient.ResourceManager, cfg.Verbose)
-	serviceRepo := gcp.NewServiceRepository(gcpClient.ServiceUsage, gcpClient.Monitoring, cfg.Verbose)
+	// Record or replay the API calls when asked to
+	cassetteOpts, closeCassette, err := openCassette(cmd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := closeCassette(); err != nil {
+			logger.Error("Failed to close fixtures", "error", err)
+		}
+	}()
+
+	// Show a live progress display instead of progress lines on terminals
+	var display *progress.Display
+	var serviceOpts []gcp.ServiceOption
+	auditOpts := []service.Option{service.WithLogger(logger)}
+	if viper.GetBool("log.progress") && progress.IsTerminal(os.Stderr) {
+		display = progress.NewDisplay(os.Stderr)
+		serviceOpts = append(serviceOpts, gcp.WithCallObserver(display.APICall))
+		auditOpts = append(auditOpts, service.WithProgress(display))
+	}
+
+	// Initialize GCP clients and repositories
+	projectRepo, serviceRepo, closeClients, err := newRepositories(ctx, cfg, serviceOpts, cassetteOpts...)
+	if err != nil {
+		logger.Error("Failed to initialize GCP client", "error", err)
+		return err
+	}
+	defer closeClients()
 
 	// Initialize reporters based on format
-	var reporters []domain.Reporter
-	switch cfg.Format {
-	case "markdown":
-		reporters = append(reporters, report.NewMarkdownReporter(outputDir))
-	case "json":
-		reporters = append(reporters, report.NewJSONReporter(outputDir))
-	case "all":
-		reporters = append(reporters, report.NewMarkdownReporter(outputDir))
-		reporters = append(reporters, report.NewJSONReporter(outputDir))
+	reporters := newReporters(cfg.Formats, cfg.OutputDir, cfg.MetricsTextfile)
+
+	// Initialize notifiers from the config file
+	notifiers, err := loadNotifiers()
+	if err != nil {
+		return err
+	}
+	if sendDigest {
+		digestNotifier, err := loadDigestNotifier(cfg.OutputDir, dryRun)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, digestNotifier)
 	}
 
 	// Create audit service with unified config
@@ -116,35 +216,135 @@ func runAudit(cmd *cobra.Command, args []string) error {
 		projectRepo,
 		serviceRepo,
 		reporters,
+		notifiers,
 		cfg,
+		auditOpts...,
 	)
+	if len(notifiers) > 0 {
+		loadBaseline(auditService, cfg.OutputDir, cfg.Profile)
+	}
 
-	// Run audit
+	// Run audit; log records are printed above the progress display while it is shown
+	if display != nil {
+		logging.SetOutput(display.Writer(os.Stderr))
+		display.Start()
+	}
 	auditReport, err := auditService.Audit(ctx)
+	if display != nil {
+		display.Stop()
+		logging.SetOutput(nil)
+	}
 	if err != nil {
-		logger.Error("Audit failed: %v", err)
+		logger.Error("Audit failed", "error", err)
 		return err
 	}
 
-	printAuditSummary(auditReport)
+	printAuditSummary(auditReport, cfg.OutputDir)
+	return nil
+}
+
+// newRepositories creates the GCP clients and repositories configured for the audit. With
+// organizations configured, every organization is listed with its own credentials. The
+// service and client options are applied to every service repository and client; the
+// returned function closes the clients.
+func newRepositories(ctx context.Context, cfg *config.Config, serviceOpts []gcp.ServiceOption, clientOpts ...gcp.ClientOption) (domain.ProjectRepository, domain.ServiceRepository, func(), error) {
+	limitOpts := append([]gcp.ServiceOption{
+		gcp.WithRateLimit(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst),
+	}, serviceOpts...)
+	if cfg.Adaptive.Enabled {
+		limitOpts = append(limitOpts, gcp.WithAdaptiveConcurrency(cfg.Adaptive.MaxInFlight))
+	}
+
+	if len(cfg.Organizations) == 0 {
+		gcpClient, err := gcp.NewClient(ctx, append(clientOptions(cfg.Credentials), clientOpts...)...)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		projectRepo := gcp.NewProjectRepository(gcpClient.ResourceManager, logger, projectOptions(cfg, gcpClient)...)
+		serviceRepo := newServiceRepository(gcpClient, cfg, limitOpts...)
+		return projectRepo, serviceRepo, func() { gcpClient.Close() }, nil
+	}
+
+	var clients []*gcp.Client
+	closeClients := func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}
+
+	var sources []gcp.OrganizationSource
+	for _, org := range cfg.Organizations {
+		gcpClient, err := gcp.NewClient(ctx, append(clientOptions(org.Credentials), clientOpts...)...)
+		if err != nil {
+			closeClients()
+			return nil, nil, nil, fmt.Errorf("organization %s: %w", org.ID, err)
+		}
+		clients = append(clients, gcpClient)
+
+		// All organizations share the rate and in-flight limits of the first one
+		opts := limitOpts
+		if len(sources) > 0 {
+			opts = append([]gcp.ServiceOption{gcp.WithSharedLimits(sources[0].Services)}, serviceOpts...)
+		}
+		sources = append(sources, gcp.OrganizationSource{
+			Organization: org.ID,
+			Projects: gcp.NewProjectRepository(gcpClient.ResourceManager, logger,
+				append(projectOptions(cfg, gcpClient), gcp.WithOrganization(org.ID, gcpClient.ResourceManagerV3))...),
+			Services: newServiceRepository(gcpClient, cfg, opts...),
+		})
+	}
+
+	multiRepo := gcp.NewMultiRepository(sources)
+	return multiRepo, multiRepo, closeClients, nil
+}
+
+// projectOptions returns the project repository options the configuration needs in every
+// organization
+func projectOptions(cfg *config.Config, gcpClient *gcp.Client) []gcp.ProjectOption {
+	if cfg.GroupBy.Folder {
+		return []gcp.ProjectOption{gcp.WithFolderNames(gcpClient.ResourceManagerV3)}
+	}
 	return nil
 }
 
-func printAuditSummary(report domain.AuditReport) {
-	logger.Info("\nAudit Summary")
-	logger.Info("-------------")
-	logger.Info("Projects analyzed: %d", report.Statistics.ValidProjects)
-	logger.Info("Excluded projects: %d", report.Statistics.ExcludedProjects)
-	logger.Info("Skipped projects: %d", report.Statistics.SkippedProjects)
-	logger.Info("Unique services found: %d", report.Statistics.UniqueServices)
-	logger.Info("Services with no usage: %d", report.Statistics.ServicesWithNoUsage)
+func newServiceRepository(gcpClient *gcp.Client, cfg *config.Config, opts ...gcp.ServiceOption) *gcp.ServiceRepository {
+	opts = append([]gcp.ServiceOption{
+		gcp.WithWorkerCount(cfg.WorkerCount),
+		gcp.WithUsageTimeout(cfg.UsageTimeout),
+	}, opts...)
+	return gcp.NewServiceRepository(gcpClient.ServiceUsage, gcpClient.Monitoring, logger, opts...)
+}
+
+// clientOptions converts configured credentials to GCP client options
+func clientOptions(credentials config.Credentials) []gcp.ClientOption {
+	var opts []gcp.ClientOption
+	if credentials.File != "" {
+		opts = append(opts, gcp.WithCredentialsFile(credentials.File))
+	}
+	if len(credentials.ImpersonateServiceAccount) > 0 {
+		opts = append(opts, gcp.WithImpersonation(credentials.ImpersonateServiceAccount))
+	}
+	if credentials.QuotaProject != "" {
+		opts = append(opts, gcp.WithQuotaProject(credentials.QuotaProject))
+	}
+	return opts
+}
+
+func printAuditSummary(report domain.AuditReport, outputDir string) {
+	fmt.Println("\nAudit Summary")
+	fmt.Println("-------------")
+	fmt.Printf("Projects analyzed: %d\n", report.Statistics.ValidProjects)
+	fmt.Printf("Excluded projects: %d\n", report.Statistics.ExcludedProjects)
+	fmt.Printf("Skipped projects: %d\n", report.Statistics.SkippedProjects)
+	fmt.Printf("Unique services found: %d\n", report.Statistics.UniqueServices)
+	fmt.Printf("Services with no usage: %d\n", report.Statistics.ServicesWithNoUsage)
 
 	if len(report.SkippedProjects) > 0 {
-		logger.Info("\nSkipped Projects:")
+		fmt.Println("\nSkipped Projects:")
 		for projectID, err := range report.SkippedProjects {
-			logger.Info("- %s: %v", projectID, err)
+			fmt.Printf("- %s: %v\n", projectID, err)
 		}
 	}
 
-	logger.Info("\nReport has been generated in: %s", outputDir)
+	fmt.Printf("\nReport has been generated in: %s\n", outputDir)
 }
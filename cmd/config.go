@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/ybonda/gcp-auditor/internal/config"
+	"github.com/ybonda/gcp-auditor/internal/tracing"
+	"github.com/ybonda/gcp-auditor/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// configCmd groups the commands that inspect the configuration
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and validate the configuration",
+	Long: `Every setting is resolved from, in order of precedence, a command-line flag, an
+environment variable (GCP_AUDITOR_<KEY> with dots replaced by underscores, e.g.
+GCP_AUDITOR_AUDIT_CONCURRENCY), the config file and the built-in default.
+
+The audit flags are accepted so that their effect on the configuration can be previewed.
+
+Examples:
+  # Show the effective configuration and where every value comes from
+  gcp-auditor config show
+
+  # Preview the effect of flags
+  gcp-auditor config show --concurrency 8 --format json,csv
+
+  # Check the config file for errors and unknown keys
+  gcp-auditor config validate --config ./gcp-auditor.yaml`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and the source of every value",
+	RunE:  runConfigShow,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:          "validate",
+	Short:        "Validate the configuration",
+	RunE:         runConfigValidate,
+	SilenceUsage: true,
+}
+
+// redactedKeys are the full keys of the secrets masked by config show. The items of a list
+// share the key of the list, and "*" stands for every key of a map.
+var redactedKeys = map[string]bool{
+	"notifications.channels.url":       true, // Slack webhook URLs embed a token
+	"notifications.channels.headers.*": true,
+	"digest.smtp.password":             true,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd, configValidateCmd)
+	addAuditFlags(configShowCmd)
+	addAuditFlags(configValidateCmd)
+}
+
+// setupLogger creates the logger of the command from the log settings. It is shared by the
+// audit service and the repositories; the returned function closes the log file.
+func setupLogger(cfg *config.Config) (func(), error) {
+	l, closeFile, err := logging.New(cfg.Log)
+	if err != nil {
+		return nil, err
+	}
+	logger = l
+	return func() { closeFile() }, nil
+}
+
+// setupTracing installs the configured span exporter. The returned function flushes the
+// pending spans.
+func setupTracing(ctx context.Context, cfg *config.Config) (func(), error) {
+	shutdown, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.Error("Failed to export spans", "error", err)
+		}
+	}, nil
+}
+
+// loadConfig resolves the audit configuration from the flags, the environment and the config file
+func loadConfig(opts ...config.Option) (*config.Config, error) {
+	cfg, err := config.Load(viper.GetViper(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateFormats(cfg.Formats); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	v := viper.GetViper()
+	out := cmd.OutOrStdout()
+
+	configFile := v.ConfigFileUsed()
+	if _, err := os.Stat(configFile); configFile == "" || err != nil {
+		configFile = "none"
+	}
+	fmt.Fprintf(out, "Config file: %s\n\n", configFile)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, setting := range config.Settings {
+		value := v.Get(setting.Key)
+		if _, isList := setting.Default.([]string); isList {
+			value = "[" + strings.Join(config.StringSlice(v, setting.Key), ", ") + "]"
+		}
+		if redactedKeys[setting.Key] && v.GetString(setting.Key) != "" {
+			value = "<redacted>"
+		}
+		fmt.Fprintf(w, "%s\t%v\t%s\n", setting.Key, value, config.Source(v, cmd.Flags(), setting.Key))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, section := range config.Sections {
+		if !v.IsSet(section) {
+			continue
+		}
+
+		var data strings.Builder
+		encoder := yaml.NewEncoder(&data)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(redact(section, v.Get(section))); err != nil {
+			return fmt.Errorf("failed to format %s: %w", section, err)
+		}
+		fmt.Fprintf(out, "\n%s (file):\n", section)
+		for _, line := range strings.Split(strings.TrimRight(data.String(), "\n"), "\n") {
+			fmt.Fprintf(out, "  %s\n", line)
+		}
+	}
+
+	return nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	var problems []string
+
+	if unknown := config.UnknownKeys(viper.GetViper()); len(unknown) > 0 {
+		sort.Strings(unknown)
+		problems = append(problems, "unknown keys in config file: "+strings.Join(unknown, ", "))
+	}
+	if _, err := loadConfig(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := validateFormats(config.StringSlice(viper.GetViper(), "serve.formats")); err != nil {
+		problems = append(problems, "serve.formats: "+err.Error())
+	}
+	if _, err := loadNotifiers(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if viper.IsSet("digest") {
+		if _, err := loadDigestNotifier("", false); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(cmd.ErrOrStderr(), "- %s\n", problem)
+		}
+		return fmt.Errorf("configuration is invalid")
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), "Configuration is valid")
+	return nil
+}
+
+// redact masks the secrets in the value of a structured config section at key
+func redact(key string, value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(typed))
+		for name, item := range typed {
+			itemKey := key + "." + strings.ToLower(name)
+			if isRedacted(itemKey) {
+				result[name] = "<redacted>"
+			} else {
+				result[name] = redact(itemKey, item)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(typed))
+		for i, item := range typed {
+			result[i] = redact(key, item)
+		}
+		return result
+	}
+	return value
+}
+
+// isRedacted reports whether the value at key is a secret. Profiles hold the same keys as the
+// top level of the config file.
+func isRedacted(key string) bool {
+	if rest, found := strings.CutPrefix(key, config.ProfilesKey+"."); found {
+		if _, profileKey, found := strings.Cut(rest, "."); found {
+			key = profileKey
+		}
+	}
+	parent := key[:max(strings.LastIndex(key, "."), 0)]
+	return redactedKeys[key] || redactedKeys[parent+".*"]
+}
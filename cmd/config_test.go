@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/ybonda/gcp-auditor/internal/config"
+)
+
+func TestRedact(t *testing.T) {
+	channels := []interface{}{
+		map[string]interface{}{
+			"type":         "slack",
+			"url":          "https://hooks.slack.com/services/T000/B000/XXXX",
+			"min_severity": "warning",
+		},
+		map[string]interface{}{
+			"type":    "webhook",
+			"url":     "https://example.com/hook",
+			"headers": map[string]interface{}{"Authorization": "Bearer secret", "X-Team": "finops"},
+		},
+	}
+	digest := map[string]interface{}{
+		"from": "auditor@example.com",
+		"smtp": map[string]interface{}{"host": "smtp.example.com", "username": "auditor", "password": "hunter2"},
+	}
+	profiles := map[string]interface{}{
+		"prod": map[string]interface{}{
+			"digest": map[string]interface{}{"smtp": map[string]interface{}{"password": "hunter2"}},
+			"output": map[string]interface{}{"dir": "prod-reports"},
+		},
+	}
+
+	tests := []struct {
+		section string
+		value   interface{}
+		want    interface{}
+	}{
+		{
+			section: "notifications.channels",
+			value:   channels,
+			want: []interface{}{
+				map[string]interface{}{"type": "slack", "url": "<redacted>", "min_severity": "warning"},
+				map[string]interface{}{
+					"type":    "webhook",
+					"url":     "<redacted>",
+					"headers": map[string]interface{}{"Authorization": "<redacted>", "X-Team": "<redacted>"},
+				},
+			},
+		},
+		{
+			section: "digest",
+			value:   digest,
+			want: map[string]interface{}{
+				"from": "auditor@example.com",
+				"smtp": map[string]interface{}{"host": "smtp.example.com", "username": "auditor", "password": "<redacted>"},
+			},
+		},
+		{
+			section: config.ProfilesKey,
+			value:   profiles,
+			want: map[string]interface{}{
+				"prod": map[string]interface{}{
+					"digest": map[string]interface{}{"smtp": map[string]interface{}{"password": "<redacted>"}},
+					"output": map[string]interface{}{"dir": "prod-reports"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.section, func(t *testing.T) {
+			if got := redact(tt.section, tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redact() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactedKeysOnlyMaskSecrets(t *testing.T) {
+	for _, key := range []string{"notifications.report_url", "output.dir", "auth.credentials_file", "tracing.endpoint"} {
+		if isRedacted(key) {
+			t.Errorf("%s is redacted, but it is not a secret", key)
+		}
+	}
+}
+
+// TestSettingsFlagsDocumented checks that the flags bound to every setting with AddFlag are
+// the ones listed in the settings table of docs/configuration.md
+func TestSettingsFlagsDocumented(t *testing.T) {
+	bound := make(map[string][]string)
+	addFlags := func(flags *pflag.FlagSet) {
+		flags.VisitAll(func(flag *pflag.Flag) {
+			if key, ok := config.FlagKey(flag); ok {
+				bound[key] = appendUnique(bound[key], "--"+flag.Name)
+			}
+		})
+	}
+	var visit func(cmd *cobra.Command)
+	visit = func(cmd *cobra.Command) {
+		addFlags(cmd.PersistentFlags())
+		addFlags(cmd.LocalNonPersistentFlags())
+		for _, child := range cmd.Commands() {
+			visit(child)
+		}
+	}
+	visit(rootCmd)
+
+	data, err := os.ReadFile("../docs/configuration.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := regexp.MustCompile("(?m)^\\| `([a-z_.]+)` \\| ([^|]*) \\|")
+	flagName := regexp.MustCompile(`--[a-z-]+`)
+	documented := make(map[string][]string)
+	for _, match := range row.FindAllStringSubmatch(string(data), -1) {
+		if _, ok := config.Lookup(match[1]); !ok {
+			continue
+		}
+		documented[match[1]] = []string{}
+		for _, name := range flagName.FindAllString(match[2], -1) {
+			documented[match[1]] = appendUnique(documented[match[1]], name)
+		}
+	}
+
+	for _, setting := range config.Settings {
+		got, want := bound[setting.Key], documented[setting.Key]
+		if want == nil {
+			t.Errorf("setting %s is not documented", setting.Key)
+			continue
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("setting %s is bound to flags %v, documented as %v", setting.Key, got, want)
+		}
+	}
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
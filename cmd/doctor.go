@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ybonda/gcp-auditor/internal/config"
+	"github.com/ybonda/gcp-auditor/internal/doctor"
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/repository/gcp"
+)
+
+// doctorCmd checks the permissions of the credentials before an audit
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check credentials, permissions and APIs before an audit",
+	Long: `Verifies in a few seconds what an audit would otherwise discover after minutes of crawling:
+
+  - the credentials can list projects
+  - serviceusage.services.list and monitoring.timeSeries.list are granted on a sample of the
+    projects that would be audited (using testIamPermissions)
+  - resourcemanager.projects.list and the permissions above are granted on the organization
+  - the Cloud Resource Manager, Service Usage and Monitoring APIs are enabled on the quota project,
+    set with --quota-project or taken from the credentials
+
+Every configured organization is checked with its own credentials. The command exits with
+status 1 when a check fails.
+
+Examples:
+  # Check the default credentials
+  gcp-auditor doctor
+
+  # Check an impersonated service account, its quota project and 10 production projects
+  gcp-auditor doctor --impersonate-service-account auditor@ops.iam.gserviceaccount.com \
+    --quota-project ops-monitoring --include-project 'prod-*' --sample 10`,
+	RunE:         runDoctor,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	flags := doctorCmd.Flags()
+	addLogFlags(doctorCmd)
+	config.AddFlag(flags, "audit.timeout", "timeout")
+	config.AddFlag(flags, "filters.include_projects", "include-project")
+	config.AddFlag(flags, "filters.exclude_projects", "exclude-project")
+	config.AddFlag(flags, "filters.labels", "label")
+	addAuthFlags(doctorCmd)
+	flags.Int("sample", 3, "Number of projects whose permissions are tested")
+	addReplayFlags(doctorCmd)
+}
+
+// doctorTarget is a set of credentials checked by doctor
+type doctorTarget struct {
+	name         string
+	organization string
+	credentials  config.Credentials
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	sampleSize, _ := cmd.Flags().GetInt("sample")
+	if sampleSize < 1 {
+		return fmt.Errorf("--sample must be at least 1")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	closeLog, err := setupLogger(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.AuditTimeout)
+	defer cancel()
+
+	targets := []doctorTarget{{name: describeCredentials(cfg.Credentials), credentials: cfg.Credentials}}
+	if len(cfg.Organizations) > 0 {
+		targets = targets[:0]
+		for _, org := range cfg.Organizations {
+			targets = append(targets, doctorTarget{
+				name:         "organizations/" + org.ID + " as " + describeCredentials(org.Credentials),
+				organization: org.ID,
+				credentials:  org.Credentials,
+			})
+		}
+	}
+
+	cassetteOpts, closeCassette, err := openCassette(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeCassette()
+
+	keep := func(project domain.Project) bool {
+		return !gcp.IsSystemProject(project.ID) && cfg.Filters.Matches(project)
+	}
+
+	var results []doctor.Result
+	for _, target := range targets {
+		logger.Debug("Checking credentials", "target", target.name)
+
+		gcpClient, err := gcp.NewClient(ctx, append(clientOptions(target.credentials), cassetteOpts...)...)
+		if err != nil {
+			results = append(results, doctor.Result{
+				Check: "credentials", Target: target.name, Status: doctor.StatusFail, Detail: err.Error(),
+				Hint: "Run gcloud auth application-default login, or check --credentials-file",
+			})
+			continue
+		}
+
+		results = append(results, doctor.Run(ctx, gcp.NewPermissionRepository(gcpClient), doctor.Options{
+			Credentials:  target.name,
+			Organization: target.organization,
+			QuotaProject: gcpClient.QuotaProject,
+			SampleSize:   sampleSize,
+			Keep:         keep,
+		})...)
+		gcpClient.Close()
+	}
+
+	if err := doctor.WriteTable(cmd.OutOrStdout(), results); err != nil {
+		return err
+	}
+	if failed := doctor.Failed(results); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// describeCredentials names the credentials in the doctor results
+func describeCredentials(credentials config.Credentials) string {
+	switch {
+	case len(credentials.ImpersonateServiceAccount) > 0:
+		return strings.Join(credentials.ImpersonateServiceAccount, " -> ")
+	case credentials.File != "":
+		return credentials.File
+	}
+	return "application default credentials"
+}
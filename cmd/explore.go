@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/ybonda/gcp-auditor/internal/explore"
+)
+
+// exploreCmd opens a terminal UI over the reports of a run
+var exploreCmd = &cobra.Command{
+	Use:   "explore [run-dir]",
+	Short: "Browse the results of an audit run in a terminal UI",
+	Long: `Opens a terminal UI over the reports of an audit run: the projects with their services,
+usage, status and errors, and the services with the projects that enable them. Lists can be
+filtered and sorted.
+
+Services can be marked for removal while browsing. The remediation plan is saved as JSON and
+as a script of gcloud commands, by default into the run directory, and loaded again the next
+time the run is explored.
+
+The run directory needs the NDJSON or JSON report; the NDJSON report adds usage errors and
+skipped projects. Without an argument, the latest run in the output directory is opened.
+
+Examples:
+  # Explore the latest run
+  gcp-auditor explore
+
+  # Explore a specific run and save the plan elsewhere
+  gcp-auditor explore reports/20240115_093000 --plan cleanup.json`,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runExplore,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(exploreCmd)
+	exploreCmd.Flags().String("plan", "", "File to save the remediation plan to (default <run-dir>/"+explore.PlanFileName+")")
+}
+
+func runExplore(cmd *cobra.Command, args []string) error {
+	var runDir string
+	if len(args) > 0 {
+		runDir = args[0]
+	} else {
+		latest, err := explore.LatestRun(viper.GetString("output.dir"))
+		if err != nil {
+			return err
+		}
+		runDir = latest
+	}
+
+	dataset, err := explore.Load(runDir)
+	if err != nil {
+		return fmt.Errorf("failed to load run %s: %w", runDir, err)
+	}
+
+	planPath, _ := cmd.Flags().GetString("plan")
+	if planPath == "" {
+		planPath = filepath.Join(runDir, explore.PlanFileName)
+	}
+	plan, err := explore.LoadPlan(planPath, dataset.RunID)
+	if err != nil {
+		return err
+	}
+
+	program := tea.NewProgram(explore.NewModel(dataset, plan, planPath), tea.WithAltScreen())
+	if _, err := program.Run(); err != nil {
+		return fmt.Errorf("failed to run explorer: %w", err)
+	}
+	return nil
+}
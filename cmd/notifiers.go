@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"github.com/ybonda/gcp-auditor/internal/digest"
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/notify"
+	"github.com/ybonda/gcp-auditor/internal/report"
+	"github.com/ybonda/gcp-auditor/internal/service"
+)
+
+// loadNotifiers creates the notifiers configured under "notifications.channels" in the config file
+func loadNotifiers() ([]domain.Notifier, error) {
+	var channels []notify.Channel
+	if err := viper.UnmarshalKey("notifications.channels", &channels); err != nil {
+		return nil, fmt.Errorf("invalid notifications configuration: %w", err)
+	}
+	return notify.NewNotifiers(channels)
+}
+
+// loadDigestNotifier creates the owner email digest configured under "digest" in the config file.
+// With dryRun set the digests are written as .eml files to the output directory.
+func loadDigestNotifier(outputDir string, dryRun bool) (domain.Notifier, error) {
+	var cfg digest.Config
+	if err := viper.UnmarshalKey("digest", &cfg); err != nil {
+		return nil, fmt.Errorf("invalid digest configuration: %w", err)
+	}
+
+	dryRunDir := ""
+	if dryRun {
+		dryRunDir = outputDir
+	}
+	return digest.NewNotifier(cfg, dryRunDir)
+}
+
+// loadBaseline compares the next audit against the latest JSON report of the profile in the
+// output directory, so notifications can list services that changed since then
+func loadBaseline(auditService *service.AuditService, outputDir, profile string) {
+	baseline, err := report.LoadBaseline(outputDir, profile)
+	if err != nil {
+		logger.Error("Failed to load previous report, change detection disabled", "error", err)
+		return
+	}
+	if baseline == nil {
+		logger.Debug("No previous JSON report found, change detection starts with this audit", "output_dir", outputDir, "profile", profile)
+		return
+	}
+	auditService.SetBaseline(baseline)
+}
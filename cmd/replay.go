@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ybonda/gcp-auditor/internal/replay"
+	"github.com/ybonda/gcp-auditor/internal/repository/gcp"
+)
+
+// addReplayFlags defines the flags that record the GCP API calls of a run, or replay them
+func addReplayFlags(cmd *cobra.Command) {
+	cmd.Flags().String("record", "", "Record the GCP API responses as fixtures into this directory")
+	cmd.Flags().String("replay", "", "Replay the GCP API responses recorded in this directory instead of calling GCP")
+	cmd.MarkFlagsMutuallyExclusive("record", "replay")
+}
+
+// openCassette opens the cassette selected with --record or --replay. Without either it
+// returns no client options and a no-op close function.
+func openCassette(cmd *cobra.Command) ([]gcp.ClientOption, func() error, error) {
+	recordDir, _ := cmd.Flags().GetString("record")
+	replayDir, _ := cmd.Flags().GetString("replay")
+
+	var cassette *replay.Cassette
+	var err error
+	switch {
+	case recordDir != "":
+		cassette, err = replay.Record(recordDir)
+	case replayDir != "":
+		cassette, err = replay.Replay(replayDir)
+	default:
+		return nil, func() error { return nil }, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open fixtures: %w", err)
+	}
+	return []gcp.ClientOption{gcp.WithCassette(cassette)}, cassette.Close, nil
+}
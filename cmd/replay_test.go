@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ybonda/gcp-auditor/internal/config"
+	"github.com/ybonda/gcp-auditor/internal/replay"
+	"github.com/ybonda/gcp-auditor/internal/report"
+	"github.com/ybonda/gcp-auditor/internal/repository/gcp"
+	"github.com/ybonda/gcp-auditor/internal/repository/stub"
+	"github.com/ybonda/gcp-auditor/internal/service"
+)
+
+var update = flag.Bool("update", false, "record the replay fixtures again from their stub scenario")
+
+const fixturesDir = "testdata/replay"
+
+// TestAuditReplay runs the audit command offline on the fixtures of testdata/replay
+func TestAuditReplay(t *testing.T) {
+	if *update {
+		recordFixtures(t, fixturesDir)
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configFile := filepath.Join(home, "config.yaml")
+	if err := os.WriteFile(configFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := t.TempDir()
+	rootCmd.SetArgs([]string{
+		"audit",
+		"--config", configFile,
+		"--replay", fixturesDir,
+		"--output-dir", outputDir,
+		"--format", "json",
+		"--progress=false",
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("audit --replay error = %v", err)
+	}
+
+	runs, err := filepath.Glob(filepath.Join(outputDir, "*", "projects.json"))
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("want the projects.json of one run, got %v (%v)", runs, err)
+	}
+	data, err := os.ReadFile(runs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectsReport, err := report.ParseProjectsReport(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, project := range projectsReport.Projects {
+		for _, service := range project.Services {
+			got = append(got, fmt.Sprintf("%s %s %s %d", project.ProjectID, service.Name, service.UsageStatus, service.RequestCount))
+		}
+	}
+	sort.Strings(got)
+	want := []string{
+		"alpha bigquery.googleapis.com SUCCESS 42",
+		"alpha compute.googleapis.com NO_ACCESS 0",
+		"alpha storage.googleapis.com SUCCESS 0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed services = %q, want %q", got, want)
+	}
+}
+
+// recordFixtures audits the stub scenario of dir and records the API calls as its fixtures
+func recordFixtures(t *testing.T, dir string) {
+	t.Helper()
+
+	scenario, err := stub.LoadScenario(filepath.Join(dir, "scenario.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := stub.NewServer(scenario)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	old, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range old {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cassette, err := replay.Record(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cassette.Close()
+
+	ctx := context.Background()
+	client, err := gcp.NewClient(ctx, gcp.WithEmulator(server.URL, server.GRPCAddr), gcp.WithCassette(cassette))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	cfg := config.NewConfig(config.WithOutputDir(t.TempDir()))
+	audit := service.NewAuditService(
+		gcp.NewProjectRepository(client.ResourceManager, nil),
+		gcp.NewServiceRepository(client.ServiceUsage, client.Monitoring, nil),
+		nil, nil, cfg,
+	)
+	if _, err := audit.Audit(ctx); err != nil {
+		t.Fatalf("recording the audit: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/report"
+)
+
+// reportFormats lists the values accepted by --format
+var reportFormats = []string{"markdown", "json", "csv", "html", "xlsx", "ndjson", "openmetrics", "all"}
+
+func validateFormat(format string) error {
+	for _, f := range reportFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format %q. Must be one of: %s", format, strings.Join(reportFormats, ", "))
+}
+
+// validateFormats checks a list of formats. "none" disables reports and cannot be combined.
+func validateFormats(formats []string) error {
+	if reportsDisabled(formats) {
+		return nil
+	}
+	for _, format := range formats {
+		if err := validateFormat(format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportsDisabled reports whether the formats turn off report generation
+func reportsDisabled(formats []string) bool {
+	return len(formats) == 1 && formats[0] == "none"
+}
+
+// newReporters creates the reporters for a list of report formats. The metrics textfile is
+// written whatever the formats, so that it stays current when OpenMetrics reports are not kept.
+func newReporters(formats []string, outputDir, metricsTextfile string) []domain.Reporter {
+	var reporters []domain.Reporter
+	created := make(map[string]bool)
+
+	for _, format := range formats {
+		names := []string{format}
+		if format == "all" {
+			names = reportFormats[:len(reportFormats)-1]
+		}
+
+		for _, name := range names {
+			if created[name] {
+				continue
+			}
+			created[name] = true
+
+			switch name {
+			case "markdown":
+				reporters = append(reporters, report.NewMarkdownReporter(outputDir))
+			case "json":
+				reporters = append(reporters, report.NewJSONReporter(outputDir))
+			case "csv":
+				reporters = append(reporters, report.NewCSVReporter(outputDir))
+			case "html":
+				reporters = append(reporters, report.NewHTMLReporter(outputDir))
+			case "xlsx":
+				reporters = append(reporters, report.NewXLSXReporter(outputDir))
+			case "ndjson":
+				reporters = append(reporters, report.NewNDJSONReporter(outputDir))
+			case "openmetrics":
+				reporters = append(reporters, report.NewOpenMetricsReporter(outputDir, metricsTextfile))
+			}
+		}
+	}
+
+	if metricsTextfile != "" && !created["openmetrics"] {
+		reporters = append(reporters, report.NewMetricsTextfileReporter(metricsTextfile))
+	}
+	return reporters
+}
@@ -1,17 +1,20 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/ybonda/gcp-auditor/internal/config"
 )
 
 var (
-	cfgFile     string
-	outputDir   string
-	daysToAudit int
+	cfgFile string
+
+	// configErr is set when the config file exists but cannot be read
+	configErr error
 )
 
 // rootCmd represents the base command
@@ -20,6 +23,16 @@ var rootCmd = &cobra.Command{
 	Short: "A tool for auditing GCP services usage",
 	Long: `GCP Auditor is a comprehensive tool for analyzing Google Cloud Platform services.
 It helps identify enabled services, their usage patterns, and potential cost optimizations.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if configErr != nil {
+			return configErr
+		}
+		// Flags set on the command line take precedence over the environment and the config file
+		if err := config.BindFlags(viper.GetViper(), cmd.Flags()); err != nil {
+			return err
+		}
+		return config.ApplyProfile(viper.GetViper())
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately
@@ -35,12 +48,9 @@ func init() {
 
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.gcp-auditor.yaml)")
-	rootCmd.PersistentFlags().StringVar(&outputDir, "output-dir", "reports", "output directory for reports")
-	rootCmd.PersistentFlags().IntVar(&daysToAudit, "days", 30, "number of days to analyze usage")
-
-	// Bind flags to viper
-	viper.BindPFlag("output-dir", rootCmd.PersistentFlags().Lookup("output-dir"))
-	viper.BindPFlag("days", rootCmd.PersistentFlags().Lookup("days"))
+	config.AddFlag(rootCmd.PersistentFlags(), "output.dir", "output-dir")
+	config.AddFlag(rootCmd.PersistentFlags(), "audit.days", "days")
+	config.AddFlag(rootCmd.PersistentFlags(), "profile", "profile")
 }
 
 func initConfig() {
@@ -51,7 +61,7 @@ func initConfig() {
 		// Find home directory
 		home, err := os.UserHomeDir()
 		if err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(rootCmd.ErrOrStderr(), err)
 			os.Exit(1)
 		}
 
@@ -61,12 +71,17 @@ func initConfig() {
 		viper.SetConfigName(".gcp-auditor")
 	}
 
-	// Read in environment variables with prefix GCP_AUDITOR
-	viper.SetEnvPrefix("GCP_AUDITOR")
-	viper.AutomaticEnv()
+	// Register defaults and environment variables with prefix GCP_AUDITOR
+	config.Register(viper.GetViper())
 
 	// If a config file is found, read it in
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	err := viper.ReadInConfig()
+	var notFound viper.ConfigFileNotFoundError
+	switch {
+	case err == nil:
+		// Notices go to stderr, since commands like scenario generate write their data to stdout
+		fmt.Fprintln(rootCmd.ErrOrStderr(), "Using config file:", viper.ConfigFileUsed())
+	case !errors.As(err, &notFound):
+		configErr = fmt.Errorf("failed to read config file: %w", err)
 	}
 }
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/ybonda/gcp-auditor/internal/repository/stub"
+)
+
+// scenarioCmd groups the commands that work with stub server scenarios
+var scenarioCmd = &cobra.Command{
+	Use:   "scenario",
+	Short: "Work with scenarios of the stub GCP API server",
+	Long: `Scenarios describe the organization served by the stub GCP API server
+(internal/repository/stub), which the integration tests and benchmarks audit instead of GCP.`,
+}
+
+var scenarioGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a synthetic organization for load testing",
+	Long: `Generates a synthetic organization as a YAML scenario. Service popularity follows a Zipf
+distribution, request counts a log-normal distribution, and failures are injected at the
+configured rates. The same flags and seed always produce the same scenario.
+
+Examples:
+  # 10,000 projects with 200 services each on average
+  gcp-auditor scenario generate --projects 10000 --services 200 --service-pool 500 -o large.yaml
+
+  # Benchmark the auditor against it
+  go test ./test/bench -run '^$' -bench . -args -scenario $PWD/large.yaml`,
+	Args:         cobra.NoArgs,
+	RunE:         runScenarioGenerate,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(scenarioCmd)
+	scenarioCmd.AddCommand(scenarioGenerateCmd)
+
+	defaults := stub.DefaultSyntheticOptions()
+	flags := scenarioGenerateCmd.Flags()
+	flags.StringP("output", "o", "-", "File to write the scenario to, - for standard output")
+	flags.Int64("seed", defaults.Seed, "Seed of the random generator")
+	flags.String("organization", defaults.Organization, "Numeric ID of the organization")
+	flags.Int("projects", defaults.Projects, "Number of projects")
+	flags.Int("folders", defaults.Folders, "Number of folders the projects are spread over")
+	flags.Int("services", defaults.Services, "Mean number of enabled services per project")
+	flags.Float64("services-spread", defaults.ServicesSpread, "Services per project vary uniformly by up to this share of --services")
+	flags.Int("service-pool", defaults.ServicePool, "Number of distinct services")
+	flags.Float64("service-skew", defaults.ServiceSkew, "Zipf exponent of the service popularity, 0 for uniform")
+	flags.Float64("unused-rate", defaults.UnusedRate, "Share of services without requests")
+	flags.Float64("requests-median", defaults.RequestsMedian, "Median request count of used services")
+	flags.Float64("requests-sigma", defaults.RequestsSigma, "Log-normal spread of the request counts")
+	flags.Float64("skip-rate", defaults.SkipRate, "Share of projects whose services cannot be listed")
+	flags.Float64("no-access-rate", defaults.NoAccessRate, "Share of services without access to their metrics")
+	flags.Float64("error-rate", defaults.ErrorRate, "Share of services whose metrics cannot be read")
+	flags.Int("page-size", defaults.PageSize, "Items per page of every list call, 0 for a single page")
+	flags.Duration("latency", defaults.Latency, "Delay of every services.list and ListTimeSeries call")
+}
+
+func runScenarioGenerate(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	opts := stub.SyntheticOptions{}
+	opts.Seed, _ = flags.GetInt64("seed")
+	opts.Organization, _ = flags.GetString("organization")
+	opts.Projects, _ = flags.GetInt("projects")
+	opts.Folders, _ = flags.GetInt("folders")
+	opts.Services, _ = flags.GetInt("services")
+	opts.ServicesSpread, _ = flags.GetFloat64("services-spread")
+	opts.ServicePool, _ = flags.GetInt("service-pool")
+	opts.ServiceSkew, _ = flags.GetFloat64("service-skew")
+	opts.UnusedRate, _ = flags.GetFloat64("unused-rate")
+	opts.RequestsMedian, _ = flags.GetFloat64("requests-median")
+	opts.RequestsSigma, _ = flags.GetFloat64("requests-sigma")
+	opts.SkipRate, _ = flags.GetFloat64("skip-rate")
+	opts.NoAccessRate, _ = flags.GetFloat64("no-access-rate")
+	opts.ErrorRate, _ = flags.GetFloat64("error-rate")
+	opts.PageSize, _ = flags.GetInt("page-size")
+	opts.Latency, _ = flags.GetDuration("latency")
+
+	scenario, err := stub.Generate(opts)
+	if err != nil {
+		return err
+	}
+
+	output, _ := flags.GetString("output")
+	if output == "-" {
+		return stub.WriteScenario(cmd.OutOrStdout(), scenario)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create scenario file: %w", err)
+	}
+	if err := stub.WriteScenario(file, scenario); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write scenario file: %w", err)
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d projects to %s\n", len(scenario.Projects), output)
+	return nil
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ybonda/gcp-auditor/internal/repository/stub"
+)
+
+// TestScenarioGenerateStdout checks that the scenario written to stdout parses, also when a
+// config file is in use
+func TestScenarioGenerateStdout(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte("audit:\n  days: 30\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	})
+	rootCmd.SetArgs([]string{
+		"--config", configFile,
+		"scenario", "generate",
+		"--projects", "20",
+		"--services", "5",
+		"--service-pool", "10",
+		"--seed", "7",
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("scenario generate error = %v", err)
+	}
+
+	scenario, err := stub.ParseScenario(stdout.Bytes())
+	if err != nil {
+		t.Fatalf("ParseScenario() error = %v\n%s", err, stdout.String())
+	}
+	if len(scenario.Projects) != 20 {
+		t.Errorf("parsed %d projects, want 20", len(scenario.Projects))
+	}
+
+	var written bytes.Buffer
+	if err := stub.WriteScenario(&written, scenario); err != nil {
+		t.Fatal(err)
+	}
+	if written.String() != stdout.String() {
+		t.Error("the parsed scenario does not write back to the generated YAML")
+	}
+	if !strings.Contains(stderr.String(), "Using config file: "+configFile) {
+		t.Errorf("stderr = %q, want the config file notice", stderr.String())
+	}
+}
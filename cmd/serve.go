@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/ybonda/gcp-auditor/internal/config"
+	"github.com/ybonda/gcp-auditor/internal/server"
+	"github.com/ybonda/gcp-auditor/internal/service"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run scheduled audits and serve the results over HTTP",
+	Long: `Runs as a long-lived process that audits GCP services on a schedule and keeps the
+latest results in memory.
+
+Endpoints:
+  GET  /metrics          Latest results in OpenMetrics format (Prometheus)
+  GET  /api/v1/report    Latest results as JSON
+  POST /api/v1/audits    Trigger an audit (joins the running audit if there is one)
+  GET  /healthz          Health and audit status
+
+Examples:
+  # Audit every 6 hours and listen on port 8080
+  gcp-auditor serve --interval 6h --listen :8080
+
+  # Also write JSON reports to disk after every audit
+  gcp-auditor serve --format json
+
+  # Trigger an audit on demand and wait for it to finish
+  curl -X POST 'http://localhost:8080/api/v1/audits?wait=true'`,
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	flags := serveCmd.Flags()
+	addLogFlags(serveCmd)
+	config.AddFlag(flags, "serve.listen", "listen")
+	config.AddFlag(flags, "serve.interval", "interval")
+	config.AddFlag(flags, "serve.formats", "format")
+	config.AddFlag(flags, "audit.timeout", "audit-timeout")
+	config.AddFlag(flags, "output.metrics_textfile", "metrics-textfile")
+	config.AddFlag(flags, "audit.group_by", "group-by")
+	config.AddFlag(flags, "audit.concurrency", "concurrency")
+	config.AddFlag(flags, "audit.worker_count", "worker-count")
+	config.AddFlag(flags, "audit.adaptive", "adaptive")
+	config.AddFlag(flags, "audit.max_in_flight", "max-in-flight")
+	addAuthFlags(serveCmd)
+	addTracingFlags(serveCmd)
+	config.AddFlag(flags, "rate_limits.requests_per_second", "rate-limit")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	listen := viper.GetString("serve.listen")
+	interval := viper.GetDuration("serve.interval")
+
+	// Scheduled audits only write reports when asked to, and never stream: the API serves the
+	// services of the last report
+	cfg, err := loadConfig(
+		config.WithFormats(config.StringSlice(viper.GetViper(), "serve.formats")),
+		config.WithStream(false),
+	)
+	if err != nil {
+		return err
+	}
+
+	if !reportsDisabled(cfg.Formats) {
+		if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	closeLog, err := setupLogger(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := setupTracing(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing()
+
+	projectRepo, serviceRepo, closeClients, err := newRepositories(ctx, cfg, nil)
+	if err != nil {
+		logger.Error("Failed to initialize GCP client", "error", err)
+		return err
+	}
+	defer closeClients()
+
+	notifiers, err := loadNotifiers()
+	if err != nil {
+		return err
+	}
+
+	auditService := service.NewAuditService(
+		projectRepo,
+		serviceRepo,
+		newReporters(cfg.Formats, cfg.OutputDir, cfg.MetricsTextfile),
+		notifiers,
+		cfg,
+		service.WithLogger(logger),
+	)
+	if len(notifiers) > 0 {
+		loadBaseline(auditService, cfg.OutputDir, cfg.Profile)
+	}
+
+	srv := server.NewServer(
+		auditService,
+		logger,
+		server.WithInterval(interval),
+		server.WithAuditTimeout(cfg.AuditTimeout),
+	)
+
+	return srv.Run(ctx, listen)
+}
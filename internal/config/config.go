@@ -1,14 +1,51 @@
 package config
 
-import "time"
+import (
+	"log/slog"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/hygiene"
+	"github.com/ybonda/gcp-auditor/internal/policy"
+	"github.com/ybonda/gcp-auditor/internal/tracing"
+	"github.com/ybonda/gcp-auditor/pkg/logging"
+)
 
 type Config struct {
-	OutputDir     string
-	DaysToAudit   int
-	Format        string
-	Verbose       bool
-	Period        time.Duration
-	Concurrency   int
+	OutputDir       string
+	DaysToAudit     int
+	Formats         []string
+	Log             logging.Options
+	Tracing         tracing.Config
+	Period          time.Duration
+	Concurrency     int // Projects processed in parallel
+	WorkerCount     int // Usage lookups in parallel within a project
+	Adaptive        Adaptive
+	AuditTimeout    time.Duration // Maximum duration of a single audit
+	UsageTimeout    time.Duration // Maximum duration of a single usage lookup
+	MetricsTextfile string
+	Stream          bool // Hand projects to incremental reporters as they complete
+	Filters         ProjectFilter
+	RateLimit       RateLimit
+	ReportURL       string // Link included in notifications; "{run}" is replaced by the run directory name
+	Policy          policy.Rules
+	GroupBy         domain.GroupBy // Rollup grouping of projects; zero disables rollups
+	Hygiene         hygiene.Config
+	Profile         string // Name of the config file profile the audit runs with
+	Credentials     Credentials
+	Organizations   []Organization // Audited with their own credentials; empty audits all visible projects
+}
+
+// RateLimit bounds the rate of Service Usage and Monitoring API requests
+type RateLimit struct {
+	RequestsPerSecond float64 // Zero disables the limit
+	Burst             int
+}
+
+// Adaptive adapts the API requests in flight across all projects to quota errors
+type Adaptive struct {
+	Enabled     bool
+	MaxInFlight int // Upper bound of the requests in flight
 }
 
 type Option func(*Config)
@@ -26,15 +63,23 @@ func WithDays(days int) Option {
 	}
 }
 
-func WithFormat(format string) Option {
+func WithFormats(formats []string) Option {
+	return func(c *Config) {
+		if len(formats) > 0 {
+			c.Formats = formats
+		}
+	}
+}
+
+func WithLog(log logging.Options) Option {
 	return func(c *Config) {
-		c.Format = format
+		c.Log = log
 	}
 }
 
-func WithVerbose(verbose bool) Option {
+func WithTracing(tracing tracing.Config) Option {
 	return func(c *Config) {
-		c.Verbose = verbose
+		c.Tracing = tracing
 	}
 }
 
@@ -46,16 +91,116 @@ func WithConcurrency(n int) Option {
 	}
 }
 
+func WithWorkerCount(n int) Option {
+	return func(c *Config) {
+		if n > 0 {
+			c.WorkerCount = n
+		}
+	}
+}
+
+func WithTimeouts(audit, usage time.Duration) Option {
+	return func(c *Config) {
+		if audit > 0 {
+			c.AuditTimeout = audit
+		}
+		if usage > 0 {
+			c.UsageTimeout = usage
+		}
+	}
+}
+
+func WithMetricsTextfile(path string) Option {
+	return func(c *Config) {
+		c.MetricsTextfile = path
+	}
+}
+
+func WithStream(stream bool) Option {
+	return func(c *Config) {
+		c.Stream = stream
+	}
+}
+
+func WithFilters(filters ProjectFilter) Option {
+	return func(c *Config) {
+		c.Filters = filters
+	}
+}
+
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(c *Config) {
+		c.RateLimit = RateLimit{RequestsPerSecond: requestsPerSecond, Burst: burst}
+	}
+}
+
+func WithReportURL(url string) Option {
+	return func(c *Config) {
+		c.ReportURL = url
+	}
+}
+
+func WithPolicy(rules policy.Rules) Option {
+	return func(c *Config) {
+		c.Policy = rules
+	}
+}
+
+func WithGroupBy(groupBy domain.GroupBy) Option {
+	return func(c *Config) {
+		c.GroupBy = groupBy
+	}
+}
+
+func WithHygiene(cfg hygiene.Config) Option {
+	return func(c *Config) {
+		c.Hygiene = cfg
+	}
+}
+
+func WithProfile(name string) Option {
+	return func(c *Config) {
+		c.Profile = name
+	}
+}
+
+func WithAdaptive(enabled bool, maxInFlight int) Option {
+	return func(c *Config) {
+		c.Adaptive.Enabled = enabled
+		if maxInFlight > 0 {
+			c.Adaptive.MaxInFlight = maxInFlight
+		}
+	}
+}
+
+func WithCredentials(credentials Credentials) Option {
+	return func(c *Config) {
+		c.Credentials = credentials
+	}
+}
+
+func WithOrganizations(organizations []Organization) Option {
+	return func(c *Config) {
+		c.Organizations = organizations
+	}
+}
 
 func NewConfig(opts ...Option) *Config {
 	// Default configuration
 	c := &Config{
-		OutputDir:   "reports",
-		DaysToAudit: 30,
-		Format:      "all",
-		Verbose:     false,
-		Period:      30 * 24 * time.Hour,
-		Concurrency: 3,
+		OutputDir:    "reports",
+		DaysToAudit:  30,
+		Formats:      []string{"all"},
+		Log:          logging.Options{Level: slog.LevelInfo, Format: logging.FormatText},
+		Tracing:      tracing.Config{Exporter: tracing.ExporterNone},
+		Period:       30 * 24 * time.Hour,
+		Concurrency:  3,
+		WorkerCount:  10,
+		AuditTimeout: 30 * time.Minute,
+		UsageTimeout: 30 * time.Second,
+		RateLimit:    RateLimit{Burst: 10},
+		Adaptive:     Adaptive{MaxInFlight: 30},
+		Hygiene:      hygiene.DefaultConfig(),
 	}
 
 	// Apply options
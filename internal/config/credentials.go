@@ -0,0 +1,66 @@
+// internal/config/credentials.go
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Credentials selects the credentials of the GCP API clients. The zero value uses
+// Application Default Credentials.
+type Credentials struct {
+	File string `mapstructure:"credentials_file"` // Service account key or external account file
+	// Delegation chain of service accounts; the last one is impersonated
+	ImpersonateServiceAccount []string `mapstructure:"impersonate_service_account"`
+	QuotaProject              string   `mapstructure:"quota_project"` // Project billed for Monitoring API calls
+}
+
+// Validate checks that the delegation chain only holds service account emails
+func (c Credentials) Validate() error {
+	for _, account := range c.ImpersonateServiceAccount {
+		if !strings.Contains(account, "@") {
+			return fmt.Errorf("invalid service account %q to impersonate. Must be an email address", account)
+		}
+	}
+	return nil
+}
+
+// inherit fills the fields that are not set from defaults
+func (c Credentials) inherit(defaults Credentials) Credentials {
+	if c.File == "" {
+		c.File = defaults.File
+	}
+	if len(c.ImpersonateServiceAccount) == 0 {
+		c.ImpersonateServiceAccount = defaults.ImpersonateServiceAccount
+	}
+	if c.QuotaProject == "" {
+		c.QuotaProject = defaults.QuotaProject
+	}
+	return c
+}
+
+// Organization audits the projects of an organization with its own credentials. Credentials
+// that are not set are taken from the auth settings.
+type Organization struct {
+	ID          string      `mapstructure:"id"` // Numeric organization ID
+	Credentials Credentials `mapstructure:",squash"`
+}
+
+// validateOrganizations checks that every organization has a unique numeric ID and valid credentials
+func validateOrganizations(organizations []Organization) error {
+	seen := make(map[string]bool, len(organizations))
+	for _, org := range organizations {
+		if org.ID == "" || strings.Trim(org.ID, "0123456789") != "" {
+			return fmt.Errorf("invalid organization ID %q. Must be numeric", org.ID)
+		}
+		if seen[org.ID] {
+			return fmt.Errorf("organization %s is configured more than once", org.ID)
+		}
+		seen[org.ID] = true
+
+		if err := org.Credentials.Validate(); err != nil {
+			return fmt.Errorf("organization %s: %w", org.ID, err)
+		}
+	}
+	return nil
+}
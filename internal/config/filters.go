@@ -0,0 +1,60 @@
+// internal/config/filters.go
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// ProjectFilter selects the projects to audit. Projects must match one of the include
+// patterns (when given), none of the exclude patterns, and carry all of the labels.
+type ProjectFilter struct {
+	IncludeProjects []string // Glob patterns on project IDs
+	ExcludeProjects []string // Glob patterns on project IDs
+	Labels          []string // "key" (label present) or "key:value"
+}
+
+// Validate checks that all project patterns are valid globs
+func (f ProjectFilter) Validate() error {
+	for _, pattern := range append(f.IncludeProjects[:len(f.IncludeProjects):len(f.IncludeProjects)], f.ExcludeProjects...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid project pattern %q: %w", pattern, err)
+		}
+	}
+	for _, label := range f.Labels {
+		if key, _, _ := strings.Cut(label, ":"); key == "" {
+			return fmt.Errorf("invalid label filter %q. Must be key or key:value", label)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the project passes the filter
+func (f ProjectFilter) Matches(project domain.Project) bool {
+	if len(f.IncludeProjects) > 0 && !matchesAny(f.IncludeProjects, project.ID) {
+		return false
+	}
+	if matchesAny(f.ExcludeProjects, project.ID) {
+		return false
+	}
+	for _, label := range f.Labels {
+		key, value, hasValue := strings.Cut(label, ":")
+		actual, exists := project.Labels[key]
+		if !exists || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(patterns []string, projectID string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, projectID); matched {
+			return true
+		}
+	}
+	return false
+}
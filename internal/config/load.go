@@ -0,0 +1,303 @@
+// internal/config/load.go
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/hygiene"
+	"github.com/ybonda/gcp-auditor/internal/policy"
+	"github.com/ybonda/gcp-auditor/internal/tracing"
+	"github.com/ybonda/gcp-auditor/pkg/logging"
+)
+
+// flagKeyAnnotation marks the flags created by AddFlag with the key they are bound to
+const flagKeyAnnotation = "gcp-auditor/config-key"
+
+// Register sets the defaults of all settings and resolves environment variables
+// with the GCP_AUDITOR_ prefix
+func Register(v *viper.Viper) {
+	for _, setting := range Settings {
+		v.SetDefault(setting.Key, setting.Default)
+	}
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+}
+
+// AddFlag defines a flag for a setting. The flag type and default follow the setting's default.
+func AddFlag(flags *pflag.FlagSet, key, name string) {
+	setting, ok := Lookup(key)
+	if !ok {
+		panic(fmt.Sprintf("config: unknown setting %q", key))
+	}
+
+	switch value := setting.Default.(type) {
+	case int:
+		flags.Int(name, value, setting.Description)
+	case float64:
+		flags.Float64(name, value, setting.Description)
+	case bool:
+		flags.Bool(name, value, setting.Description)
+	case string:
+		flags.String(name, value, setting.Description)
+	case time.Duration:
+		flags.Duration(name, value, setting.Description)
+	case []string:
+		flags.StringSlice(name, value, setting.Description)
+	default:
+		panic(fmt.Sprintf("config: unsupported type %T of setting %q", value, key))
+	}
+
+	flags.SetAnnotation(name, flagKeyAnnotation, []string{key})
+}
+
+// FlagKey returns the key of the setting a flag created by AddFlag is bound to
+func FlagKey(flag *pflag.Flag) (string, bool) {
+	keys, ok := flag.Annotations[flagKeyAnnotation]
+	if !ok || len(keys) == 0 {
+		return "", false
+	}
+	return keys[0], true
+}
+
+// BindFlags binds the flags created by AddFlag to their settings, so that flags
+// explicitly set on the command line take precedence over all other sources
+func BindFlags(v *viper.Viper, flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if key, ok := FlagKey(flag); ok && err == nil {
+			err = v.BindPFlag(key, flag)
+		}
+	})
+	return err
+}
+
+// Source describes where the effective value of a setting comes from
+func Source(v *viper.Viper, flags *pflag.FlagSet, key string) string {
+	var source string
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flagKey, ok := FlagKey(flag); ok && flagKey == key && flag.Changed {
+			source = "flag --" + flag.Name
+		}
+	})
+	if source != "" {
+		return source
+	}
+
+	if _, ok := os.LookupEnv(EnvVar(key)); ok {
+		return "env " + EnvVar(key)
+	}
+	if inProfile(v, key) {
+		return "profile " + v.GetString("profile")
+	}
+	if v.InConfig(key) {
+		return "file"
+	}
+	return "default"
+}
+
+// StringSlice returns a list setting. Values may be given as a list, or as a comma
+// or space separated string in flags and environment variables.
+func StringSlice(v *viper.Viper, key string) []string {
+	var values []string
+	for _, value := range v.GetStringSlice(key) {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
+
+// Load builds the audit configuration from all sources registered on v. Options are
+// applied last and override the loaded values.
+func Load(v *viper.Viper, opts ...Option) (*Config, error) {
+	if err := validateSettings(v); err != nil {
+		return nil, err
+	}
+
+	groupBy, err := domain.ParseGroupBy(v.GetString("audit.group_by"))
+	if err != nil {
+		return nil, err
+	}
+
+	filters := ProjectFilter{
+		IncludeProjects: StringSlice(v, "filters.include_projects"),
+		ExcludeProjects: StringSlice(v, "filters.exclude_projects"),
+		Labels:          StringSlice(v, "filters.labels"),
+	}
+	if err := filters.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid filters: %w", err)
+	}
+
+	var rules policy.Rules
+	if err := v.UnmarshalKey("policy", &rules); err != nil {
+		return nil, fmt.Errorf("invalid policy configuration: %w", err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy configuration: %w", err)
+	}
+
+	// Weights that are not set keep their defaults
+	hygieneConfig := hygiene.DefaultConfig()
+	if err := v.UnmarshalKey("hygiene", &hygieneConfig); err != nil {
+		return nil, fmt.Errorf("invalid hygiene configuration: %w", err)
+	}
+	if err := hygieneConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid hygiene configuration: %w", err)
+	}
+
+	logOptions, err := loadLogOptions(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log configuration: %w", err)
+	}
+
+	tracingConfig := tracing.Config{
+		Exporter: strings.ToLower(v.GetString("tracing.exporter")),
+		Endpoint: v.GetString("tracing.endpoint"),
+		Insecure: v.GetBool("tracing.insecure"),
+		File:     v.GetString("tracing.file"),
+	}
+	if err := tracingConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid tracing configuration: %w", err)
+	}
+
+	credentials := Credentials{
+		File:                      v.GetString("auth.credentials_file"),
+		ImpersonateServiceAccount: StringSlice(v, "auth.impersonate_service_account"),
+		QuotaProject:              v.GetString("auth.quota_project"),
+	}
+	if err := credentials.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid auth configuration: %w", err)
+	}
+
+	var organizations []Organization
+	if err := v.UnmarshalKey("organizations", &organizations); err != nil {
+		return nil, fmt.Errorf("invalid organizations configuration: %w", err)
+	}
+	if err := validateOrganizations(organizations); err != nil {
+		return nil, fmt.Errorf("invalid organizations configuration: %w", err)
+	}
+	for i := range organizations {
+		organizations[i].Credentials = organizations[i].Credentials.inherit(credentials)
+	}
+
+	options := []Option{
+		WithOutputDir(v.GetString("output.dir")),
+		WithDays(v.GetInt("audit.days")),
+		WithFormats(StringSlice(v, "output.formats")),
+		WithLog(logOptions),
+		WithTracing(tracingConfig),
+		WithConcurrency(v.GetInt("audit.concurrency")),
+		WithWorkerCount(v.GetInt("audit.worker_count")),
+		WithAdaptive(v.GetBool("audit.adaptive"), v.GetInt("audit.max_in_flight")),
+		WithTimeouts(v.GetDuration("audit.timeout"), v.GetDuration("audit.usage_timeout")),
+		WithMetricsTextfile(v.GetString("output.metrics_textfile")),
+		WithStream(v.GetBool("output.stream")),
+		WithFilters(filters),
+		WithRateLimit(v.GetFloat64("rate_limits.requests_per_second"), v.GetInt("rate_limits.burst")),
+		WithReportURL(v.GetString("notifications.report_url")),
+		WithPolicy(rules),
+		WithGroupBy(groupBy),
+		WithHygiene(hygieneConfig),
+		WithProfile(v.GetString("profile")),
+		WithCredentials(credentials),
+		WithOrganizations(organizations),
+	}
+
+	return NewConfig(append(options, opts...)...), nil
+}
+
+// loadLogOptions resolves the log settings; log.verbose is a shorthand for the debug level
+func loadLogOptions(v *viper.Viper) (logging.Options, error) {
+	level, err := logging.ParseLevel(v.GetString("log.level"))
+	if err != nil {
+		return logging.Options{}, err
+	}
+	if v.GetBool("log.verbose") {
+		level = min(level, slog.LevelDebug)
+	}
+
+	format := strings.ToLower(v.GetString("log.format"))
+	if err := logging.ValidateFormat(format); err != nil {
+		return logging.Options{}, err
+	}
+
+	return logging.Options{
+		Level:  level,
+		Format: format,
+		File:   v.GetString("log.file"),
+	}, nil
+}
+
+// validateSettings checks the ranges of the numeric settings
+func validateSettings(v *viper.Viper) error {
+	positive := []string{"audit.days", "audit.concurrency", "audit.worker_count", "audit.max_in_flight"}
+	for _, key := range positive {
+		if v.GetInt(key) <= 0 {
+			return fmt.Errorf("%s must be positive, got %q", key, v.GetString(key))
+		}
+	}
+
+	for _, key := range []string{"audit.timeout", "audit.usage_timeout"} {
+		if v.GetDuration(key) <= 0 {
+			return fmt.Errorf("%s must be a positive duration, got %q", key, v.GetString(key))
+		}
+	}
+
+	if v.GetFloat64("rate_limits.requests_per_second") < 0 {
+		return fmt.Errorf("rate_limits.requests_per_second must not be negative")
+	}
+	if v.GetInt("rate_limits.burst") < 1 {
+		return fmt.Errorf("rate_limits.burst must be at least 1")
+	}
+	if v.GetDuration("serve.interval") < 0 {
+		return fmt.Errorf("serve.interval must not be negative")
+	}
+	return nil
+}
+
+// UnknownKeys returns the keys of the config file, including those of its profiles,
+// that are not part of the schema
+func UnknownKeys(v *viper.Viper) []string {
+	var unknown []string
+	for _, key := range v.AllKeys() {
+		if !v.InConfig(key) {
+			continue
+		}
+
+		schemaKey := key
+		if strings.HasPrefix(key, ProfilesKey+".") {
+			// profiles.<name>.<key>; profiles cannot select other profiles
+			parts := strings.SplitN(key, ".", 3)
+			if len(parts) < 3 || parts[2] == "profile" {
+				unknown = append(unknown, key)
+				continue
+			}
+			schemaKey = parts[2]
+		}
+
+		if _, ok := Lookup(schemaKey); ok || inSection(schemaKey) {
+			continue
+		}
+		unknown = append(unknown, key)
+	}
+	return unknown
+}
+
+func inSection(key string) bool {
+	for _, section := range Sections {
+		if key == section || strings.HasPrefix(key, section+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+// internal/config/profile.go
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ProfilesKey holds the named profiles in the config file. Every profile uses the same
+// schema as the top level of the file and overrides its values.
+const ProfilesKey = "profiles"
+
+// ApplyProfile overlays the profile selected by the "profile" setting on the values of the
+// config file. Flags and environment variables keep precedence over the profile.
+func ApplyProfile(v *viper.Viper) error {
+	name := v.GetString("profile")
+	if name == "" {
+		return nil
+	}
+
+	key := ProfilesKey + "." + name
+	if !v.IsSet(key) {
+		available := Profiles(v)
+		if len(available) == 0 {
+			return fmt.Errorf("profile %q not found: the config file defines no profiles", name)
+		}
+		return fmt.Errorf("profile %q not found. Available profiles: %s", name, strings.Join(available, ", "))
+	}
+
+	settings := v.GetStringMap(key)
+	if _, nested := settings["profile"]; nested {
+		return fmt.Errorf("profile %q must not select another profile", name)
+	}
+
+	if err := v.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("failed to apply profile %q: %w", name, err)
+	}
+	return nil
+}
+
+// Profiles returns the names of the profiles defined in the config file
+func Profiles(v *viper.Viper) []string {
+	profiles := v.GetStringMap(ProfilesKey)
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inProfile reports whether the selected profile sets a key
+func inProfile(v *viper.Viper, key string) bool {
+	name := v.GetString("profile")
+	return name != "" && v.InConfig(ProfilesKey+"."+name+"."+key)
+}
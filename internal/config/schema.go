@@ -0,0 +1,76 @@
+// internal/config/schema.go
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// EnvPrefix is prepended to the environment variable of every setting
+const EnvPrefix = "GCP_AUDITOR"
+
+// Setting describes a scalar configuration key. Its value is resolved from, in order of
+// precedence, a command-line flag, an environment variable, the config file and the default.
+// Commands bind their flags to settings with AddFlag.
+type Setting struct {
+	Key         string      // Dotted key in the config file, e.g. "audit.days"
+	Default     interface{} // Also determines the type of the flags
+	Description string
+}
+
+// Settings is the schema of all scalar configuration keys
+var Settings = []Setting{
+	{"profile", "", "Named profile from the profiles section of the config file"},
+	{"audit.days", 30, "Number of days to analyze usage"},
+	{"audit.concurrency", 3, "Projects processed in parallel"},
+	{"audit.worker_count", 10, "Usage lookups in parallel within a project"},
+	{"audit.adaptive", false, "Adapt the API requests in flight to quota errors, up to audit.max_in_flight"},
+	{"audit.max_in_flight", 30, "Maximum API requests in flight across all projects in adaptive mode"},
+	{"audit.timeout", 30 * time.Minute, "Maximum duration of a single audit"},
+	{"audit.usage_timeout", 30 * time.Second, "Maximum duration of a single usage lookup"},
+	{"audit.group_by", "", "Roll up projects per group (label:<key> or folder)"},
+	{"filters.include_projects", []string{}, "Only audit projects whose ID matches one of these glob patterns"},
+	{"filters.exclude_projects", []string{}, "Skip projects whose ID matches one of these glob patterns"},
+	{"filters.labels", []string{}, "Only audit projects carrying all of these labels (key or key:value)"},
+	{"auth.credentials_file", "", "Service account key or external account file (default: Application Default Credentials)"},
+	{"auth.impersonate_service_account", []string{}, "Service account to impersonate; a list is a delegation chain ending with the impersonated account"},
+	{"auth.quota_project", "", "Project billed for the quota of Monitoring API calls"},
+	{"output.dir", "reports", "Directory for report output"},
+	{"output.formats", []string{"all"}, "Report formats (markdown, json, csv, html, xlsx, ndjson, openmetrics, all)"},
+	{"output.metrics_textfile", "", "Also write OpenMetrics results to this node_exporter textfile path, whatever the formats"},
+	{"output.stream", false, "Write JSON, NDJSON and Markdown reports project by project as the audit runs"},
+	{"rate_limits.requests_per_second", 0.0, "Maximum Service Usage and Monitoring API requests per second (0 disables the limit)"},
+	{"rate_limits.burst", 10, "Requests allowed above the rate limit in a burst"},
+	{"log.verbose", false, "Log debug records (same as --log-level debug)"},
+	{"log.level", "info", "Minimum level of log records (debug, info, warn, error)"},
+	{"log.format", "text", "Format of log records (text, json)"},
+	{"log.file", "", "Append log records to this file instead of standard error"},
+	{"log.progress", true, "Show a live progress display instead of progress log lines when standard error is a terminal"},
+	{"tracing.exporter", "none", "Export OpenTelemetry spans of audits (none, otlp, file)"},
+	{"tracing.endpoint", "", "Address of the OTLP gRPC collector (default: OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317)"},
+	{"tracing.insecure", false, "Connect to the OTLP collector without TLS"},
+	{"tracing.file", "traces.jsonl", "File the file exporter appends spans to, one JSON object per span"},
+	{"notifications.report_url", "", "Link included in notifications; {run} is replaced by the run directory name"},
+	{"serve.listen", ":8080", "Address the server listens on"},
+	{"serve.interval", 24 * time.Hour, "Time between scheduled audits (0 disables scheduling)"},
+	{"serve.formats", []string{"none"}, "Report formats written after every scheduled audit (none disables reports)"},
+}
+
+// Sections are configuration keys holding structured values that are only read from the
+// config file
+var Sections = []string{"policy", "hygiene", "notifications.channels", "digest", "organizations", ProfilesKey}
+
+// EnvVar returns the environment variable of a key, e.g. GCP_AUDITOR_AUDIT_DAYS
+func EnvVar(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Lookup returns the setting of a key
+func Lookup(key string) (Setting, bool) {
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
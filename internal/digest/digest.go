@@ -0,0 +1,199 @@
+// internal/digest/digest.go
+package digest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// unownedGroup collects projects without any of the owner labels
+const unownedGroup = "unowned"
+
+// Config configures owner digests. Project label values cannot contain "@", so owners are
+// mapped to addresses through Recipients or RecipientDomain.
+type Config struct {
+	OwnerLabels       []string          `mapstructure:"owner_labels"`       // Labels identifying the owner, first match wins
+	Recipients        map[string]string `mapstructure:"recipients"`         // Owner label value to email address
+	RecipientDomain   string            `mapstructure:"recipient_domain"`   // Fallback: <owner>@<domain>
+	FallbackRecipient string            `mapstructure:"fallback_recipient"` // Receives projects without an owner label
+	From              string            `mapstructure:"from"`
+	Subject           string            `mapstructure:"subject"`
+	SMTP              SMTPConfig        `mapstructure:"smtp"`
+}
+
+// SMTPConfig configures the mail server. STARTTLS is used when the server offers it.
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// OwnerDigest holds the findings of all projects belonging to one owner
+type OwnerDigest struct {
+	Owner       string
+	Recipient   string
+	GeneratedAt time.Time
+	PeriodDays  int
+	ReportURL   string
+	Projects    []ProjectFindings
+}
+
+// ProjectFindings lists what needs the owner's attention in a single project
+type ProjectFindings struct {
+	ProjectID        string
+	ProjectName      string
+	SkippedError     string
+	InactiveServices []string
+	ServiceErrors    []ServiceError
+	Violations       []domain.PolicyViolation
+}
+
+type ServiceError struct {
+	Service string
+	Message string
+}
+
+// HasFindings reports whether there is anything to tell the owner about the project
+func (p ProjectFindings) HasFindings() bool {
+	return p.SkippedError != "" || len(p.InactiveServices) > 0 || len(p.ServiceErrors) > 0 || len(p.Violations) > 0
+}
+
+// InactiveCount returns the number of inactive services across the digest
+func (d OwnerDigest) InactiveCount() int {
+	count := 0
+	for _, p := range d.Projects {
+		count += len(p.InactiveServices)
+	}
+	return count
+}
+
+// ErrorCount returns the number of services with metric errors and skipped projects
+func (d OwnerDigest) ErrorCount() int {
+	count := 0
+	for _, p := range d.Projects {
+		count += len(p.ServiceErrors)
+		if p.SkippedError != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// ViolationCount returns the number of policy violations across the digest
+func (d OwnerDigest) ViolationCount() int {
+	count := 0
+	for _, p := range d.Projects {
+		count += len(p.Violations)
+	}
+	return count
+}
+
+// BuildDigests groups the findings of the audit by owner. Owners without findings get no digest.
+func BuildDigests(report domain.AuditReport, reportURL string, cfg Config) []OwnerDigest {
+	ownerLabels := cfg.OwnerLabels
+	if len(ownerLabels) == 0 {
+		ownerLabels = []string{"owner", "team"}
+	}
+
+	violations := make(map[string][]domain.PolicyViolation)
+	for _, violation := range report.PolicyViolations {
+		violations[violation.ProjectID] = append(violations[violation.ProjectID], violation)
+	}
+
+	digests := make(map[string]*OwnerDigest)
+	for _, project := range report.Projects {
+		findings := projectFindings(report, project, violations[project.ID])
+		if !findings.HasFindings() {
+			continue
+		}
+
+		owner := ownerOf(project, ownerLabels)
+		digest, exists := digests[owner]
+		if !exists {
+			digest = &OwnerDigest{
+				Owner:       owner,
+				Recipient:   cfg.recipientFor(owner),
+				GeneratedAt: report.GeneratedAt,
+				PeriodDays:  int(report.Period / (24 * time.Hour)),
+				ReportURL:   reportURL,
+			}
+			digests[owner] = digest
+		}
+		digest.Projects = append(digest.Projects, findings)
+	}
+
+	result := make([]OwnerDigest, 0, len(digests))
+	for _, digest := range digests {
+		sort.Slice(digest.Projects, func(i, j int) bool {
+			return digest.Projects[i].ProjectID < digest.Projects[j].ProjectID
+		})
+		result = append(result, *digest)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Owner < result[j].Owner
+	})
+
+	return result
+}
+
+func projectFindings(report domain.AuditReport, project domain.Project, violations []domain.PolicyViolation) ProjectFindings {
+	findings := ProjectFindings{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		Violations:  violations,
+	}
+
+	if err, skipped := report.SkippedProjects[project.ID]; skipped && err != nil {
+		findings.SkippedError = err.Error()
+	}
+
+	for _, service := range report.Services[project.ID] {
+		if service.Usage == nil {
+			continue
+		}
+
+		switch service.Usage.Status {
+		case domain.UsageStatusSuccess:
+			if service.Usage.RequestCount == 0 {
+				findings.InactiveServices = append(findings.InactiveServices, service.Name)
+			}
+		case domain.UsageStatusNoAccess, domain.UsageStatusError:
+			findings.ServiceErrors = append(findings.ServiceErrors, ServiceError{
+				Service: service.Name,
+				Message: service.Usage.Error,
+			})
+		}
+	}
+
+	sort.Strings(findings.InactiveServices)
+	sort.Slice(findings.ServiceErrors, func(i, j int) bool {
+		return findings.ServiceErrors[i].Service < findings.ServiceErrors[j].Service
+	})
+
+	return findings
+}
+
+func ownerOf(project domain.Project, ownerLabels []string) string {
+	for _, label := range ownerLabels {
+		if owner := project.Labels[label]; owner != "" {
+			return owner
+		}
+	}
+	return unownedGroup
+}
+
+func (c Config) recipientFor(owner string) string {
+	if owner == unownedGroup {
+		return c.FallbackRecipient
+	}
+	if recipient, exists := c.Recipients[owner]; exists {
+		return recipient
+	}
+	if c.RecipientDomain != "" {
+		return owner + "@" + c.RecipientDomain
+	}
+	return c.FallbackRecipient
+}
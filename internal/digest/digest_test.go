@@ -0,0 +1,337 @@
+package digest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+var generated = time.Date(2024, 11, 27, 12, 34, 56, 0, time.UTC)
+
+func usage(status domain.UsageStatus, requests int64, message string) *domain.Usage {
+	return &domain.Usage{Status: status, RequestCount: requests, Error: message}
+}
+
+// testReport has projects of several owners: owned through each owner label, unowned, without
+// findings, skipped and with policy violations
+func testReport() domain.AuditReport {
+	return domain.AuditReport{
+		StartTime:   generated.Add(-time.Hour),
+		GeneratedAt: generated,
+		Period:      30 * 24 * time.Hour,
+		Projects: []domain.Project{
+			{ID: "data-prod", Name: "Data prod", Labels: map[string]string{"owner": "data", "team": "ignored"}},
+			{ID: "data-dev", Labels: map[string]string{"team": "data"}},
+			{ID: "web", Labels: map[string]string{"owner": "web"}},
+			{ID: "clean", Labels: map[string]string{"owner": "clean"}},
+			{ID: "orphan"},
+			{ID: "broken", Labels: map[string]string{"owner": "ops"}},
+		},
+		Services: map[string][]domain.Service{
+			"data-prod": {
+				{Name: "storage.googleapis.com", Usage: usage(domain.UsageStatusSuccess, 0, "")},
+				{Name: "bigquery.googleapis.com", Usage: usage(domain.UsageStatusSuccess, 0, "")},
+				{Name: "compute.googleapis.com", Usage: usage(domain.UsageStatusSuccess, 12, "")},
+			},
+			"data-dev": {
+				{Name: "pubsub.googleapis.com", Usage: usage(domain.UsageStatusNoAccess, 0, "permission denied")},
+				{Name: "dataflow.googleapis.com", Usage: usage(domain.UsageStatusError, 0, "boom")},
+			},
+			"web":    {{Name: "run.googleapis.com", Usage: usage(domain.UsageStatusSuccess, 5, "")}},
+			"clean":  {{Name: "run.googleapis.com", Usage: usage(domain.UsageStatusSuccess, 1, "")}, {Name: "logging.googleapis.com"}},
+			"orphan": {{Name: "storage.googleapis.com", Usage: usage(domain.UsageStatusSuccess, 0, "")}},
+		},
+		SkippedProjects: map[string]error{"broken": errors.New("permission denied")},
+		PolicyViolations: []domain.PolicyViolation{
+			{ProjectID: "web", Rule: "required-label", Message: "missing label env"},
+		},
+	}
+}
+
+func TestBuildDigests(t *testing.T) {
+	cfg := Config{
+		Recipients:        map[string]string{"data": "data-team@example.com"},
+		RecipientDomain:   "example.org",
+		FallbackRecipient: "finops@example.com",
+	}
+
+	digests := BuildDigests(testReport(), "https://reports.example.com/run", cfg)
+
+	type summary struct {
+		owner, recipient             string
+		projects                     []string
+		inactive, errors, violations int
+	}
+	var got []summary
+	for _, digest := range digests {
+		s := summary{
+			owner:      digest.Owner,
+			recipient:  digest.Recipient,
+			inactive:   digest.InactiveCount(),
+			errors:     digest.ErrorCount(),
+			violations: digest.ViolationCount(),
+		}
+		for _, project := range digest.Projects {
+			s.projects = append(s.projects, project.ProjectID)
+		}
+		got = append(got, s)
+
+		if digest.PeriodDays != 30 || !digest.GeneratedAt.Equal(generated) || digest.ReportURL != "https://reports.example.com/run" {
+			t.Errorf("digest of %s has period %d, generated %s, URL %q", digest.Owner, digest.PeriodDays, digest.GeneratedAt, digest.ReportURL)
+		}
+	}
+	want := []summary{
+		{owner: "data", recipient: "data-team@example.com", projects: []string{"data-dev", "data-prod"}, inactive: 2, errors: 2},
+		{owner: "ops", recipient: "ops@example.org", projects: []string{"broken"}, errors: 1},
+		{owner: "unowned", recipient: "finops@example.com", projects: []string{"orphan"}, inactive: 1},
+		{owner: "web", recipient: "web@example.org", projects: []string{"web"}, violations: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildDigests() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	prod := digests[0].Projects[1]
+	if want := []string{"bigquery.googleapis.com", "storage.googleapis.com"}; !reflect.DeepEqual(prod.InactiveServices, want) {
+		t.Errorf("inactive services of data-prod = %v, want %v", prod.InactiveServices, want)
+	}
+	dev := digests[0].Projects[0]
+	wantErrors := []ServiceError{
+		{Service: "dataflow.googleapis.com", Message: "boom"},
+		{Service: "pubsub.googleapis.com", Message: "permission denied"},
+	}
+	if !reflect.DeepEqual(dev.ServiceErrors, wantErrors) {
+		t.Errorf("service errors of data-dev = %v, want %v", dev.ServiceErrors, wantErrors)
+	}
+	if got := digests[1].Projects[0].SkippedError; got != "permission denied" {
+		t.Errorf("skipped error of broken = %q, want %q", got, "permission denied")
+	}
+}
+
+func TestBuildDigestsOwnerLabels(t *testing.T) {
+	digests := BuildDigests(testReport(), "", Config{OwnerLabels: []string{"team"}})
+
+	var owners []string
+	for _, digest := range digests {
+		owners = append(owners, digest.Owner)
+	}
+	// Only the team label counts, so data-prod belongs to "ignored" and the projects with just
+	// an owner label are unowned
+	if want := []string{"data", "ignored", "unowned"}; !reflect.DeepEqual(owners, want) {
+		t.Errorf("owners = %v, want %v", owners, want)
+	}
+}
+
+func TestRecipientFor(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		owner string
+		want  string
+	}{
+		{name: "mapped owner", cfg: Config{Recipients: map[string]string{"data": "d@example.com"}, RecipientDomain: "example.org"}, owner: "data", want: "d@example.com"},
+		{name: "owner at domain", cfg: Config{RecipientDomain: "example.org", FallbackRecipient: "f@example.com"}, owner: "web", want: "web@example.org"},
+		{name: "fallback", cfg: Config{FallbackRecipient: "f@example.com"}, owner: "web", want: "f@example.com"},
+		{name: "unowned ignores the domain", cfg: Config{RecipientDomain: "example.org", FallbackRecipient: "f@example.com"}, owner: unownedGroup, want: "f@example.com"},
+		{name: "unresolved", cfg: Config{RecipientDomain: "example.org"}, owner: unownedGroup, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.recipientFor(tt.owner); got != tt.want {
+				t.Errorf("recipientFor(%q) = %q, want %q", tt.owner, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifyDryRun(t *testing.T) {
+	dir := t.TempDir()
+	notifier, err := NewNotifier(Config{
+		From:            "auditor@example.com",
+		Subject:         "Audit {run} for {owner}",
+		RecipientDomain: "example.org",
+	}, dir)
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+
+	report := testReport()
+	err = notifier.Notify(context.Background(), domain.Notification{Report: report})
+	// The unowned project has no recipient without a fallback, the other digests are written
+	if err == nil || !strings.Contains(err.Error(), `no recipient for owner "unowned"`) {
+		t.Errorf("Notify() error = %v, want the unowned digest to be reported", err)
+	}
+
+	digestDir := filepath.Join(dir, report.RunID(), "digest")
+	entries, err := os.ReadDir(digestDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	if want := []string{"data.eml", "ops.eml", "web.eml"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("dry run wrote %v, want %v", files, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(digestDir, "data.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{
+		"From: auditor@example.com\r\n",
+		"To: data@example.org\r\n",
+		"Subject: Audit " + report.RunID() + " for data\r\n",
+		"Date: " + generated.Format(time.RFC1123Z) + "\r\n",
+		"Content-Type: multipart/alternative; boundary=",
+	} {
+		if !strings.Contains(string(data), header) {
+			t.Errorf("data.eml lacks %q:\n%s", header, data)
+		}
+	}
+	for _, part := range []string{"Content-Type: text/plain; charset=utf-8", "Content-Type: text/html; charset=utf-8", "data-prod"} {
+		if !strings.Contains(string(data), part) {
+			t.Errorf("data.eml lacks %q", part)
+		}
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	if got, want := sanitizeFilename("../data team/ä"), ".._data_team__"; got != want {
+		t.Errorf("sanitizeFilename() = %q, want %q", got, want)
+	}
+}
+
+// serveSMTP answers one SMTP session on listener and returns the commands and message it got
+func serveSMTP(t *testing.T, listener net.Listener) <-chan []string {
+	t.Helper()
+
+	received := make(chan []string, 1)
+	go func() {
+		defer close(received)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var lines []string
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				received <- lines
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch command := strings.ToUpper(strings.Fields(line + " ")[0]); command {
+			case "EHLO":
+				reply("250 localhost")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					lines = append(lines, strings.TrimRight(line, "\r\n"))
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return received
+}
+
+func newSMTPNotifier(t *testing.T, listener net.Listener) *Notifier {
+	t.Helper()
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	notifier, err := NewNotifier(Config{
+		From:              "auditor@example.com",
+		FallbackRecipient: "finops@example.com",
+		SMTP:              SMTPConfig{Host: host, Port: portNumber},
+	}, "")
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+	return notifier
+}
+
+func TestNotifySMTP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := serveSMTP(t, listener)
+
+	report := testReport()
+	report.Projects = report.Projects[4:5] // Only the unowned project, so one digest is sent
+	if err := newSMTPNotifier(t, listener).Notify(context.Background(), domain.Notification{Report: report}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	lines := <-received
+	session := strings.Join(lines, "\n")
+	for _, want := range []string{"MAIL FROM:<auditor@example.com>", "RCPT TO:<finops@example.com>", "DATA", "To: finops@example.com", "QUIT"} {
+		if !strings.Contains(session, want) {
+			t.Errorf("SMTP session lacks %q:\n%s", want, session)
+		}
+	}
+}
+
+func TestNotifySMTPUnresponsive(t *testing.T) {
+	// The server accepts connections but never greets, like a blackholed host behind a proxy
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	report := testReport()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = newSMTPNotifier(t, listener).Notify(ctx, domain.Notification{Report: report})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Notify() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Notify() returned after %s", elapsed)
+	}
+}
@@ -0,0 +1,102 @@
+// internal/digest/email.go
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	textTemplate = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/digest.txt.tmpl"))
+	htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/digest.html.tmpl"))
+)
+
+// Email is a rendered digest ready to be sent or written to disk
+type Email struct {
+	From    string
+	To      string
+	Subject string
+	Raw     []byte // RFC 5322 message with a multipart/alternative body
+}
+
+// renderEmail renders the plain text and HTML versions of a digest into a single message
+func renderEmail(digest OwnerDigest, from, subject string, date time.Time) (Email, error) {
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, digest); err != nil {
+		return Email{}, fmt.Errorf("failed to render text template: %w", err)
+	}
+	if err := htmlTemplate.Execute(&html, digest); err != nil {
+		return Email{}, fmt.Errorf("failed to render HTML template: %w", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return Email{}, fmt.Errorf("failed to create message part: %w", err)
+		}
+		qp := quotedprintable.NewWriter(partWriter)
+		if _, err := qp.Write(part.content); err != nil {
+			return Email{}, fmt.Errorf("failed to encode message part: %w", err)
+		}
+		if err := qp.Close(); err != nil {
+			return Email{}, fmt.Errorf("failed to encode message part: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return Email{}, fmt.Errorf("failed to finish message: %w", err)
+	}
+
+	var raw bytes.Buffer
+	headers := [][2]string{
+		{"From", from},
+		{"To", digest.Recipient},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", writer.Boundary())},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&raw, "%s: %s\r\n", header[0], header[1])
+	}
+	raw.WriteString("\r\n")
+	raw.Write(body.Bytes())
+
+	return Email{
+		From:    from,
+		To:      digest.Recipient,
+		Subject: subject,
+		Raw:     raw.Bytes(),
+	}, nil
+}
+
+// subjectFor expands the {owner} and {run} placeholders of the configured subject
+func subjectFor(format, owner, runID string) string {
+	if format == "" {
+		format = "GCP audit digest for {owner}"
+	}
+	return strings.NewReplacer("{owner}", owner, "{run}", runID).Replace(format)
+}
@@ -0,0 +1,180 @@
+// internal/digest/notifier.go
+package digest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// smtpTimeout bounds connecting to the mail server and delivering one digest
+const smtpTimeout = 30 * time.Second
+
+// Notifier emails every owner a digest of the findings in their projects. In dry-run mode
+// the messages are written as .eml files to <outputDir>/<run>/digest instead of being sent.
+type Notifier struct {
+	config    Config
+	dryRunDir string
+}
+
+// NewNotifier validates the digest configuration. A non-empty dryRunDir enables dry-run mode.
+func NewNotifier(cfg Config, dryRunDir string) (*Notifier, error) {
+	if cfg.From == "" {
+		return nil, fmt.Errorf("digest: from address is required")
+	}
+	if dryRunDir == "" && cfg.SMTP.Host == "" {
+		return nil, fmt.Errorf("digest: smtp.host is required unless --dry-run is set")
+	}
+	if len(cfg.Recipients) == 0 && cfg.RecipientDomain == "" && cfg.FallbackRecipient == "" {
+		return nil, fmt.Errorf("digest: at least one of recipients, recipient_domain or fallback_recipient is required")
+	}
+
+	return &Notifier{config: cfg, dryRunDir: dryRunDir}, nil
+}
+
+// Notify sends one digest per owner. Owners without a resolvable recipient are reported in
+// the returned error after all other digests have been delivered.
+func (n *Notifier) Notify(ctx context.Context, notification domain.Notification) error {
+	report := notification.Report
+	digests := BuildDigests(report, notification.ReportURL, n.config)
+
+	var errs []error
+	for _, digest := range digests {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if digest.Recipient == "" {
+			errs = append(errs, fmt.Errorf("no recipient for owner %q", digest.Owner))
+			continue
+		}
+
+		email, err := renderEmail(digest, n.config.From, subjectFor(n.config.Subject, digest.Owner, report.RunID()), report.GeneratedAt)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("owner %q: %w", digest.Owner, err))
+			continue
+		}
+
+		if n.dryRunDir != "" {
+			err = writeEmail(filepath.Join(n.dryRunDir, report.RunID(), "digest"), digest.Owner, email)
+		} else {
+			err = n.send(ctx, email)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("owner %q: %w", digest.Owner, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) send(ctx context.Context, email Email) error {
+	port := n.config.SMTP.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(n.config.SMTP.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if n.config.SMTP.Username != "" {
+		auth = smtp.PlainAuth("", n.config.SMTP.Username, n.config.SMTP.Password, n.config.SMTP.Host)
+	}
+
+	if err := n.deliver(ctx, addr, auth, email); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return fmt.Errorf("failed to send email to %s: %w", email.To, err)
+	}
+	return nil
+}
+
+// deliver sends the email like smtp.SendMail, but gives up after smtpTimeout or when ctx is done
+func (n *Notifier) deliver(ctx context.Context, addr string, auth smtp.Auth, email Email) error {
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(smtpTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	// Cancelling ctx interrupts the exchange with the server
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, n.config.SMTP.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.config.SMTP.Host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(email.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(email.To); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(email.Raw); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func writeEmail(dir, owner string, email Email) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create digest directory: %w", err)
+	}
+
+	filename := filepath.Join(dir, sanitizeFilename(owner)+".eml")
+	if err := os.WriteFile(filename, email.Raw, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
+
+func sanitizeFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+}
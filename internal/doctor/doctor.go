@@ -0,0 +1,249 @@
+// internal/doctor/doctor.go
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// Permissions an audit needs
+const (
+	PermissionProjectsList   = "resourcemanager.projects.list"
+	PermissionServicesList   = "serviceusage.services.list"
+	PermissionTimeSeriesList = "monitoring.timeSeries.list"
+)
+
+// RequiredAPIs must be enabled on the quota project
+var RequiredAPIs = []string{
+	"cloudresourcemanager.googleapis.com",
+	"serviceusage.googleapis.com",
+	"monitoring.googleapis.com",
+}
+
+// roles grant the permissions with the least privileges
+var roles = map[string]string{
+	PermissionProjectsList:   "roles/browser",
+	PermissionServicesList:   "roles/serviceusage.serviceUsageViewer",
+	PermissionTimeSeriesList: "roles/monitoring.viewer",
+}
+
+type Status string
+
+const (
+	StatusPass Status = "PASS"
+	StatusFail Status = "FAIL"
+	StatusSkip Status = "SKIP"
+)
+
+// Result is the outcome of a single check
+type Result struct {
+	Check  string
+	Target string
+	Status Status
+	Detail string
+	Hint   string // Remediation for checks that did not pass
+}
+
+// Prober reads the permissions and APIs of the audited resources
+type Prober interface {
+	SampleProjects(ctx context.Context, n int, keep func(domain.Project) bool) ([]domain.Project, error)
+	TestProjectPermissions(ctx context.Context, projectID string, permissions []string) ([]string, error)
+	TestOrganizationPermissions(ctx context.Context, organizationID string, permissions []string) ([]string, error)
+	EnabledServices(ctx context.Context, projectID string, services []string) (map[string]bool, error)
+}
+
+// Options selects what the checks run against
+type Options struct {
+	Credentials  string                    // Describes the credentials in the results
+	Organization string                    // Organization to check; empty derives it from the sample
+	QuotaProject string                    // Project whose APIs are checked, configured or from the credentials; empty skips the check
+	SampleSize   int                       // Projects whose permissions are tested
+	Keep         func(domain.Project) bool // Selects the projects that would be audited
+}
+
+// Run checks that the credentials can list projects, that they hold the permissions an audit
+// needs on a sample of projects and on the organization, and that the required APIs are
+// enabled on the quota project
+func Run(ctx context.Context, prober Prober, opts Options) []Result {
+	var results []Result
+
+	sample, err := prober.SampleProjects(ctx, opts.SampleSize, opts.Keep)
+	switch {
+	case err != nil:
+		results = append(results, Result{
+			Check: "list projects", Target: opts.Credentials, Status: StatusFail, Detail: err.Error(),
+			Hint: "Check the credentials (gcloud auth application-default login, --credentials-file or " +
+				"--impersonate-service-account) and grant roles/browser on the organization",
+		})
+	case len(sample) == 0:
+		results = append(results, Result{
+			Check: "list projects", Target: opts.Credentials, Status: StatusFail, Detail: "no projects to audit are visible",
+			Hint: "Grant roles/browser on the organization or folders, or relax the project filters",
+		})
+	default:
+		results = append(results, Result{
+			Check: "list projects", Target: opts.Credentials, Status: StatusPass,
+			Detail: fmt.Sprintf("sampled %d projects", len(sample)),
+		})
+	}
+
+	for _, project := range sample {
+		results = append(results, checkPermissions(
+			"project permissions", "projects/"+project.ID, " or its folder or organization",
+			[]string{PermissionServicesList, PermissionTimeSeriesList},
+			func(permissions []string) ([]string, error) {
+				return prober.TestProjectPermissions(ctx, project.ID, permissions)
+			}))
+	}
+
+	results = append(results, checkOrganization(ctx, prober, opts.Organization, sample)...)
+	results = append(results, checkAPIs(ctx, prober, opts.QuotaProject))
+	return results
+}
+
+func checkOrganization(ctx context.Context, prober Prober, organization string, sample []domain.Project) []Result {
+	organizations := []string{organization}
+	if organization == "" {
+		organizations = sampleOrganizations(sample)
+	}
+	if len(organizations) == 0 {
+		return []Result{{
+			Check: "organization permissions", Target: "-", Status: StatusSkip,
+			Detail: "no organization is the direct parent of a sampled project",
+			Hint:   "Configure the organization under organizations in the config file to check it",
+		}}
+	}
+
+	var results []Result
+	for _, id := range organizations {
+		results = append(results, checkPermissions(
+			"organization permissions", "organizations/"+id, "",
+			[]string{PermissionProjectsList, PermissionServicesList, PermissionTimeSeriesList},
+			func(permissions []string) ([]string, error) {
+				return prober.TestOrganizationPermissions(ctx, id, permissions)
+			}))
+	}
+	return results
+}
+
+// sampleOrganizations returns the organizations that directly contain sampled projects
+func sampleOrganizations(sample []domain.Project) []string {
+	seen := make(map[string]bool)
+	var organizations []string
+	for _, project := range sample {
+		id, ok := strings.CutPrefix(project.Parent, "organizations/")
+		if ok && !seen[id] {
+			seen[id] = true
+			organizations = append(organizations, id)
+		}
+	}
+	return organizations
+}
+
+// checkPermissions tests permissions on a target. The scope extends the target in the remediation.
+func checkPermissions(check, target, scope string, permissions []string, test func([]string) ([]string, error)) Result {
+	granted, err := test(permissions)
+	if err != nil {
+		return Result{
+			Check: check, Target: target, Status: StatusFail, Detail: err.Error(),
+			Hint: "Grant roles/browser on " + target + " so that its permissions can be tested",
+		}
+	}
+
+	held := make(map[string]bool, len(granted))
+	for _, permission := range granted {
+		held[permission] = true
+	}
+
+	var missing, hints []string
+	for _, permission := range permissions {
+		if !held[permission] {
+			missing = append(missing, permission)
+			hints = append(hints, roles[permission])
+		}
+	}
+	if len(missing) > 0 {
+		return Result{
+			Check: check, Target: target, Status: StatusFail,
+			Detail: "missing " + strings.Join(missing, ", "),
+			Hint:   "Grant " + strings.Join(hints, ", ") + " on " + target + scope,
+		}
+	}
+	return Result{Check: check, Target: target, Status: StatusPass, Detail: strings.Join(permissions, ", ")}
+}
+
+func checkAPIs(ctx context.Context, prober Prober, quotaProject string) Result {
+	if quotaProject == "" {
+		return Result{
+			Check: "required APIs", Target: "-", Status: StatusSkip,
+			Detail: "no quota project configured or set in the credentials",
+			Hint:   "Set --quota-project to check the APIs billed for the audit",
+		}
+	}
+
+	target := "projects/" + quotaProject
+	enabled, err := prober.EnabledServices(ctx, quotaProject, RequiredAPIs)
+	if err != nil {
+		return Result{
+			Check: "required APIs", Target: target, Status: StatusFail, Detail: err.Error(),
+			Hint: "Grant roles/serviceusage.serviceUsageViewer on " + target,
+		}
+	}
+
+	var disabled []string
+	for _, api := range RequiredAPIs {
+		if !enabled[api] {
+			disabled = append(disabled, api)
+		}
+	}
+	if len(disabled) > 0 {
+		return Result{
+			Check: "required APIs", Target: target, Status: StatusFail,
+			Detail: "disabled " + strings.Join(disabled, ", "),
+			Hint:   fmt.Sprintf("gcloud services enable %s --project %s", strings.Join(disabled, " "), quotaProject),
+		}
+	}
+	return Result{Check: "required APIs", Target: target, Status: StatusPass, Detail: strings.Join(RequiredAPIs, ", ")}
+}
+
+// Failed returns the number of failed checks
+func Failed(results []Result) int {
+	failed := 0
+	for _, result := range results {
+		if result.Status == StatusFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteTable prints the results as a table followed by the remediation of the checks that did
+// not pass
+func WriteTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tTARGET\tRESULT\tDETAIL")
+	for _, result := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Check, result.Target, result.Status, result.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	header := false
+	for _, result := range results {
+		if result.Status == StatusPass || result.Hint == "" {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(w, "\nRemediation:")
+			header = true
+		}
+		fmt.Fprintf(w, "- %s %s (%s): %s\n", result.Status, result.Check, result.Target, result.Hint)
+	}
+	return nil
+}
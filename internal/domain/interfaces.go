@@ -22,6 +22,33 @@ type Reporter interface {
 	GenerateReport(report AuditReport) error
 }
 
+// IncrementalReporter writes a report while the audit runs, so that the services of a
+// project do not have to be kept until the end. Begin is called once the projects to audit
+// are known, AddProject once per project in completion order, and GenerateReport finishes
+// the report with the summary. When the audit fails instead, Abort discards the files of the
+// report and the reporter can Begin again; Abort does nothing when no report is under way.
+// Calls are never concurrent. A reporter that did not Begin writes the whole report in
+// GenerateReport, like any other Reporter.
+type IncrementalReporter interface {
+	Reporter
+	Begin(report AuditReport) error
+	AddProject(result ProjectResult) error
+	Abort()
+}
+
+// ProgressObserver follows an audit while it runs, e.g. to display its progress. Methods
+// may be called concurrently.
+type ProgressObserver interface {
+	AuditStarted(projects int)
+	ProjectStarted(projectID string)
+	ProjectFinished(projectID string, err error) // err is non-nil when the project was skipped
+}
+
+// Notifier sends a summary of a completed audit to an external channel
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
 // Auditor defines the main audit operation
 type Auditor interface {
 	Audit(ctx context.Context) (AuditReport, error)
@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -19,6 +21,8 @@ type Project struct {
 	ProjectNum int64             // Project number as string
 	Labels     map[string]string // Project labels
 	CreateTime time.Time         // Project creation time
+	Parent     string            // Parent resource (e.g., "folders/123" or "organizations/456")
+	FolderName string            // Display name of the parent folder, if resolved
 }
 
 // Service represents a GCP service and its state
@@ -41,6 +45,7 @@ type Usage struct {
 
 // AuditReport represents the final audit report
 type AuditReport struct {
+	Profile          string // Config file profile the audit ran with, empty when none was selected
 	StartTime        time.Time
 	GeneratedAt      time.Time
 	Period           time.Duration
@@ -49,6 +54,47 @@ type AuditReport struct {
 	SkippedProjects  map[string]error
 	Statistics       AuditStatistics
 	ProjectDurations map[string]time.Duration
+	PolicyViolations []PolicyViolation
+	Hygiene          map[string]HygieneScore // Hygiene score per project ID
+}
+
+// HygieneScore rates a project from 0 (neglected) to 100 (clean)
+type HygieneScore struct {
+	Score   float64
+	Factors []HygieneFactor
+}
+
+// HygieneFactor is one input of a hygiene score. The score loses Weight/sum(weights)*100
+// points scaled by Penalty.
+type HygieneFactor struct {
+	Name    string
+	Weight  float64
+	Penalty float64 // 0 (no issue) to 1 (full penalty)
+}
+
+// RunID identifies the audit run; it is also the name of the run's report directory. It
+// derives from the start time, so that streamed reports can be written before the run ends.
+func (r AuditReport) RunID() string {
+	return r.StartTime.Format("20060102_150405")
+}
+
+// ProjectResult is the outcome of auditing one project, handed to incremental reporters as
+// soon as the project completes
+type ProjectResult struct {
+	Project          Project
+	Services         []Service // Nil when the project was skipped
+	Err              error     // Why the project was skipped, nil on success
+	Duration         time.Duration
+	Hygiene          HygieneScore
+	PolicyViolations []PolicyViolation
+}
+
+// PolicyViolation is a project that breaks one of the configured policy rules
+type PolicyViolation struct {
+	ProjectID string
+	Rule      string // Rule name, e.g. "required-label"
+	Service   string // Offending service, empty for project-level rules
+	Message   string
 }
 
 // AuditStatistics contains summary statistics
@@ -60,6 +106,21 @@ type AuditStatistics struct {
 	UniqueServices      int
 	ServicesWithNoUsage int
 	ServiceDetails      []*ServiceDetail
+	GroupBy             GroupBy        // Grouping of the rollups; zero when grouping is disabled
+	Groups              []*GroupRollup // Rollups sorted by group name
+	Ungrouped           *GroupRollup   // Projects missing the grouping label or folder; nil if there are none
+}
+
+// GroupRollup aggregates the projects that share a group, e.g. the same team label
+type GroupRollup struct {
+	ID               string   // Label value or folder ID the projects are grouped by
+	Name             string   // Label value or folder display name, the ID if the name is unknown
+	Projects         []string // Sorted project IDs, including skipped projects
+	SkippedProjects  int
+	EnabledServices  int
+	ActiveServices   int // Services with requests in the period
+	InactiveServices int // Services without requests in the period
+	TotalRequests    int64
 }
 
 type ServiceStatistics struct {
@@ -77,3 +138,109 @@ type ServiceDetail struct {
 	TotalRequests int64
 	EnabledIn     []string
 }
+
+// GroupBy selects how projects are grouped into rollups: by the value of a label or by
+// the parent folder
+type GroupBy struct {
+	Label  string // Label key when grouping by label
+	Folder bool   // Group by parent folder
+}
+
+// ParseGroupBy parses "label:<key>" or "folder". An empty value disables grouping.
+func ParseGroupBy(value string) (GroupBy, error) {
+	switch {
+	case value == "":
+		return GroupBy{}, nil
+	case value == "folder":
+		return GroupBy{Folder: true}, nil
+	case strings.HasPrefix(value, "label:") && len(value) > len("label:"):
+		return GroupBy{Label: strings.TrimPrefix(value, "label:")}, nil
+	}
+	return GroupBy{}, fmt.Errorf("invalid group by %q. Must be label:<key> or folder", value)
+}
+
+// IsZero reports whether grouping is disabled
+func (g GroupBy) IsZero() bool {
+	return g.Label == "" && !g.Folder
+}
+
+func (g GroupBy) String() string {
+	switch {
+	case g.Folder:
+		return "folder"
+	case g.Label != "":
+		return "label:" + g.Label
+	}
+	return ""
+}
+
+// Key returns the group of a project. The second value is false when the project has no
+// value for the grouping label, or when grouping by folder and the project is not in a folder.
+func (g GroupBy) Key(project Project) (string, bool) {
+	if g.Folder {
+		folder := strings.TrimPrefix(project.Parent, "folders/")
+		return folder, folder != project.Parent && folder != ""
+	}
+	value := project.Labels[g.Label]
+	return value, value != ""
+}
+
+// Name returns the display name of the group a project is in, the group key when grouping by
+// label or when the folder name was not resolved
+func (g GroupBy) Name(project Project) string {
+	key, _ := g.Key(project)
+	if g.Folder && project.FolderName != "" {
+		return project.FolderName
+	}
+	return key
+}
+
+// Severity ranks audit notifications so channels can ignore routine runs
+type Severity int
+
+const (
+	SeverityInfo     Severity = iota // Audit completed without notable changes
+	SeverityWarning                  // Services became unused or were newly enabled
+	SeverityCritical                 // Projects were skipped during the audit
+)
+
+// ServiceChange identifies a service in a project that changed since the previous audit
+type ServiceChange struct {
+	ProjectID string
+	Service   string
+}
+
+// Notification summarizes a completed audit and what changed since the previous one
+type Notification struct {
+	Report       AuditReport
+	Severity     Severity
+	HasBaseline  bool            // False on the first audit, when there is nothing to compare against
+	NewlyUnused  []ServiceChange // Active in the previous audit, no requests in this one
+	NewlyEnabled []ServiceChange // Enabled since the previous audit
+	ReportURL    string
+}
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	}
+	return "unknown"
+}
+
+// ParseSeverity converts a severity name (info, warning, critical) to a Severity
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(name) {
+	case "", "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "critical":
+		return SeverityCritical, nil
+	}
+	return SeverityInfo, fmt.Errorf("invalid severity %q. Must be one of: info, warning, critical", name)
+}
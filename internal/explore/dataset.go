@@ -0,0 +1,279 @@
+// internal/explore/dataset.go
+package explore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/report"
+)
+
+// Dataset is the result of one audit run as browsed by the explorer
+type Dataset struct {
+	RunID    string
+	Dir      string
+	Projects []*Project        // Sorted by ID
+	Services []*ServiceSummary // Sorted by name
+	Detailed bool              // Loaded from services.ndjson, with usage errors and skipped projects
+}
+
+// Project is an audited project and its enabled services
+type Project struct {
+	ID         string
+	Name       string
+	Labels     map[string]string
+	Skipped    bool
+	Error      string   // Why the project was skipped
+	Hygiene    *float64 // Nil when the run has no JSON report
+	Services   []*Service
+	Active     int // Services with requests in the period
+	Unused     int // Services whose usage was read and had no requests
+	Unreadable int // Services whose usage could not be read
+}
+
+// Service is a service enabled in a project
+type Service struct {
+	Name     string
+	Title    string
+	State    string
+	Status   domain.UsageStatus
+	Requests int64
+	Error    string
+}
+
+// Unused reports whether the usage of the service was read and it had no requests
+func (s *Service) Unused() bool {
+	return s.Status == domain.UsageStatusSuccess && s.Requests == 0
+}
+
+// ServiceSummary is a service across the projects that enable it
+type ServiceSummary struct {
+	Name          string
+	Title         string
+	Enabled       []Enablement // Sorted by project ID
+	ActiveIn      int
+	UnusedIn      int
+	TotalRequests int64
+}
+
+// Enablement is a service enabled in one project
+type Enablement struct {
+	Project *Project
+	Service *Service
+}
+
+// LatestRun returns the most recent run directory in outputDir that the explorer can read
+func LatestRun(outputDir string) (string, error) {
+	var runs []string
+	for _, name := range []string{"services.ndjson", "projects.json"} {
+		matches, err := filepath.Glob(filepath.Join(outputDir, "*", name))
+		if err != nil {
+			return "", fmt.Errorf("failed to search for reports: %w", err)
+		}
+		for _, match := range matches {
+			runs = append(runs, filepath.Dir(match))
+		}
+	}
+	if len(runs) == 0 {
+		return "", fmt.Errorf("no NDJSON or JSON report found in %s", outputDir)
+	}
+
+	// Run directories are timestamps, so the lexically last one is the latest
+	sort.Strings(runs)
+	return runs[len(runs)-1], nil
+}
+
+// Load reads the reports of a run directory. The NDJSON report is preferred, since only it
+// records usage errors and skipped projects; hygiene scores come from the JSON report.
+func Load(dir string) (*Dataset, error) {
+	dataset := &Dataset{
+		RunID: filepath.Base(dir),
+		Dir:   dir,
+	}
+	projects := make(map[string]*Project)
+
+	err := loadNDJSON(filepath.Join(dir, "services.ndjson"), projects)
+	switch {
+	case err == nil:
+		dataset.Detailed = true
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	err = loadJSON(filepath.Join(dir, "projects.json"), projects, !dataset.Detailed)
+	switch {
+	case err == nil:
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	case !dataset.Detailed:
+		return nil, fmt.Errorf("no services.ndjson or projects.json found")
+	}
+
+	dataset.index(projects)
+	return dataset, nil
+}
+
+func loadNDJSON(path string, projects map[string]*Project) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record report.NDJSONRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("invalid record on line %d of %s: %w", line, path, err)
+		}
+
+		project := projects[record.ProjectID]
+		if project == nil {
+			project = &Project{
+				ID:     record.ProjectID,
+				Name:   record.ProjectName,
+				Labels: make(map[string]string, len(record.ProjectLabels)),
+			}
+			for _, label := range record.ProjectLabels {
+				project.Labels[label.Key] = label.Value
+			}
+			projects[record.ProjectID] = project
+		}
+
+		if record.ProjectSkipped {
+			project.Skipped = true
+			project.Error = value(record.ProjectError)
+			continue
+		}
+		if record.ServiceName == nil {
+			continue
+		}
+
+		service := &Service{
+			Name:   *record.ServiceName,
+			Title:  value(record.ServiceTitle),
+			State:  value(record.ServiceState),
+			Status: domain.UsageStatus(value(record.UsageStatus)),
+			Error:  value(record.UsageError),
+		}
+		if record.RequestCount != nil {
+			service.Requests = *record.RequestCount
+		}
+		project.Services = append(project.Services, service)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadJSON adds the hygiene scores of projects.json, and the services too when withServices
+// is set
+func loadJSON(path string, projects map[string]*Project, withServices bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	projectsReport, err := report.ParseProjectsReport(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for _, projectReport := range projectsReport.Projects {
+		project := projects[projectReport.ProjectID]
+		if project == nil {
+			if !withServices {
+				continue
+			}
+			project = &Project{ID: projectReport.ProjectID}
+			projects[project.ID] = project
+		}
+		if projectReport.Hygiene != nil {
+			score := projectReport.Hygiene.Score
+			project.Hygiene = &score
+		}
+		if !withServices {
+			continue
+		}
+
+		for _, s := range projectReport.Services {
+			project.Services = append(project.Services, &Service{
+				Name:     s.Name,
+				Title:    s.Title,
+				State:    s.State,
+				Status:   domain.UsageStatus(s.UsageStatus),
+				Requests: s.RequestCount,
+			})
+		}
+	}
+	return nil
+}
+
+// index sorts the projects and builds the service-centric view
+func (d *Dataset) index(projects map[string]*Project) {
+	services := make(map[string]*ServiceSummary)
+
+	for _, project := range projects {
+		sort.Slice(project.Services, func(i, j int) bool {
+			return project.Services[i].Name < project.Services[j].Name
+		})
+
+		for _, service := range project.Services {
+			switch {
+			case service.Status == domain.UsageStatusSuccess && service.Requests > 0:
+				project.Active++
+			case service.Unused():
+				project.Unused++
+			default:
+				project.Unreadable++
+			}
+
+			summary := services[service.Name]
+			if summary == nil {
+				summary = &ServiceSummary{Name: service.Name}
+				services[service.Name] = summary
+			}
+			if summary.Title == "" {
+				summary.Title = service.Title
+			}
+			summary.Enabled = append(summary.Enabled, Enablement{Project: project, Service: service})
+			summary.TotalRequests += service.Requests
+			if service.Requests > 0 {
+				summary.ActiveIn++
+			}
+			if service.Unused() {
+				summary.UnusedIn++
+			}
+		}
+
+		d.Projects = append(d.Projects, project)
+	}
+	sort.Slice(d.Projects, func(i, j int) bool {
+		return d.Projects[i].ID < d.Projects[j].ID
+	})
+
+	for _, summary := range services {
+		sort.Slice(summary.Enabled, func(i, j int) bool {
+			return summary.Enabled[i].Project.ID < summary.Enabled[j].Project.ID
+		})
+		d.Services = append(d.Services, summary)
+	}
+	sort.Slice(d.Services, func(i, j int) bool {
+		return d.Services[i].Name < d.Services[j].Name
+	})
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
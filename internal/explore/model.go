@@ -0,0 +1,719 @@
+// internal/explore/model.go
+package explore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/pkg/metrics"
+)
+
+type view int
+
+const (
+	viewProjects        view = iota // All projects
+	viewServices                    // All services, with the number of projects enabling them
+	viewProjectServices             // The services of one project
+	viewServiceProjects             // The projects enabling one service
+)
+
+// chromeLines is the number of lines around the table: title, column header, detail and
+// status lines
+const chromeLines = 4
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	headerStyle   = lipgloss.NewStyle().Bold(true).Underline(true)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	markedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	faintStyle    = lipgloss.NewStyle().Faint(true)
+)
+
+// row is an entry of a list: a project, a service across projects, or a service in a project
+type row struct {
+	project *Project
+	service *Service
+	summary *ServiceSummary
+}
+
+// frame is a list on the navigation stack, with its own cursor, filter and sort order
+type frame struct {
+	view    view
+	project *Project        // Project of viewProjectServices
+	summary *ServiceSummary // Service of viewServiceProjects
+	cursor  int
+	offset  int
+	filter  string
+	sort    int
+	rows    []row // Filtered and sorted; rebuilt by refresh
+}
+
+type column struct {
+	title string
+	width int // Zero takes the remaining width
+	right bool
+	cell  func(m *Model, r row) string
+}
+
+type sortOrder struct {
+	name string
+	less func(a, b row) bool
+}
+
+// Model is the explorer terminal UI over the dataset of one audit run
+type Model struct {
+	dataset  *Dataset
+	services map[string]*ServiceSummary
+	plan     *Plan
+	planPath string
+
+	stack     []*frame // Navigation stack; the last frame is shown
+	filtering bool     // The filter of the current frame is being typed
+	help      bool
+	quitting  bool // Quit was requested with unsaved marks
+	status    string
+	width     int
+	height    int
+}
+
+// NewModel returns the explorer over dataset. Marked services are added to plan, which is
+// saved to planPath.
+func NewModel(dataset *Dataset, plan *Plan, planPath string) *Model {
+	m := &Model{
+		dataset:  dataset,
+		services: make(map[string]*ServiceSummary, len(dataset.Services)),
+		plan:     plan,
+		planPath: planPath,
+		width:    80,
+		height:   24,
+	}
+	for _, summary := range dataset.Services {
+		m.services[summary.Name] = summary
+	}
+	if !dataset.Detailed {
+		m.status = "No NDJSON report in this run: usage errors and skipped projects are not shown"
+	}
+	m.push(&frame{view: viewProjects})
+	return m
+}
+
+func (m *Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.scroll()
+		return m, nil
+	case tea.KeyMsg:
+		if m.filtering {
+			m.updateFilter(msg)
+			return m, nil
+		}
+		// Messages are shown until the next key
+		m.status = ""
+		return m.updateKey(msg)
+	}
+	return m, nil
+}
+
+func (m *Model) updateKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	if key != "q" {
+		m.quitting = false
+	}
+	if m.help && key != "ctrl+c" && key != "q" {
+		m.help = false
+		return m, nil
+	}
+
+	f := m.current()
+	switch key {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "q":
+		if m.plan.Dirty() && !m.quitting {
+			m.quitting = true
+			m.status = "The remediation plan has unsaved changes: press w to save, q again to quit"
+			return m, nil
+		}
+		return m, tea.Quit
+	case "?":
+		m.help = true
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "pgup", "ctrl+b":
+		m.move(-m.pageSize())
+	case "pgdown", "ctrl+f":
+		m.move(m.pageSize())
+	case "home", "g":
+		m.move(-len(f.rows))
+	case "end", "G":
+		m.move(len(f.rows))
+	case "tab":
+		if m.stack[0].view == viewProjects {
+			m.reset(&frame{view: viewServices})
+		} else {
+			m.reset(&frame{view: viewProjects})
+		}
+	case "enter", "right", "l":
+		m.drillDown()
+	case "esc", "left", "h", "backspace":
+		if f.filter != "" && key == "esc" {
+			f.filter = ""
+			m.refresh()
+		} else if len(m.stack) > 1 {
+			m.stack = m.stack[:len(m.stack)-1]
+		}
+	case "/":
+		m.filtering = true
+	case "s":
+		f.sort = (f.sort + 1) % len(m.sortOrders(f.view))
+		m.refresh()
+	case " ", "m":
+		m.toggleMark()
+	case "a":
+		m.markUnused()
+	case "w":
+		m.save()
+	}
+	return m, nil
+}
+
+// updateFilter edits the filter of the current frame; the list is filtered as it is typed
+func (m *Model) updateFilter(msg tea.KeyMsg) {
+	f := m.current()
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.filtering = false
+	case tea.KeyEsc:
+		m.filtering = false
+		f.filter = ""
+	case tea.KeyBackspace:
+		if runes := []rune(f.filter); len(runes) > 0 {
+			f.filter = string(runes[:len(runes)-1])
+		}
+	case tea.KeyCtrlU:
+		f.filter = ""
+	case tea.KeyCtrlC:
+		m.filtering = false
+	case tea.KeyRunes, tea.KeySpace:
+		f.filter += string(msg.Runes)
+	default:
+		return
+	}
+	m.refresh()
+}
+
+func (m *Model) current() *frame {
+	return m.stack[len(m.stack)-1]
+}
+
+func (m *Model) push(f *frame) {
+	m.stack = append(m.stack, f)
+	m.refresh()
+}
+
+// reset replaces the navigation stack with a top-level list
+func (m *Model) reset(f *frame) {
+	m.stack = nil
+	m.push(f)
+}
+
+func (m *Model) selected() (row, bool) {
+	f := m.current()
+	if f.cursor < 0 || f.cursor >= len(f.rows) {
+		return row{}, false
+	}
+	return f.rows[f.cursor], true
+}
+
+// drillDown opens the services of the selected project, or the projects of the selected service
+func (m *Model) drillDown() {
+	r, ok := m.selected()
+	if !ok {
+		return
+	}
+	switch m.current().view {
+	case viewProjects, viewServiceProjects:
+		m.push(&frame{view: viewProjectServices, project: r.project})
+	case viewServices:
+		m.push(&frame{view: viewServiceProjects, summary: r.summary})
+	case viewProjectServices:
+		m.push(&frame{view: viewServiceProjects, summary: m.services[r.service.Name]})
+	}
+}
+
+func (m *Model) move(delta int) {
+	f := m.current()
+	f.cursor = max(0, min(f.cursor+delta, len(f.rows)-1))
+	m.scroll()
+}
+
+// scroll keeps the cursor of the current frame within the visible rows
+func (m *Model) scroll() {
+	f := m.current()
+	page := m.pageSize()
+	if f.cursor < f.offset {
+		f.offset = f.cursor
+	}
+	if f.cursor >= f.offset+page {
+		f.offset = f.cursor - page + 1
+	}
+	f.offset = max(0, min(f.offset, len(f.rows)-page))
+}
+
+func (m *Model) pageSize() int {
+	return max(1, m.height-chromeLines)
+}
+
+// refresh rebuilds the rows of the current frame from its filter and sort order, keeping
+// the cursor on the selected entry when it is still listed
+func (m *Model) refresh() {
+	f := m.current()
+	var previous row
+	if f.cursor < len(f.rows) {
+		previous = f.rows[f.cursor]
+	}
+
+	filter := strings.ToLower(f.filter)
+	f.rows = f.rows[:0]
+	for _, r := range m.allRows(f) {
+		if filter == "" || strings.Contains(m.filterText(f.view, r), filter) {
+			f.rows = append(f.rows, r)
+		}
+	}
+
+	less := m.sortOrders(f.view)[f.sort].less
+	sort.SliceStable(f.rows, func(i, j int) bool {
+		return less(f.rows[i], f.rows[j])
+	})
+
+	f.cursor = 0
+	for i, r := range f.rows {
+		if r == previous {
+			f.cursor = i
+			break
+		}
+	}
+	m.scroll()
+}
+
+func (m *Model) allRows(f *frame) []row {
+	var rows []row
+	switch f.view {
+	case viewProjects:
+		for _, project := range m.dataset.Projects {
+			rows = append(rows, row{project: project})
+		}
+	case viewServices:
+		for _, summary := range m.dataset.Services {
+			rows = append(rows, row{summary: summary})
+		}
+	case viewProjectServices:
+		for _, service := range f.project.Services {
+			rows = append(rows, row{project: f.project, service: service, summary: m.services[service.Name]})
+		}
+	case viewServiceProjects:
+		for _, enablement := range f.summary.Enabled {
+			rows = append(rows, row{project: enablement.Project, service: enablement.Service, summary: f.summary})
+		}
+	}
+	return rows
+}
+
+// filterText is the lower-case text the filter is matched against. Projects match on their
+// ID, name and labels as key=value.
+func (m *Model) filterText(v view, r row) string {
+	var parts []string
+	switch v {
+	case viewProjects, viewServiceProjects:
+		parts = append(parts, r.project.ID, r.project.Name)
+		for key, value := range r.project.Labels {
+			parts = append(parts, key+"="+value)
+		}
+	case viewServices:
+		parts = append(parts, r.summary.Name, r.summary.Title)
+	case viewProjectServices:
+		parts = append(parts, r.service.Name, r.service.Title)
+	}
+	if r.service != nil {
+		parts = append(parts, string(r.service.Status))
+	}
+	return strings.ToLower(strings.Join(parts, " "))
+}
+
+// toggleMark marks or unmarks the selected service of a project for remediation
+func (m *Model) toggleMark() {
+	r, ok := m.selected()
+	if !ok {
+		return
+	}
+	if r.service == nil {
+		m.status = "Open a project or a service to mark services (enter)"
+		return
+	}
+	m.plan.Toggle(r.project, r.service)
+	if m.plan.Marked(r.project.ID, r.service.Name) {
+		m.status = fmt.Sprintf("Marked %s in %s", r.service.Name, r.project.ID)
+	} else {
+		m.status = fmt.Sprintf("Unmarked %s in %s", r.service.Name, r.project.ID)
+	}
+	m.move(1)
+}
+
+// markUnused marks every unused service in the current list, or of the selected project or
+// service in the top-level lists
+func (m *Model) markUnused() {
+	var enablements []Enablement
+	f := m.current()
+	switch f.view {
+	case viewProjects, viewServices:
+		r, ok := m.selected()
+		if !ok {
+			return
+		}
+		if r.project != nil {
+			for _, service := range r.project.Services {
+				enablements = append(enablements, Enablement{Project: r.project, Service: service})
+			}
+		} else {
+			enablements = r.summary.Enabled
+		}
+	default:
+		for _, r := range f.rows {
+			enablements = append(enablements, Enablement{Project: r.project, Service: r.service})
+		}
+	}
+
+	marked := 0
+	for _, enablement := range enablements {
+		if enablement.Service.Unused() && !m.plan.Marked(enablement.Project.ID, enablement.Service.Name) {
+			m.plan.Set(enablement.Project, enablement.Service, true)
+			marked++
+		}
+	}
+	m.status = fmt.Sprintf("Marked %d unused services", marked)
+}
+
+func (m *Model) save() {
+	script, err := m.plan.Save(m.planPath)
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.status = fmt.Sprintf("Saved %d services to %s and %s", m.plan.Len(), m.planPath, script)
+}
+
+func (m *Model) View() string {
+	if m.help {
+		return m.helpView()
+	}
+
+	f := m.current()
+	columns := m.columns(f.view)
+	widths := m.columnWidths(columns)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(fit(m.title(), m.width)))
+	b.WriteString("\n")
+
+	var header []string
+	for i, c := range columns {
+		header = append(header, pad(c.title, widths[i], c.right))
+	}
+	b.WriteString(headerStyle.Render(fit(strings.Join(header, " "), m.width)))
+	b.WriteString("\n")
+
+	page := m.pageSize()
+	for i := f.offset; i < f.offset+page; i++ {
+		if i < len(f.rows) {
+			b.WriteString(m.renderRow(f, i, columns, widths))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(fit(m.detail(), m.width))
+	b.WriteString("\n")
+	b.WriteString(m.statusLine())
+	return b.String()
+}
+
+func (m *Model) title() string {
+	f := m.current()
+	var name string
+	switch f.view {
+	case viewProjects:
+		name = fmt.Sprintf("Projects (%d)", len(f.rows))
+	case viewServices:
+		name = fmt.Sprintf("Services (%d)", len(f.rows))
+	case viewProjectServices:
+		name = fmt.Sprintf("Services of %s (%d)", f.project.ID, len(f.rows))
+	case viewServiceProjects:
+		name = fmt.Sprintf("Projects enabling %s (%d)", f.summary.Name, len(f.rows))
+	}
+
+	title := fmt.Sprintf("Run %s │ %s │ sort: %s", m.dataset.RunID, name, m.sortOrders(f.view)[f.sort].name)
+	if f.filter != "" || m.filtering {
+		title += fmt.Sprintf(" │ filter: %s", f.filter)
+	}
+	plan := fmt.Sprintf(" │ plan: %d marked", m.plan.Len())
+	if m.plan.Dirty() {
+		plan += " (unsaved)"
+	}
+	return title + plan
+}
+
+func (m *Model) renderRow(f *frame, i int, columns []column, widths []int) string {
+	r := f.rows[i]
+	cells := make([]string, len(columns))
+	for j, c := range columns {
+		cells[j] = pad(c.cell(m, r), widths[j], c.right)
+	}
+	line := fit(strings.Join(cells, " "), m.width)
+
+	switch {
+	case i == f.cursor:
+		return selectedStyle.Render(pad(line, m.width, false))
+	case r.service != nil && m.plan.Marked(r.project.ID, r.service.Name):
+		return markedStyle.Render(line)
+	case r.project != nil && r.service == nil && r.project.Skipped,
+		r.service != nil && r.service.Error != "":
+		return errorStyle.Render(line)
+	}
+	return line
+}
+
+// detail describes the selected entry in full, since cells are truncated
+func (m *Model) detail() string {
+	r, ok := m.selected()
+	if !ok {
+		return "No entries match the filter"
+	}
+	switch {
+	case r.service != nil && r.service.Error != "":
+		return fmt.Sprintf("%s in %s: %s", r.service.Name, r.project.ID, r.service.Error)
+	case r.service != nil:
+		return fmt.Sprintf("%s (%s) in %s: %s, %s requests", r.service.Name, r.service.Title,
+			r.project.ID, r.service.State, metrics.FormatNumber(r.service.Requests))
+	case r.project != nil && r.project.Skipped:
+		return fmt.Sprintf("%s skipped: %s", r.project.ID, r.project.Error)
+	case r.project != nil:
+		labels := make([]string, 0, len(r.project.Labels))
+		for key, value := range r.project.Labels {
+			labels = append(labels, key+"="+value)
+		}
+		sort.Strings(labels)
+		detail := r.project.ID
+		if r.project.Name != "" && r.project.Name != r.project.ID {
+			detail += " (" + r.project.Name + ")"
+		}
+		if len(labels) == 0 {
+			return detail + ": no labels"
+		}
+		return detail + ": " + strings.Join(labels, ", ")
+	default:
+		return fmt.Sprintf("%s (%s): enabled in %d projects, unused in %d", r.summary.Name,
+			r.summary.Title, len(r.summary.Enabled), r.summary.UnusedIn)
+	}
+}
+
+func (m *Model) statusLine() string {
+	if m.filtering {
+		return fmt.Sprintf("/%s█  (enter to apply, esc to clear)", m.current().filter)
+	}
+	if m.status != "" {
+		return fit(m.status, m.width)
+	}
+	return faintStyle.Render(fit("↑↓ move  enter open  esc back  tab projects/services  / filter  s sort  space mark  a mark unused  w save  ? help  q quit", m.width))
+}
+
+func (m *Model) helpView() string {
+	return `Keys
+
+  ↑ ↓ k j          Move the cursor
+  pgup pgdn        Move by a page
+  g G              Go to the first or last entry
+  enter → l        Open the services of a project, or the projects enabling a service
+  esc ← h          Clear the filter, then go back
+  tab              Switch between the project list and the service list
+  /                Filter by ID, name, label (key=value) or usage status
+  s                Cycle the sort order
+  space m          Mark or unmark the selected service of a project for remediation
+  a                Mark every unused service in the list, or of the selected project or service
+  w                Save the remediation plan (JSON and gcloud script)
+  q                Quit
+  ?                Show this help
+
+Press any key to return`
+}
+
+// columnWidths gives the flexible column the width left over by the fixed columns
+func (m *Model) columnWidths(columns []column) []int {
+	widths := make([]int, len(columns))
+	used := 0
+	for i, c := range columns {
+		widths[i] = c.width
+		used += c.width + 1
+	}
+	for i, c := range columns {
+		if c.width == 0 {
+			widths[i] = max(20, m.width-used)
+		}
+	}
+	return widths
+}
+
+func (m *Model) mark(r row) string {
+	if m.plan.Marked(r.project.ID, r.service.Name) {
+		return "✓"
+	}
+	return ""
+}
+
+// markedCount is the number of marked services of a project
+func (m *Model) markedCount(project *Project) int {
+	count := 0
+	for _, service := range project.Services {
+		if m.plan.Marked(project.ID, service.Name) {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *Model) columns(v view) []column {
+	switch v {
+	case viewProjects:
+		return []column{
+			{title: "Project", cell: func(m *Model, r row) string { return r.project.ID }},
+			{title: "Services", width: 8, right: true, cell: func(m *Model, r row) string { return fmt.Sprint(len(r.project.Services)) }},
+			{title: "Active", width: 7, right: true, cell: func(m *Model, r row) string { return fmt.Sprint(r.project.Active) }},
+			{title: "Unused", width: 7, right: true, cell: func(m *Model, r row) string { return fmt.Sprint(r.project.Unused) }},
+			{title: "Errors", width: 7, right: true, cell: func(m *Model, r row) string { return fmt.Sprint(r.project.Unreadable) }},
+			{title: "Hygiene", width: 7, right: true, cell: func(m *Model, r row) string { return hygiene(r.project) }},
+			{title: "Marked", width: 6, right: true, cell: func(m *Model, r row) string { return fmt.Sprint(m.markedCount(r.project)) }},
+			{title: "Status", width: 8, cell: func(m *Model, r row) string { return projectStatus(r.project) }},
+		}
+	case viewServices:
+		return []column{
+			{title: "Service", cell: func(m *Model, r row) string { return r.summary.Name }},
+			{title: "Projects", width: 8, right: true, cell: func(m *Model, r row) string { return fmt.Sprint(len(r.summary.Enabled)) }},
+			{title: "Active", width: 7, right: true, cell: func(m *Model, r row) string { return fmt.Sprint(r.summary.ActiveIn) }},
+			{title: "Unused", width: 7, right: true, cell: func(m *Model, r row) string { return fmt.Sprint(r.summary.UnusedIn) }},
+			{title: "Requests", width: 14, right: true, cell: func(m *Model, r row) string { return metrics.FormatNumber(r.summary.TotalRequests) }},
+		}
+	case viewProjectServices:
+		return []column{
+			{title: "", width: 1, cell: (*Model).mark},
+			{title: "Service", width: 40, cell: func(m *Model, r row) string { return r.service.Name }},
+			{title: "Status", width: 9, cell: func(m *Model, r row) string { return string(r.service.Status) }},
+			{title: "Requests", width: 14, right: true, cell: func(m *Model, r row) string { return metrics.FormatNumber(r.service.Requests) }},
+			{title: "Title / error", cell: serviceNote},
+		}
+	default:
+		return []column{
+			{title: "", width: 1, cell: (*Model).mark},
+			{title: "Project", width: 40, cell: func(m *Model, r row) string { return r.project.ID }},
+			{title: "Status", width: 9, cell: func(m *Model, r row) string { return string(r.service.Status) }},
+			{title: "Requests", width: 14, right: true, cell: func(m *Model, r row) string { return metrics.FormatNumber(r.service.Requests) }},
+			{title: "Error", cell: func(m *Model, r row) string { return r.service.Error }},
+		}
+	}
+}
+
+func (m *Model) sortOrders(v view) []sortOrder {
+	switch v {
+	case viewProjects:
+		return []sortOrder{
+			{"project", func(a, b row) bool { return a.project.ID < b.project.ID }},
+			{"hygiene", func(a, b row) bool { return hygieneValue(a.project) < hygieneValue(b.project) }},
+			{"unused services", func(a, b row) bool { return a.project.Unused > b.project.Unused }},
+			{"services", func(a, b row) bool { return len(a.project.Services) > len(b.project.Services) }},
+			{"errors", func(a, b row) bool { return a.project.Unreadable > b.project.Unreadable }},
+		}
+	case viewServices:
+		return []sortOrder{
+			{"service", func(a, b row) bool { return a.summary.Name < b.summary.Name }},
+			{"projects", func(a, b row) bool { return len(a.summary.Enabled) > len(b.summary.Enabled) }},
+			{"unused in", func(a, b row) bool { return a.summary.UnusedIn > b.summary.UnusedIn }},
+			{"requests", func(a, b row) bool { return a.summary.TotalRequests > b.summary.TotalRequests }},
+		}
+	case viewProjectServices:
+		return []sortOrder{
+			{"service", func(a, b row) bool { return a.service.Name < b.service.Name }},
+			{"requests", func(a, b row) bool { return a.service.Requests < b.service.Requests }},
+			{"status", func(a, b row) bool { return a.service.Status < b.service.Status }},
+		}
+	default:
+		return []sortOrder{
+			{"project", func(a, b row) bool { return a.project.ID < b.project.ID }},
+			{"requests", func(a, b row) bool { return a.service.Requests < b.service.Requests }},
+			{"status", func(a, b row) bool { return a.service.Status < b.service.Status }},
+		}
+	}
+}
+
+func projectStatus(project *Project) string {
+	if project.Skipped {
+		return "SKIPPED"
+	}
+	return ""
+}
+
+func hygiene(project *Project) string {
+	if project.Hygiene == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", *project.Hygiene)
+}
+
+// hygieneValue sorts projects without a score last
+func hygieneValue(project *Project) float64 {
+	if project.Hygiene == nil {
+		return 101
+	}
+	return *project.Hygiene
+}
+
+// serviceNote is the usage error of a service, or its title when the usage was read
+func serviceNote(m *Model, r row) string {
+	if r.service.Error != "" || r.service.Status != domain.UsageStatusSuccess {
+		return r.service.Error
+	}
+	return r.service.Title
+}
+
+// fit truncates s to width runes
+func fit(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// pad truncates or pads s to exactly width runes
+func pad(s string, width int, right bool) string {
+	s = fit(s, width)
+	padding := strings.Repeat(" ", max(0, width-len([]rune(s))))
+	if right {
+		return padding + s
+	}
+	return s + padding
+}
@@ -0,0 +1,169 @@
+// internal/explore/plan.go
+package explore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PlanFileName is the name of the remediation plan written into the run directory by default
+const PlanFileName = "remediation_plan.json"
+
+// Plan is the set of services marked for remediation while exploring a run
+type Plan struct {
+	RunID     string     `json:"runId"`
+	UpdatedAt string     `json:"updatedAt"`
+	Items     []PlanItem `json:"items"`
+
+	marked map[planKey]bool
+	dirty  bool
+}
+
+// PlanItem is a service to disable in a project
+type PlanItem struct {
+	ProjectID    string `json:"projectId"`
+	Service      string `json:"service"`
+	RequestCount int64  `json:"requestCount"`
+	UsageStatus  string `json:"usageStatus,omitempty"`
+}
+
+type planKey struct {
+	projectID string
+	service   string
+}
+
+// LoadPlan reads the plan at path, or returns an empty plan for runID if it does not exist
+func LoadPlan(path, runID string) (*Plan, error) {
+	plan := &Plan{RunID: runID, marked: make(map[planKey]bool)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return plan, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remediation plan: %w", err)
+	}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, fmt.Errorf("failed to parse remediation plan %s: %w", path, err)
+	}
+
+	for _, item := range plan.Items {
+		plan.marked[planKey{item.ProjectID, item.Service}] = true
+	}
+	return plan, nil
+}
+
+// Marked reports whether the service is marked in the project
+func (p *Plan) Marked(projectID, service string) bool {
+	return p.marked[planKey{projectID, service}]
+}
+
+// Len returns the number of marked services
+func (p *Plan) Len() int {
+	return len(p.Items)
+}
+
+// Dirty reports whether the plan changed since it was loaded or saved
+func (p *Plan) Dirty() bool {
+	return p.dirty
+}
+
+// Toggle marks the service in the project, or unmarks it if it is marked
+func (p *Plan) Toggle(project *Project, service *Service) {
+	p.Set(project, service, !p.Marked(project.ID, service.Name))
+}
+
+// Set marks or unmarks the service in the project
+func (p *Plan) Set(project *Project, service *Service, marked bool) {
+	key := planKey{project.ID, service.Name}
+	if p.marked[key] == marked {
+		return
+	}
+	p.dirty = true
+
+	if !marked {
+		delete(p.marked, key)
+		for i, item := range p.Items {
+			if item.ProjectID == project.ID && item.Service == service.Name {
+				p.Items = append(p.Items[:i], p.Items[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+
+	p.marked[key] = true
+	p.Items = append(p.Items, PlanItem{
+		ProjectID:    project.ID,
+		Service:      service.Name,
+		RequestCount: service.Requests,
+		UsageStatus:  string(service.Status),
+	})
+}
+
+// Save writes the plan as JSON to path, and next to it a shell script with the gcloud
+// commands that disable the marked services. It returns the path of the script.
+func (p *Plan) Save(path string) (string, error) {
+	sort.Slice(p.Items, func(i, j int) bool {
+		if p.Items[i].ProjectID != p.Items[j].ProjectID {
+			return p.Items[i].ProjectID < p.Items[j].ProjectID
+		}
+		return p.Items[i].Service < p.Items[j].Service
+	})
+	p.UpdatedAt = time.Now().Format(time.RFC3339)
+	if p.Items == nil {
+		p.Items = []PlanItem{}
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal remediation plan: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write remediation plan: %w", err)
+	}
+
+	scriptPath := strings.TrimSuffix(path, ".json") + ".sh"
+	if err := os.WriteFile(scriptPath, []byte(p.script()), 0755); err != nil {
+		return "", fmt.Errorf("failed to write remediation script: %w", err)
+	}
+
+	p.dirty = false
+	return scriptPath, nil
+}
+
+// script renders the plan as gcloud commands, to be reviewed before it is run. Names come
+// from the plan file, so they are quoted rather than trusted to be valid IDs.
+func (p *Plan) script() string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Remediation plan for audit run %s, %d services\n", commentText(p.RunID), len(p.Items))
+	b.WriteString("# Review before running: disabling a service that is still in use breaks its callers.\n")
+	b.WriteString("set -e\n")
+
+	project := ""
+	for _, item := range p.Items {
+		if item.ProjectID != project {
+			project = item.ProjectID
+			fmt.Fprintf(&b, "\n# %s\n", commentText(project))
+		}
+		fmt.Fprintf(&b, "gcloud services disable %s --project %s\n", shellQuote(item.Service), shellQuote(item.ProjectID))
+	}
+	return b.String()
+}
+
+// shellQuote quotes s as a single word for sh. Plain service and project IDs are left as is.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.-_:/") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// commentText keeps s on the comment line it is written to
+var commentText = strings.NewReplacer("\r", " ", "\n", " ").Replace
@@ -0,0 +1,145 @@
+package explore
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+func TestPlanSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), PlanFileName)
+	plan, err := LoadPlan(path, "20241127_123456")
+	if err != nil {
+		t.Fatalf("LoadPlan() error = %v", err)
+	}
+	if plan.Len() != 0 || plan.Dirty() {
+		t.Fatalf("missing plan has %d items, dirty %t; want an empty clean plan", plan.Len(), plan.Dirty())
+	}
+
+	beta := &Project{ID: "beta"}
+	alpha := &Project{ID: "alpha"}
+	storage := &Service{Name: "storage.googleapis.com", Status: domain.UsageStatusSuccess}
+	bigquery := &Service{Name: "bigquery.googleapis.com", Status: domain.UsageStatusSuccess, Requests: 3}
+	plan.Toggle(beta, storage)
+	plan.Toggle(alpha, storage)
+	plan.Toggle(alpha, bigquery)
+	plan.Toggle(beta, storage) // Unmarked again
+	if !plan.Dirty() {
+		t.Error("Dirty() = false after marking services")
+	}
+
+	scriptPath, err := plan.Save(path)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if plan.Dirty() {
+		t.Error("Dirty() = true after Save()")
+	}
+	if want := strings.TrimSuffix(path, ".json") + ".sh"; scriptPath != want {
+		t.Errorf("Save() = %s, want %s", scriptPath, want)
+	}
+
+	script, err := os.ReadFile(scriptPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `#!/bin/sh
+# Remediation plan for audit run 20241127_123456, 2 services
+# Review before running: disabling a service that is still in use breaks its callers.
+set -e
+
+# alpha
+gcloud services disable bigquery.googleapis.com --project alpha
+gcloud services disable storage.googleapis.com --project alpha
+`
+	if string(script) != want {
+		t.Errorf("script =\n%s\nwant:\n%s", script, want)
+	}
+	if info, err := os.Stat(scriptPath); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("script is not executable: %v %v", info.Mode(), err)
+	}
+
+	loaded, err := LoadPlan(path, "ignored")
+	if err != nil {
+		t.Fatalf("LoadPlan() error = %v", err)
+	}
+	if loaded.RunID != "20241127_123456" {
+		t.Errorf("loaded RunID = %s, want the saved one", loaded.RunID)
+	}
+	if !reflect.DeepEqual(loaded.Items, plan.Items) {
+		t.Errorf("loaded items = %+v, want %+v", loaded.Items, plan.Items)
+	}
+	if !loaded.Marked("alpha", "bigquery.googleapis.com") || loaded.Marked("beta", "storage.googleapis.com") {
+		t.Error("Marked() does not match the saved items")
+	}
+	if loaded.Dirty() {
+		t.Error("Dirty() = true after LoadPlan()")
+	}
+}
+
+func TestLoadPlanInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), PlanFileName)
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPlan(path, ""); err == nil {
+		t.Error("LoadPlan() error = nil for invalid JSON")
+	}
+}
+
+// TestPlanScriptQuoting runs the script of a plan file with hostile names, against a gcloud
+// that records its arguments
+func TestPlanScriptQuoting(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "injected")
+	args := filepath.Join(dir, "args")
+	gcloud := "#!/bin/sh\nprintf '%s\\n' \"$@\" >> " + shellQuote(args) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "gcloud"), []byte(gcloud), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := &Plan{
+		RunID: "run\ntouch " + marker,
+		Items: []PlanItem{
+			{ProjectID: "alpha; touch " + marker, Service: "$(touch " + marker + ")"},
+			{ProjectID: "alpha; touch " + marker, Service: "it's `touch " + marker + "`"},
+			{ProjectID: "beta\ntouch " + marker, Service: "storage.googleapis.com"},
+		},
+	}
+	path := filepath.Join(dir, PlanFileName)
+	scriptPath, err := plan.Save(path)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	cmd := exec.Command("sh", scriptPath)
+	cmd.Env = append(os.Environ(), "PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("the script ran a command injected through the plan")
+	}
+
+	data, err := os.ReadFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, item := range plan.Items {
+		want = append(want, "services", "disable", item.Service, "--project", item.ProjectID)
+	}
+	// Arguments are recorded one per line, so the project ID with a line break spans two
+	if got, want := string(data), strings.Join(want, "\n")+"\n"; got != want {
+		t.Errorf("gcloud arguments =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+// internal/hygiene/hygiene.go
+package hygiene
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/policy"
+)
+
+// Factor names reported in hygiene scores
+const (
+	FactorUnusedServices   = "unused_services"
+	FactorMissingLabels    = "missing_labels"
+	FactorMetricErrors     = "metric_errors"
+	FactorAge              = "age"
+	FactorPolicyViolations = "policy_violations"
+)
+
+// violationsForFullPenalty is the number of policy violations that costs the full weight
+const violationsForFullPenalty = 3
+
+// Weights sets how much each factor contributes to the score. Weights are relative,
+// so only their ratios matter; a zero weight disables the factor.
+type Weights struct {
+	UnusedServices   float64 `mapstructure:"unused_services"`
+	MissingLabels    float64 `mapstructure:"missing_labels"`
+	MetricErrors     float64 `mapstructure:"metric_errors"`
+	Age              float64 `mapstructure:"age"`
+	PolicyViolations float64 `mapstructure:"policy_violations"`
+}
+
+// Config configures the project hygiene score
+type Config struct {
+	Weights    Weights `mapstructure:"weights"`
+	MaxAgeDays int     `mapstructure:"max_age_days"` // Age at which a project gets the full age penalty
+}
+
+// DefaultConfig returns the weights used when the config file does not set any
+func DefaultConfig() Config {
+	return Config{
+		Weights: Weights{
+			UnusedServices:   30,
+			MissingLabels:    20,
+			MetricErrors:     15,
+			Age:              10,
+			PolicyViolations: 25,
+		},
+		MaxAgeDays: 3 * 365,
+	}
+}
+
+// Validate checks that weights are non-negative and at least one factor is enabled
+func (c Config) Validate() error {
+	total := 0.0
+	for _, weight := range c.Weights.values() {
+		if weight.value < 0 {
+			return fmt.Errorf("hygiene weight %s must not be negative", weight.name)
+		}
+		total += weight.value
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one hygiene weight must be positive")
+	}
+	if c.MaxAgeDays < 0 {
+		return fmt.Errorf("hygiene max_age_days must not be negative")
+	}
+	return nil
+}
+
+type namedWeight struct {
+	name  string
+	value float64
+}
+
+func (w Weights) values() []namedWeight {
+	return []namedWeight{
+		{FactorUnusedServices, w.UnusedServices},
+		{FactorMissingLabels, w.MissingLabels},
+		{FactorMetricErrors, w.MetricErrors},
+		{FactorAge, w.Age},
+		{FactorPolicyViolations, w.PolicyViolations},
+	}
+}
+
+// ScoreProject computes the hygiene score of a single project from its services and policy
+// violations. now is the time the project age is measured at.
+func ScoreProject(
+	project domain.Project,
+	services []domain.Service,
+	skipped bool,
+	violations []domain.PolicyViolation,
+	now time.Time,
+	rules policy.Rules,
+	cfg Config,
+) domain.HygieneScore {
+	// Missing labels have their own factor
+	policyViolations := 0
+	for _, violation := range violations {
+		if violation.Rule != policy.RuleRequiredLabel {
+			policyViolations++
+		}
+	}
+
+	totalWeight := 0.0
+	for _, weight := range cfg.Weights.values() {
+		totalWeight += weight.value
+	}
+
+	factors := []domain.HygieneFactor{
+		{Name: FactorUnusedServices, Weight: cfg.Weights.UnusedServices, Penalty: unusedPenalty(services)},
+		{Name: FactorMissingLabels, Weight: cfg.Weights.MissingLabels, Penalty: missingLabelsPenalty(project, rules.RequiredLabels)},
+		{Name: FactorMetricErrors, Weight: cfg.Weights.MetricErrors, Penalty: metricErrorsPenalty(services, skipped)},
+		{Name: FactorAge, Weight: cfg.Weights.Age, Penalty: agePenalty(project, now, cfg.MaxAgeDays)},
+		{Name: FactorPolicyViolations, Weight: cfg.Weights.PolicyViolations,
+			Penalty: math.Min(float64(policyViolations)/violationsForFullPenalty, 1)},
+	}
+
+	score := 100.0
+	for i, factor := range factors {
+		if totalWeight > 0 {
+			score -= 100 * factor.Weight / totalWeight * factor.Penalty
+		}
+		factors[i].Penalty = math.Round(factor.Penalty*1000) / 1000
+	}
+
+	return domain.HygieneScore{
+		Score:   math.Round(math.Max(score, 0)*10) / 10,
+		Factors: factors,
+	}
+}
+
+// unusedPenalty is the share of services with a successful usage lookup that had no requests
+func unusedPenalty(services []domain.Service) float64 {
+	measured, unused := 0, 0
+	for _, service := range services {
+		if service.Usage == nil || service.Usage.Status != domain.UsageStatusSuccess {
+			continue
+		}
+		measured++
+		if service.Usage.RequestCount == 0 {
+			unused++
+		}
+	}
+	if measured == 0 {
+		return 0
+	}
+	return float64(unused) / float64(measured)
+}
+
+// missingLabelsPenalty is the share of the policy's required labels the project lacks
+func missingLabelsPenalty(project domain.Project, requiredLabels []string) float64 {
+	if len(requiredLabels) == 0 {
+		return 0
+	}
+	missing := 0
+	for _, label := range requiredLabels {
+		if project.Labels[label] == "" {
+			missing++
+		}
+	}
+	return float64(missing) / float64(len(requiredLabels))
+}
+
+// metricErrorsPenalty is the share of services whose usage could not be read. A skipped
+// project gets the full penalty.
+func metricErrorsPenalty(services []domain.Service, skipped bool) float64 {
+	if skipped {
+		return 1
+	}
+	if len(services) == 0 {
+		return 0
+	}
+	failed := 0
+	for _, service := range services {
+		if service.Usage != nil &&
+			(service.Usage.Status == domain.UsageStatusNoAccess || service.Usage.Status == domain.UsageStatusError) {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(services))
+}
+
+// agePenalty grows linearly with the project age up to maxAgeDays. Older projects are more
+// likely to carry forgotten services.
+func agePenalty(project domain.Project, now time.Time, maxAgeDays int) float64 {
+	if project.CreateTime.IsZero() || maxAgeDays <= 0 {
+		return 0
+	}
+	age := now.Sub(project.CreateTime)
+	maxAge := time.Duration(maxAgeDays) * 24 * time.Hour
+	return math.Max(0, math.Min(float64(age)/float64(maxAge), 1))
+}
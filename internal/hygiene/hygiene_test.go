@@ -0,0 +1,220 @@
+package hygiene
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/policy"
+)
+
+var now = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func service(name string, status domain.UsageStatus, requests int64) domain.Service {
+	return domain.Service{Name: name, Usage: &domain.Usage{Status: status, RequestCount: requests}}
+}
+
+func violation(rule, service string) domain.PolicyViolation {
+	return domain.PolicyViolation{ProjectID: "p", Rule: rule, Service: service}
+}
+
+func daysAgo(days int) time.Time {
+	return now.Add(-time.Duration(days) * 24 * time.Hour)
+}
+
+func TestScoreProject(t *testing.T) {
+	tests := []struct {
+		name        string
+		project     domain.Project
+		services    []domain.Service
+		skipped     bool
+		violations  []domain.PolicyViolation
+		rules       policy.Rules
+		cfg         Config
+		wantScore   float64
+		wantPenalty map[string]float64 // Factors not listed must have no penalty
+	}{
+		{
+			name:      "clean project",
+			project:   domain.Project{ID: "p", Labels: map[string]string{"owner": "data"}, CreateTime: now},
+			services:  []domain.Service{service("a", domain.UsageStatusSuccess, 5)},
+			rules:     policy.Rules{RequiredLabels: []string{"owner"}},
+			cfg:       DefaultConfig(),
+			wantScore: 100,
+		},
+		{
+			name:    "every factor",
+			project: domain.Project{ID: "p", Labels: map[string]string{"owner": "data"}, CreateTime: daysAgo(25)},
+			services: []domain.Service{
+				service("used", domain.UsageStatusSuccess, 10),
+				service("unused", domain.UsageStatusSuccess, 0),
+				service("denied", domain.UsageStatusNoAccess, 0),
+				service("failed", domain.UsageStatusError, 0),
+			},
+			violations: []domain.PolicyViolation{
+				violation(policy.RuleRequiredLabel, ""),
+				violation(policy.RuleDeniedService, "x"),
+				violation(policy.RuleDeniedService, "y"),
+			},
+			rules: policy.Rules{RequiredLabels: []string{"owner", "env"}},
+			cfg:   Config{Weights: DefaultConfig().Weights, MaxAgeDays: 100},
+			// 100 - 30*0.5 - 20*0.5 - 15*0.5 - 10*0.25 - 25*2/3
+			wantScore: 48.3,
+			wantPenalty: map[string]float64{
+				FactorUnusedServices:   0.5, // Only successful lookups are measured
+				FactorMissingLabels:    0.5,
+				FactorMetricErrors:     0.5,
+				FactorAge:              0.25,
+				FactorPolicyViolations: 0.667, // Missing labels are not counted twice
+			},
+		},
+		{
+			name:      "weights are normalized",
+			project:   domain.Project{ID: "p"},
+			services:  []domain.Service{service("a", domain.UsageStatusSuccess, 0)},
+			cfg:       Config{Weights: Weights{UnusedServices: 3, Age: 1}},
+			wantScore: 25,
+			wantPenalty: map[string]float64{
+				FactorUnusedServices: 1,
+			},
+		},
+		{
+			name:      "only weight ratios matter",
+			project:   domain.Project{ID: "p"},
+			services:  []domain.Service{service("a", domain.UsageStatusSuccess, 0)},
+			cfg:       Config{Weights: Weights{UnusedServices: 300, Age: 100}},
+			wantScore: 25,
+			wantPenalty: map[string]float64{
+				FactorUnusedServices: 1,
+			},
+		},
+		{
+			name:      "zero weight disables a factor",
+			project:   domain.Project{ID: "p"},
+			services:  []domain.Service{service("a", domain.UsageStatusSuccess, 0)},
+			cfg:       Config{Weights: Weights{Age: 1}},
+			wantScore: 100,
+			wantPenalty: map[string]float64{
+				FactorUnusedServices: 1,
+			},
+		},
+		{
+			name:      "skipped project has full metric penalty",
+			project:   domain.Project{ID: "p"},
+			skipped:   true,
+			cfg:       Config{Weights: Weights{MetricErrors: 1, UnusedServices: 1}},
+			wantScore: 50,
+			wantPenalty: map[string]float64{
+				FactorMetricErrors: 1,
+			},
+		},
+		{
+			name:    "policy violations are capped",
+			project: domain.Project{ID: "p"},
+			violations: []domain.PolicyViolation{
+				violation(policy.RuleDeniedService, "a"),
+				violation(policy.RuleDeniedService, "b"),
+				violation(policy.RuleDeniedService, "c"),
+				violation(policy.RuleDeniedService, "d"),
+			},
+			cfg:       Config{Weights: Weights{PolicyViolations: 1}},
+			wantScore: 0,
+			wantPenalty: map[string]float64{
+				FactorPolicyViolations: 1,
+			},
+		},
+		{
+			name:       "score is rounded to one decimal",
+			project:    domain.Project{ID: "p"},
+			violations: []domain.PolicyViolation{violation(policy.RuleDeniedService, "a")},
+			cfg:        Config{Weights: Weights{PolicyViolations: 1}},
+			wantScore:  66.7,
+			wantPenalty: map[string]float64{
+				FactorPolicyViolations: 0.333,
+			},
+		},
+		{
+			name:      "no services",
+			project:   domain.Project{ID: "p"},
+			cfg:       Config{Weights: Weights{UnusedServices: 1, MetricErrors: 1}},
+			wantScore: 100,
+		},
+		{
+			name:      "no weights",
+			project:   domain.Project{ID: "p"},
+			services:  []domain.Service{service("a", domain.UsageStatusSuccess, 0)},
+			cfg:       Config{},
+			wantScore: 100,
+			wantPenalty: map[string]float64{
+				FactorUnusedServices: 1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ScoreProject(tt.project, tt.services, tt.skipped, tt.violations, now, tt.rules, tt.cfg)
+
+			if got.Score != tt.wantScore {
+				t.Errorf("Score = %v, want %v", got.Score, tt.wantScore)
+			}
+			if len(got.Factors) != 5 {
+				t.Fatalf("got %d factors, want 5", len(got.Factors))
+			}
+			for _, factor := range got.Factors {
+				if factor.Penalty != tt.wantPenalty[factor.Name] {
+					t.Errorf("%s penalty = %v, want %v", factor.Name, factor.Penalty, tt.wantPenalty[factor.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestAgePenalty(t *testing.T) {
+	tests := []struct {
+		name       string
+		created    time.Time
+		maxAgeDays int
+		want       float64
+	}{
+		{name: "new", created: now, maxAgeDays: 100, want: 0},
+		{name: "half", created: daysAgo(50), maxAgeDays: 100, want: 0.5},
+		{name: "at max age", created: daysAgo(100), maxAgeDays: 100, want: 1},
+		{name: "older than max age", created: daysAgo(400), maxAgeDays: 100, want: 1},
+		{name: "created in the future", created: now.Add(time.Hour), maxAgeDays: 100, want: 0},
+		{name: "unknown creation time", maxAgeDays: 100, want: 0},
+		{name: "disabled", created: daysAgo(50), maxAgeDays: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := agePenalty(domain.Project{CreateTime: tt.created}, now, tt.maxAgeDays)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("agePenalty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "defaults", cfg: DefaultConfig()},
+		{name: "single factor", cfg: Config{Weights: Weights{Age: 1}}},
+		{name: "negative weight", cfg: Config{Weights: Weights{Age: 1, UnusedServices: -1}}, wantErr: true},
+		{name: "all zero", cfg: Config{}, wantErr: true},
+		{name: "negative max age", cfg: Config{Weights: Weights{Age: 1}, MaxAgeDays: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
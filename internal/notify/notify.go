@@ -0,0 +1,91 @@
+// internal/notify/notify.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// maxListedChanges caps the services listed per section of a notification
+const maxListedChanges = 20
+
+// Channel configures a single notification destination
+type Channel struct {
+	Type        string            `mapstructure:"type"`         // slack or webhook
+	URL         string            `mapstructure:"url"`          // Incoming webhook or endpoint URL
+	MinSeverity string            `mapstructure:"min_severity"` // info, warning or critical
+	Headers     map[string]string `mapstructure:"headers"`      // Extra HTTP headers (webhook only)
+}
+
+// NewNotifiers creates a notifier for every configured channel
+func NewNotifiers(channels []Channel) ([]domain.Notifier, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	notifiers := make([]domain.Notifier, 0, len(channels))
+	for i, channel := range channels {
+		if channel.URL == "" {
+			return nil, fmt.Errorf("notification channel %d: url is required", i)
+		}
+
+		minSeverity, err := domain.ParseSeverity(channel.MinSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("notification channel %d: %w", i, err)
+		}
+
+		switch channel.Type {
+		case "slack":
+			notifiers = append(notifiers, NewSlackNotifier(client, channel.URL, minSeverity))
+		case "webhook":
+			notifiers = append(notifiers, NewWebhookNotifier(client, channel.URL, channel.Headers, minSeverity))
+		default:
+			return nil, fmt.Errorf("notification channel %d: invalid type %q. Must be one of: slack, webhook", i, channel.Type)
+		}
+	}
+
+	return notifiers, nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
+
+// enabledServiceCount returns the number of project/service pairs in the report
+func enabledServiceCount(report domain.AuditReport) int {
+	count := 0
+	for _, services := range report.Services {
+		count += len(services)
+	}
+	return count
+}
@@ -0,0 +1,273 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// request is a notification received by the HTTP stand-in
+type request struct {
+	method string
+	header http.Header
+	body   []byte
+}
+
+// newStandIn starts an HTTP stand-in for a Slack or webhook endpoint that answers with status
+// and records the requests it receives
+func newStandIn(t *testing.T, status int) (*httptest.Server, <-chan request) {
+	t.Helper()
+
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{method: r.Method, header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+		io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func newNotification(severity domain.Severity) domain.Notification {
+	start := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+	return domain.Notification{
+		Report: domain.AuditReport{
+			StartTime:   start,
+			GeneratedAt: start.Add(5 * time.Minute),
+			Profile:     "prod",
+			Services: map[string][]domain.Service{
+				"alpha": {{Name: "bigquery.googleapis.com"}, {Name: "storage.googleapis.com"}},
+				"beta":  {{Name: "pubsub.googleapis.com"}},
+			},
+			SkippedProjects: map[string]error{
+				"denied": errors.New("permission denied"),
+			},
+			Statistics: domain.AuditStatistics{
+				ValidProjects:       2,
+				SkippedProjects:     1,
+				UniqueServices:      3,
+				ServicesWithNoUsage: 1,
+			},
+		},
+		Severity:     severity,
+		HasBaseline:  true,
+		NewlyUnused:  []domain.ServiceChange{{ProjectID: "alpha", Service: "bigquery.googleapis.com"}},
+		NewlyEnabled: []domain.ServiceChange{{ProjectID: "beta", Service: "pubsub.googleapis.com"}},
+		ReportURL:    "https://reports.example.com/20240301_060000",
+	}
+}
+
+func TestSlackNotifierPayload(t *testing.T) {
+	server, requests := newStandIn(t, http.StatusOK)
+	notifier := NewSlackNotifier(server.Client(), server.URL, domain.SeverityInfo)
+
+	if err := notifier.Notify(context.Background(), newNotification(domain.SeverityCritical)); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	got := <-requests
+	if got.method != http.MethodPost {
+		t.Errorf("method = %s, want POST", got.method)
+	}
+	if ct := got.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var message slackMessage
+	if err := json.Unmarshal(got.body, &message); err != nil {
+		t.Fatalf("invalid Slack payload %s: %v", got.body, err)
+	}
+	for _, want := range []string{
+		":rotating_light: *GCP services audit completed* (2024-03-01 06:05 UTC)",
+		"• Profile: prod",
+		"• Projects analyzed: 2 (skipped: 1)",
+		"• Enabled services: 3 (3 unique, 1 without usage)",
+		"*Newly unused services*\n• `bigquery.googleapis.com` in `alpha`",
+		"*Newly enabled services*\n• `pubsub.googleapis.com` in `beta`",
+		"*Skipped projects*\n• `denied`: permission denied",
+		"<https://reports.example.com/20240301_060000|View full report>",
+	} {
+		if !strings.Contains(message.Text, want) {
+			t.Errorf("Slack text does not contain %q:\n%s", want, message.Text)
+		}
+	}
+}
+
+func TestSlackNotifierTruncatesChanges(t *testing.T) {
+	notification := newNotification(domain.SeverityWarning)
+	notification.NewlyUnused = nil
+	for i := 0; i < maxListedChanges+5; i++ {
+		notification.NewlyUnused = append(notification.NewlyUnused, domain.ServiceChange{ProjectID: "alpha", Service: "svc"})
+	}
+
+	text := formatSlackText(notification)
+	if got := strings.Count(text, "• `svc` in `alpha`"); got != maxListedChanges {
+		t.Errorf("listed %d changes, want %d", got, maxListedChanges)
+	}
+	if !strings.Contains(text, "_…and 5 more_") {
+		t.Errorf("Slack text does not mention the remaining changes:\n%s", text)
+	}
+}
+
+func TestWebhookNotifierPayload(t *testing.T) {
+	server, requests := newStandIn(t, http.StatusAccepted)
+	headers := map[string]string{
+		"Authorization":   "Bearer secret",
+		"X-Audit-Channel": "finops",
+	}
+	notifier := NewWebhookNotifier(server.Client(), server.URL, headers, domain.SeverityWarning)
+
+	if err := notifier.Notify(context.Background(), newNotification(domain.SeverityCritical)); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	got := <-requests
+	if ct := got.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	for key, want := range headers {
+		if value := got.header.Get(key); value != want {
+			t.Errorf("header %s = %q, want %q", key, value, want)
+		}
+	}
+
+	var payload map[string]any
+	if err := json.Unmarshal(got.body, &payload); err != nil {
+		t.Fatalf("invalid webhook payload %s: %v", got.body, err)
+	}
+	want := map[string]any{
+		"runId":           "20240301_060000",
+		"profile":         "prod",
+		"generatedAt":     "2024-03-01T06:05:00Z",
+		"severity":        "critical",
+		"reportUrl":       "https://reports.example.com/20240301_060000",
+		"projects":        float64(2),
+		"skippedCount":    float64(1),
+		"uniqueServices":  float64(3),
+		"enabledServices": float64(3),
+		"unusedServices":  float64(1),
+		"hasBaseline":     true,
+		"newlyUnused":     []any{map[string]any{"projectId": "alpha", "service": "bigquery.googleapis.com"}},
+		"newlyEnabled":    []any{map[string]any{"projectId": "beta", "service": "pubsub.googleapis.com"}},
+		"skippedProjects": []any{map[string]any{"projectId": "denied", "error": "permission denied"}},
+	}
+	gotJSON, _ := json.Marshal(payload)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("webhook payload = %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+func TestNotifierMinSeverity(t *testing.T) {
+	server, requests := newStandIn(t, http.StatusOK)
+	notifiers := map[string]domain.Notifier{
+		"slack":   NewSlackNotifier(server.Client(), server.URL, domain.SeverityWarning),
+		"webhook": NewWebhookNotifier(server.Client(), server.URL, nil, domain.SeverityWarning),
+	}
+
+	for name, notifier := range notifiers {
+		if err := notifier.Notify(context.Background(), newNotification(domain.SeverityInfo)); err != nil {
+			t.Errorf("%s Notify() error = %v", name, err)
+		}
+	}
+	if len(requests) != 0 {
+		t.Errorf("sent %d notifications below the minimum severity, want none", len(requests))
+	}
+}
+
+func TestNotifierErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		delay   time.Duration
+		wantErr string
+	}{
+		{name: "server error", status: http.StatusInternalServerError, wantErr: "unexpected status 500 Internal Server Error: Internal Server Error"},
+		{name: "not found", status: http.StatusNotFound, wantErr: "unexpected status 404 Not Found: Not Found"},
+		{name: "multiple choices", status: http.StatusMultipleChoices, wantErr: "unexpected status 300"},
+		{name: "timeout", status: http.StatusOK, delay: time.Second, wantErr: "Client.Timeout exceeded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Reading the body lets the server notice when the client gives up
+				io.Copy(io.Discard, r.Body)
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+					return
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, http.StatusText(tt.status))
+			}))
+			defer server.Close()
+
+			client := &http.Client{Timeout: 100 * time.Millisecond}
+			notifiers := map[string]domain.Notifier{
+				"slack":   NewSlackNotifier(client, server.URL, domain.SeverityInfo),
+				"webhook": NewWebhookNotifier(client, server.URL, nil, domain.SeverityInfo),
+			}
+			for name, notifier := range notifiers {
+				err := notifier.Notify(context.Background(), newNotification(domain.SeverityInfo))
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("%s Notify() error = %v, want %q", name, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifierContextCancelled(t *testing.T) {
+	server, _ := newStandIn(t, http.StatusOK)
+	notifier := NewWebhookNotifier(server.Client(), server.URL, nil, domain.SeverityInfo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := notifier.Notify(ctx, newNotification(domain.SeverityInfo)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Notify() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestNewNotifiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []Channel
+		wantErr  string
+	}{
+		{name: "valid", channels: []Channel{
+			{Type: "slack", URL: "https://hooks.slack.com/services/x", MinSeverity: "warning"},
+			{Type: "webhook", URL: "https://example.com/hook"},
+		}},
+		{name: "missing url", channels: []Channel{{Type: "slack"}}, wantErr: "notification channel 0: url is required"},
+		{name: "invalid severity", channels: []Channel{{Type: "slack", URL: "https://x", MinSeverity: "loud"}}, wantErr: `invalid severity "loud"`},
+		{name: "invalid type", channels: []Channel{{Type: "teams", URL: "https://x"}}, wantErr: `invalid type "teams"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifiers, err := NewNotifiers(tt.channels)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewNotifiers() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewNotifiers() error = %v", err)
+			}
+			if len(notifiers) != len(tt.channels) {
+				t.Errorf("NewNotifiers() returned %d notifiers, want %d", len(notifiers), len(tt.channels))
+			}
+		})
+	}
+}
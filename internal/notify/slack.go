@@ -0,0 +1,105 @@
+// internal/notify/slack.go
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// SlackNotifier posts the audit summary to a Slack incoming webhook
+type SlackNotifier struct {
+	client      *http.Client
+	url         string
+	minSeverity domain.Severity
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+var severityEmoji = map[domain.Severity]string{
+	domain.SeverityInfo:     ":white_check_mark:",
+	domain.SeverityWarning:  ":warning:",
+	domain.SeverityCritical: ":rotating_light:",
+}
+
+func NewSlackNotifier(client *http.Client, url string, minSeverity domain.Severity) *SlackNotifier {
+	return &SlackNotifier{
+		client:      client,
+		url:         url,
+		minSeverity: minSeverity,
+	}
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, notification domain.Notification) error {
+	if notification.Severity < n.minSeverity {
+		return nil
+	}
+	return postJSON(ctx, n.client, n.url, nil, slackMessage{Text: formatSlackText(notification)})
+}
+
+func formatSlackText(notification domain.Notification) string {
+	report := notification.Report
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s *GCP services audit completed* (%s)\n",
+		severityEmoji[notification.Severity], report.GeneratedAt.Format("2006-01-02 15:04 MST"))
+	if report.Profile != "" {
+		fmt.Fprintf(&b, "• Profile: %s\n", report.Profile)
+	}
+	fmt.Fprintf(&b, "• Projects analyzed: %d (skipped: %d)\n",
+		report.Statistics.ValidProjects, report.Statistics.SkippedProjects)
+	fmt.Fprintf(&b, "• Enabled services: %d (%d unique, %d without usage)\n",
+		enabledServiceCount(report), report.Statistics.UniqueServices, report.Statistics.ServicesWithNoUsage)
+
+	if notification.HasBaseline {
+		fmt.Fprintf(&b, "• Newly unused services: %d\n", len(notification.NewlyUnused))
+		fmt.Fprintf(&b, "• Newly enabled services: %d\n", len(notification.NewlyEnabled))
+	}
+
+	writeSlackChanges(&b, "Newly unused services", notification.NewlyUnused)
+	writeSlackChanges(&b, "Newly enabled services", notification.NewlyEnabled)
+
+	if len(report.SkippedProjects) > 0 {
+		ids := make([]string, 0, len(report.SkippedProjects))
+		for projectID := range report.SkippedProjects {
+			ids = append(ids, projectID)
+		}
+		sort.Strings(ids)
+
+		fmt.Fprintf(&b, "\n*Skipped projects*\n")
+		for i, projectID := range ids {
+			if i == maxListedChanges {
+				fmt.Fprintf(&b, "_…and %d more_\n", len(ids)-maxListedChanges)
+				break
+			}
+			fmt.Fprintf(&b, "• `%s`: %v\n", projectID, report.SkippedProjects[projectID])
+		}
+	}
+
+	if notification.ReportURL != "" {
+		fmt.Fprintf(&b, "\n<%s|View full report>\n", notification.ReportURL)
+	}
+
+	return b.String()
+}
+
+func writeSlackChanges(b *strings.Builder, title string, changes []domain.ServiceChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n*%s*\n", title)
+	for i, change := range changes {
+		if i == maxListedChanges {
+			fmt.Fprintf(b, "_…and %d more_\n", len(changes)-maxListedChanges)
+			break
+		}
+		fmt.Fprintf(b, "• `%s` in `%s`\n", change.Service, change.ProjectID)
+	}
+}
@@ -0,0 +1,102 @@
+// internal/notify/webhook.go
+package notify
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// WebhookNotifier posts the audit summary as JSON to an arbitrary HTTP endpoint
+type WebhookNotifier struct {
+	client      *http.Client
+	url         string
+	headers     map[string]string
+	minSeverity domain.Severity
+}
+
+// WebhookPayload is the JSON document posted by WebhookNotifier
+type WebhookPayload struct {
+	RunID           string           `json:"runId"`
+	Profile         string           `json:"profile,omitempty"`
+	GeneratedAt     time.Time        `json:"generatedAt"`
+	Severity        string           `json:"severity"`
+	ReportURL       string           `json:"reportUrl,omitempty"`
+	Projects        int              `json:"projects"`
+	SkippedCount    int              `json:"skippedCount"`
+	UniqueServices  int              `json:"uniqueServices"`
+	EnabledServices int              `json:"enabledServices"`
+	UnusedServices  int              `json:"unusedServices"`
+	HasBaseline     bool             `json:"hasBaseline"`
+	NewlyUnused     []WebhookService `json:"newlyUnused"`
+	NewlyEnabled    []WebhookService `json:"newlyEnabled"`
+	SkippedProjects []WebhookSkipped `json:"skippedProjects"`
+}
+
+type WebhookService struct {
+	ProjectID string `json:"projectId"`
+	Service   string `json:"service"`
+}
+
+type WebhookSkipped struct {
+	ProjectID string `json:"projectId"`
+	Error     string `json:"error"`
+}
+
+func NewWebhookNotifier(client *http.Client, url string, headers map[string]string, minSeverity domain.Severity) *WebhookNotifier {
+	return &WebhookNotifier{
+		client:      client,
+		url:         url,
+		headers:     headers,
+		minSeverity: minSeverity,
+	}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification domain.Notification) error {
+	if notification.Severity < n.minSeverity {
+		return nil
+	}
+	return postJSON(ctx, n.client, n.url, n.headers, newWebhookPayload(notification))
+}
+
+func newWebhookPayload(notification domain.Notification) WebhookPayload {
+	report := notification.Report
+	payload := WebhookPayload{
+		RunID:           report.RunID(),
+		Profile:         report.Profile,
+		GeneratedAt:     report.GeneratedAt,
+		Severity:        notification.Severity.String(),
+		ReportURL:       notification.ReportURL,
+		Projects:        report.Statistics.ValidProjects,
+		SkippedCount:    report.Statistics.SkippedProjects,
+		UniqueServices:  report.Statistics.UniqueServices,
+		EnabledServices: enabledServiceCount(report),
+		UnusedServices:  report.Statistics.ServicesWithNoUsage,
+		HasBaseline:     notification.HasBaseline,
+		NewlyUnused:     make([]WebhookService, 0, len(notification.NewlyUnused)),
+		NewlyEnabled:    make([]WebhookService, 0, len(notification.NewlyEnabled)),
+		SkippedProjects: make([]WebhookSkipped, 0, len(report.SkippedProjects)),
+	}
+
+	for _, change := range notification.NewlyUnused {
+		payload.NewlyUnused = append(payload.NewlyUnused, WebhookService{ProjectID: change.ProjectID, Service: change.Service})
+	}
+	for _, change := range notification.NewlyEnabled {
+		payload.NewlyEnabled = append(payload.NewlyEnabled, WebhookService{ProjectID: change.ProjectID, Service: change.Service})
+	}
+	for projectID, err := range report.SkippedProjects {
+		skipped := WebhookSkipped{ProjectID: projectID}
+		if err != nil {
+			skipped.Error = err.Error()
+		}
+		payload.SkippedProjects = append(payload.SkippedProjects, skipped)
+	}
+	sort.Slice(payload.SkippedProjects, func(i, j int) bool {
+		return payload.SkippedProjects[i].ProjectID < payload.SkippedProjects[j].ProjectID
+	})
+
+	return payload
+}
@@ -0,0 +1,74 @@
+// internal/policy/policy.go
+package policy
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// Rule names reported in policy violations
+const (
+	RuleRequiredLabel = "required-label"
+	RuleDeniedService = "denied-service"
+)
+
+// Rules are the project policies checked after every audit
+type Rules struct {
+	RequiredLabels []string `mapstructure:"required_labels"` // Labels every project must carry
+	DeniedServices []string `mapstructure:"denied_services"` // Services that must not be enabled; glob patterns are allowed
+}
+
+// Validate checks that the denied service patterns are valid globs
+func (r Rules) Validate() error {
+	for _, pattern := range r.DeniedServices {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid denied service pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// EvaluateProject returns the policy violations of a single project, ordered by rule
+// configuration and service
+func EvaluateProject(project domain.Project, services []domain.Service, rules Rules) []domain.PolicyViolation {
+	var violations []domain.PolicyViolation
+
+	for _, label := range rules.RequiredLabels {
+		if value, exists := project.Labels[label]; !exists || value == "" {
+			violations = append(violations, domain.PolicyViolation{
+				ProjectID: project.ID,
+				Rule:      RuleRequiredLabel,
+				Message:   fmt.Sprintf("missing required label %q", label),
+			})
+		}
+	}
+
+	for _, service := range services {
+		for _, pattern := range rules.DeniedServices {
+			if matched, _ := path.Match(pattern, service.Name); matched {
+				violations = append(violations, domain.PolicyViolation{
+					ProjectID: project.ID,
+					Rule:      RuleDeniedService,
+					Service:   service.Name,
+					Message:   fmt.Sprintf("service %s is not allowed", service.Name),
+				})
+				break
+			}
+		}
+	}
+
+	return violations
+}
+
+// Sort orders violations by project and rule, keeping the order of violations within a rule
+func Sort(violations []domain.PolicyViolation) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].ProjectID != violations[j].ProjectID {
+			return violations[i].ProjectID < violations[j].ProjectID
+		}
+		return violations[i].Rule < violations[j].Rule
+	})
+}
@@ -0,0 +1,268 @@
+// internal/progress/progress.go
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/pkg/metrics"
+)
+
+const (
+	refreshInterval = 200 * time.Millisecond
+	rateWindow      = 10 * time.Second // API call rates are averaged over this window
+	maxInFlightRows = 8                // In-flight projects listed; the rest are counted
+	barWidth        = 30
+	defaultWidth    = 80
+)
+
+// Display is a live view of a running audit, redrawn in place on a terminal: overall
+// project progress with an ETA, the projects in flight, API call rates and error counts.
+// It implements domain.ProgressObserver; all methods are safe for concurrent use.
+type Display struct {
+	terminal *os.File
+
+	mu        sync.Mutex
+	started   time.Time
+	total     int
+	done      int
+	skipped   int
+	inFlight  map[string]time.Time // Start time per project being audited
+	apiCalls  int64
+	apiErrors int64
+	samples   []sample // Recent API call counts for the rate
+	lines     int      // Lines drawn by the last render, erased by the next one
+	active    bool     // Between Start and Stop
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type sample struct {
+	at    time.Time
+	calls int64
+}
+
+// IsTerminal reports whether f is a terminal that can show the live display
+func IsTerminal(f *os.File) bool {
+	_, ok := terminalWidth(f)
+	return ok
+}
+
+// NewDisplay returns a display drawn on terminal, usually standard error. Start begins
+// drawing.
+func NewDisplay(terminal *os.File) *Display {
+	return &Display{
+		terminal: terminal,
+		started:  time.Now(),
+		inFlight: make(map[string]time.Time),
+	}
+}
+
+// Start redraws the display periodically until Stop is called
+func (d *Display) Start() {
+	d.mu.Lock()
+	d.active = true
+	d.mu.Unlock()
+
+	d.stop = make(chan struct{})
+	d.stopped = make(chan struct{})
+
+	go func() {
+		defer close(d.stopped)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				d.mu.Lock()
+				d.render()
+				d.mu.Unlock()
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop draws the final state and stops redrawing. The final state stays on the terminal.
+func (d *Display) Stop() {
+	if d.stop == nil {
+		return
+	}
+	close(d.stop)
+	<-d.stopped
+	d.stop = nil
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.render()
+	d.lines = 0
+	d.active = false
+}
+
+// Writer wraps the destination of log lines, so that they are printed above the display
+// instead of through it
+func (d *Display) Writer(w io.Writer) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		d.erase()
+		n, err := w.Write(p)
+		if d.active {
+			d.render()
+		}
+		return n, err
+	})
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// AuditStarted sets the number of projects the audit processes
+func (d *Display) AuditStarted(projects int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.started = time.Now()
+	d.total = projects
+}
+
+// ProjectStarted marks a project as in flight
+func (d *Display) ProjectStarted(projectID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inFlight[projectID] = time.Now()
+}
+
+// ProjectFinished marks a project as done; a non-nil err means it was skipped
+func (d *Display) ProjectFinished(projectID string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inFlight, projectID)
+	d.done++
+	if err != nil {
+		d.skipped++
+	}
+}
+
+// APICall counts a Service Usage or Monitoring API request and whether it failed
+func (d *Display) APICall(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.apiCalls++
+	if err != nil {
+		d.apiErrors++
+	}
+}
+
+// erase moves the cursor back to the first line of the display and clears it
+func (d *Display) erase() {
+	if d.lines > 0 {
+		fmt.Fprintf(d.terminal, "\x1b[%dF\x1b[J", d.lines)
+		d.lines = 0
+	}
+}
+
+func (d *Display) render() {
+	now := time.Now()
+	width, ok := terminalWidth(d.terminal)
+	if !ok || width <= 0 {
+		width = defaultWidth
+	}
+
+	lines := []string{d.progressLine(now), d.apiLine(now)}
+	lines = append(lines, d.inFlightLines(now)...)
+
+	var b strings.Builder
+	for _, line := range lines {
+		// Wrapped lines would throw off the number of lines to erase
+		if len(line) >= width {
+			line = line[:width-1]
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	d.erase()
+	io.WriteString(d.terminal, b.String())
+	d.lines = len(lines)
+}
+
+func (d *Display) progressLine(now time.Time) string {
+	elapsed := now.Sub(d.started)
+	percent := 0
+	filled := 0
+	if d.total > 0 {
+		percent = d.done * 100 / d.total
+		filled = d.done * barWidth / d.total
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled)
+
+	// Projects complete at a steady rate once the concurrency is saturated
+	eta := "-"
+	if d.done > 0 && d.done < d.total {
+		remaining := time.Duration(float64(elapsed) / float64(d.done) * float64(d.total-d.done))
+		eta = remaining.Round(time.Second).String()
+	} else if d.total > 0 && d.done == d.total {
+		eta = "0s"
+	}
+
+	return fmt.Sprintf("Projects [%s] %d/%d (%d%%)  elapsed %s  ETA %s",
+		bar, d.done, d.total, percent, elapsed.Round(time.Second), eta)
+}
+
+func (d *Display) apiLine(now time.Time) string {
+	d.samples = append(d.samples, sample{at: now, calls: d.apiCalls})
+	for len(d.samples) > 1 && now.Sub(d.samples[0].at) > rateWindow {
+		d.samples = d.samples[1:]
+	}
+
+	rate := 0.0
+	if oldest := d.samples[0]; now.After(oldest.at) {
+		rate = float64(d.apiCalls-oldest.calls) / now.Sub(oldest.at).Seconds()
+	}
+
+	return fmt.Sprintf("API calls %s (%.1f/s)  API errors %s  skipped projects %d",
+		metrics.FormatNumber(d.apiCalls), rate, metrics.FormatNumber(d.apiErrors), d.skipped)
+}
+
+// inFlightLines lists the projects in flight, the longest running first
+func (d *Display) inFlightLines(now time.Time) []string {
+	if len(d.inFlight) == 0 {
+		return nil
+	}
+
+	type project struct {
+		id      string
+		started time.Time
+	}
+	projects := make([]project, 0, len(d.inFlight))
+	for id, started := range d.inFlight {
+		projects = append(projects, project{id: id, started: started})
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if !projects[i].started.Equal(projects[j].started) {
+			return projects[i].started.Before(projects[j].started)
+		}
+		return projects[i].id < projects[j].id
+	})
+
+	lines := []string{fmt.Sprintf("In flight (%d):", len(projects))}
+	for _, p := range projects[:min(len(projects), maxInFlightRows)] {
+		lines = append(lines, fmt.Sprintf("  %-40s %s", p.id, now.Sub(p.started).Round(time.Second)))
+	}
+	if len(projects) > maxInFlightRows {
+		lines = append(lines, fmt.Sprintf("  ... and %d more", len(projects)-maxInFlightRows))
+	}
+	return lines
+}
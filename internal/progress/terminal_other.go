@@ -0,0 +1,12 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || zos || windows)
+
+// internal/progress/terminal_other.go
+package progress
+
+import "os"
+
+// terminalWidth reports that f is not a terminal; the live display is not supported on
+// this platform
+func terminalWidth(f *os.File) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+// internal/progress/terminal_windows.go
+package progress
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// terminalWidth returns the width of the console f is attached to. The second value is
+// false when f is not a console, or one that cannot interpret the escape sequences of
+// the display.
+func terminalWidth(f *os.File) (int, bool) {
+	handle := windows.Handle(f.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return 0, false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING == 0 {
+		if err := windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+			return 0, false
+		}
+	}
+
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(handle, &info); err != nil {
+		return 0, false
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, true
+}
@@ -0,0 +1,180 @@
+// internal/replay/cassette.go
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Interaction is a recorded API request and its response. HTTP interactions carry a status and
+// body; gRPC interactions carry a protobuf JSON response or an error code.
+type Interaction struct {
+	Key      string          `json:"key"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url,omitempty"`
+	Request  json.RawMessage `json:"request,omitempty"` // Informational; matching uses Key
+	Status   int             `json:"status,omitempty"`
+	Header   *Header         `json:"header,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Body     string          `json:"body,omitempty"`
+	Code     int             `json:"code,omitempty"` // gRPC status code of a failed call
+	Message  string          `json:"message,omitempty"`
+}
+
+// Header holds the response headers needed to decode a replayed HTTP response
+type Header struct {
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// Cassette records API interactions to fixture files, or replays them. Every API host has its
+// own JSON Lines file in the cassette directory, e.g. monitoring.googleapis.com.jsonl.
+// Identical requests are replayed in the order they were recorded; the last response is
+// repeated once the recording is exhausted.
+type Cassette struct {
+	dir       string
+	recording bool
+
+	mu           sync.Mutex
+	files        map[string]*os.File      // Recording: open fixture file per host
+	interactions map[string][]Interaction // Replay: recorded interactions per key
+	played       map[string]int           // Replay: interactions played per key
+}
+
+const fixtureExt = ".jsonl"
+
+// Record creates a cassette that records interactions into dir
+func Record(dir string) (*Cassette, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	return &Cassette{
+		dir:       dir,
+		recording: true,
+		files:     make(map[string]*os.File),
+	}, nil
+}
+
+// Replay loads the interactions recorded in dir
+func Replay(dir string) (*Cassette, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+fixtureExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fixtures (*%s) found in %s", fixtureExt, dir)
+	}
+	sort.Strings(paths)
+
+	c := &Cassette{
+		dir:          dir,
+		interactions: make(map[string][]Interaction),
+		played:       make(map[string]int),
+	}
+	for _, path := range paths {
+		if err := c.load(path); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Cassette) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open fixture: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return fmt.Errorf("invalid fixture %s:%d: %w", path, line, err)
+		}
+		c.interactions[interaction.Key] = append(c.interactions[interaction.Key], interaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read fixture %s: %w", path, err)
+	}
+	return nil
+}
+
+// Recording reports whether the cassette records interactions rather than replaying them
+func (c *Cassette) Recording() bool {
+	return c.recording
+}
+
+// record appends an interaction to the fixture file of a host
+func (c *Cassette) record(host string, interaction Interaction) error {
+	data, err := json.Marshal(interaction)
+	if err != nil {
+		return fmt.Errorf("failed to encode interaction: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file, ok := c.files[host]
+	if !ok {
+		path := filepath.Join(c.dir, sanitizeHost(host)+fixtureExt)
+		file, err = os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create fixture: %w", err)
+		}
+		c.files[host] = file
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+// next returns the next recorded interaction for a key
+func (c *Cassette) next(key string) (Interaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	recorded := c.interactions[key]
+	if len(recorded) == 0 {
+		return Interaction{}, fmt.Errorf("replay: no recorded response for %s", key)
+	}
+
+	i := min(c.played[key], len(recorded)-1)
+	c.played[key]++
+	return recorded[i], nil
+}
+
+// Close closes the fixture files of a recording
+func (c *Cassette) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for _, file := range c.files {
+		errs = append(errs, file.Close())
+	}
+	c.files = make(map[string]*os.File)
+	return errors.Join(errs...)
+}
+
+func sanitizeHost(host string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, host)
+}
@@ -0,0 +1,121 @@
+// internal/replay/http.go
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// RoundTripper records or replays HTTP requests. When recording, requests are sent through
+// the wrapped transport, which is expected to authenticate them.
+func (c *Cassette) RoundTripper(base http.RoundTripper) http.RoundTripper {
+	return &transport{cassette: c, base: base}
+}
+
+type transport struct {
+	cassette *Cassette
+	base     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	key := httpKey(req, body)
+
+	if !t.cassette.recording {
+		interaction, err := t.cassette.next(key)
+		if err != nil {
+			return nil, err
+		}
+		return replayResponse(req, interaction), nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Key:    key,
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+		Header: &Header{ContentType: resp.Header.Get("Content-Type")},
+		Body:   string(respBody),
+	}
+	if json.Valid(body) {
+		interaction.Request = body
+	}
+	if err := t.cassette.record(req.URL.Host, interaction); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// httpKey identifies a request by method, URL without volatile query parameters, and body.
+// JSON bodies are included as compact JSON, other bodies by their hash.
+func httpKey(req *http.Request, body []byte) string {
+	query := req.URL.Query()
+	for _, param := range []string{"alt", "prettyPrint"} {
+		query.Del(param)
+	}
+
+	u := url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: req.URL.Path, RawQuery: query.Encode()}
+	key := req.Method + " " + u.String()
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		key += " " + compactJSON(body)
+	default:
+		sum := sha256.Sum256(body)
+		key += " sha256:" + hex.EncodeToString(sum[:])
+	}
+	return key
+}
+
+// compactJSON removes insignificant whitespace, which protojson varies between runs
+func compactJSON(data []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return string(data)
+	}
+	return buf.String()
+}
+
+func replayResponse(req *http.Request, interaction Interaction) *http.Response {
+	header := make(http.Header)
+	if interaction.Header != nil && interaction.Header.ContentType != "" {
+		header.Set("Content-Type", interaction.Header.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+		StatusCode:    interaction.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Body))),
+		ContentLength: int64(len(interaction.Body)),
+		Request:       req,
+	}
+}
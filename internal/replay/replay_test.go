@@ -0,0 +1,330 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	monitoringpb "cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newCountingServer answers every request with its method, path, body and the number of
+// requests received so far
+func newCountingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, `{"method":%q,"path":%q,"body":%q,"count":%d}`, r.Method, r.URL.Path, body, requests)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+type response struct {
+	status      int
+	contentType string
+	body        string
+}
+
+func send(t *testing.T, client *http.Client, method, url, body string) response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response{status: resp.StatusCode, contentType: resp.Header.Get("Content-Type"), body: string(data)}
+}
+
+func closeCassette(t *testing.T, cassette *Cassette) {
+	t.Helper()
+
+	if err := cassette.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestHTTPRecordReplay(t *testing.T) {
+	server := newCountingServer(t)
+	dir := t.TempDir()
+
+	recording, err := Record(dir)
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	client := &http.Client{Transport: recording.RoundTripper(http.DefaultTransport)}
+	recorded := []response{
+		send(t, client, http.MethodGet, server.URL+"/v1/projects?alt=json&prettyPrint=false&pageToken=2", ""),
+		send(t, client, http.MethodPost, server.URL+"/v1/projects:search", `{"query": "parent:folders/1"}`),
+		send(t, client, http.MethodGet, server.URL+"/missing", ""),
+	}
+	closeCassette(t, recording)
+	server.Close()
+
+	cassette, err := Replay(dir)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	client = &http.Client{Transport: cassette.RoundTripper(nil)}
+	replayed := []response{
+		// Volatile parameters and the whitespace of JSON bodies do not change the key
+		send(t, client, http.MethodGet, server.URL+"/v1/projects?pageToken=2&alt=json", ""),
+		send(t, client, http.MethodPost, server.URL+"/v1/projects:search", `{"query":"parent:folders/1"}`),
+		send(t, client, http.MethodGet, server.URL+"/missing", ""),
+	}
+
+	for i := range recorded {
+		if replayed[i] != recorded[i] {
+			t.Errorf("replayed response %d = %+v, want %+v", i, replayed[i], recorded[i])
+		}
+	}
+	if recorded[2].status != http.StatusNotFound {
+		t.Errorf("recorded status = %d, want %d", recorded[2].status, http.StatusNotFound)
+	}
+}
+
+func TestReplayRepeatedRequests(t *testing.T) {
+	server := newCountingServer(t)
+	dir := t.TempDir()
+
+	recording, err := Record(dir)
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	client := &http.Client{Transport: recording.RoundTripper(http.DefaultTransport)}
+	for i := 0; i < 3; i++ {
+		send(t, client, http.MethodGet, server.URL+"/v1/operations/op", "")
+	}
+	closeCassette(t, recording)
+
+	cassette, err := Replay(dir)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	client = &http.Client{Transport: cassette.RoundTripper(nil)}
+
+	// Responses are replayed in the recorded order, then the last one is repeated
+	for i, wantCount := range []int{1, 2, 3, 3} {
+		got := send(t, client, http.MethodGet, server.URL+"/v1/operations/op", "")
+		if want := fmt.Sprintf(`"count":%d}`, wantCount); !strings.HasSuffix(got.body, want) {
+			t.Errorf("replayed response %d = %s, want count %d", i, got.body, wantCount)
+		}
+	}
+}
+
+func TestReplayNoRecordedResponse(t *testing.T) {
+	dir := t.TempDir()
+	fixture := `{"key":"GET https://serviceusage.googleapis.com/v1/projects/alpha/services","method":"GET","status":200,"body":"{}"}`
+	if err := os.WriteFile(filepath.Join(dir, "serviceusage.googleapis.com.jsonl"), []byte(fixture+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cassette, err := Replay(dir)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	client := &http.Client{Transport: cassette.RoundTripper(nil)}
+
+	if got := send(t, client, http.MethodGet, "https://serviceusage.googleapis.com/v1/projects/alpha/services", ""); got.status != http.StatusOK {
+		t.Errorf("recorded request status = %d, want %d", got.status, http.StatusOK)
+	}
+	_, err = client.Get("https://serviceusage.googleapis.com/v1/projects/beta/services")
+	if err == nil || !strings.Contains(err.Error(), "replay: no recorded response for GET https://serviceusage.googleapis.com/v1/projects/beta/services") {
+		t.Errorf("unrecorded request error = %v, want no recorded response", err)
+	}
+}
+
+func TestReplayInvalidFixtures(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{name: "no fixtures", files: map[string]string{"notes.txt": "not a fixture"}, wantErr: "no fixtures (*.jsonl) found"},
+		{name: "invalid line", files: map[string]string{"a.jsonl": "{}\n\nnot json\n"}, wantErr: "a.jsonl:3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := Replay(dir); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Replay() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newTimeSeriesRequest(filter string, end time.Time) *monitoringpb.ListTimeSeriesRequest {
+	return &monitoringpb.ListTimeSeriesRequest{
+		Name:   "projects/alpha",
+		Filter: filter,
+		Interval: &monitoringpb.TimeInterval{
+			StartTime: timestamppb.New(end.Add(-24 * time.Hour)),
+			EndTime:   timestamppb.New(end),
+		},
+	}
+}
+
+func TestGRPCKey(t *testing.T) {
+	const method = "/google.monitoring.v3.MetricService/ListTimeSeries"
+	now := time.Now()
+
+	key := func(req proto.Message) string {
+		t.Helper()
+		key, err := grpcKey(method, req)
+		if err != nil {
+			t.Fatalf("grpcKey() error = %v", err)
+		}
+		return key
+	}
+
+	req := newTimeSeriesRequest(`resource.labels.service = "a"`, now)
+	if got, want := key(req), `grpc `+method+` {"name":"projects/alpha","filter":"resource.labels.service = \"a\""}`; got != want {
+		t.Errorf("grpcKey() = %s, want %s", got, want)
+	}
+	if key(newTimeSeriesRequest(`resource.labels.service = "a"`, now.Add(time.Hour))) != key(req) {
+		t.Error("requests for another interval have different keys")
+	}
+	if key(newTimeSeriesRequest(`resource.labels.service = "b"`, now)) == key(req) {
+		t.Error("requests with another filter have the same key")
+	}
+	if req.Interval == nil {
+		t.Error("grpcKey() cleared the interval of the request")
+	}
+}
+
+// newConn returns a connection to the Monitoring API that is never used: recorded calls go to
+// a fake invoker and replayed calls never reach the connection
+func newConn(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	conn, err := grpc.NewClient("passthrough:///monitoring.googleapis.com:443", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCRecordReplay(t *testing.T) {
+	const method = "/google.monitoring.v3.MetricService/ListTimeSeries"
+	recordedAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	ok := newTimeSeriesRequest(`resource.labels.service = "ok"`, recordedAt)
+	denied := newTimeSeriesRequest(`resource.labels.service = "denied"`, recordedAt)
+
+	// The fake invoker answers ok with a page of time series and denies the other requests
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if req.(*monitoringpb.ListTimeSeriesRequest).Filter != ok.Filter {
+			return status.Error(codes.PermissionDenied, "Permission monitoring.timeSeries.list denied")
+		}
+		proto.Merge(reply.(proto.Message), &monitoringpb.ListTimeSeriesResponse{
+			TimeSeries:    []*monitoringpb.TimeSeries{{Unit: "1"}},
+			NextPageToken: "next",
+		})
+		return nil
+	}
+
+	dir := t.TempDir()
+	recording, err := Record(dir)
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	conn := newConn(t)
+	interceptor := recording.UnaryInterceptor()
+	recordedReply := &monitoringpb.ListTimeSeriesResponse{}
+	if err := interceptor(context.Background(), method, ok, recordedReply, conn, invoker); err != nil {
+		t.Fatalf("recording ok: error = %v", err)
+	}
+	recordedErr := interceptor(context.Background(), method, denied, &monitoringpb.ListTimeSeriesResponse{}, conn, invoker)
+	if status.Code(recordedErr) != codes.PermissionDenied {
+		t.Fatalf("recording denied: error = %v, want %v", recordedErr, codes.PermissionDenied)
+	}
+	closeCassette(t, recording)
+
+	fixture, err := os.ReadFile(filepath.Join(dir, "monitoring.googleapis.com.jsonl"))
+	if err != nil {
+		t.Fatalf("fixture of the Monitoring API: %v", err)
+	}
+	if lines := bytes.Count(fixture, []byte("\n")); lines != 2 {
+		t.Errorf("fixture has %d interactions, want 2", lines)
+	}
+
+	cassette, err := Replay(dir)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	interceptor = cassette.UnaryInterceptor()
+	unreachable := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		t.Error("replayed call reached the connection")
+		return nil
+	}
+	replayedAt := recordedAt.Add(48 * time.Hour)
+
+	reply := &monitoringpb.ListTimeSeriesResponse{}
+	if err := interceptor(context.Background(), method, newTimeSeriesRequest(ok.Filter, replayedAt), reply, conn, unreachable); err != nil {
+		t.Fatalf("replaying ok: error = %v", err)
+	}
+	if !proto.Equal(reply, recordedReply) {
+		t.Errorf("replayed reply = %v, want %v", reply, recordedReply)
+	}
+
+	err = interceptor(context.Background(), method, newTimeSeriesRequest(denied.Filter, replayedAt), &monitoringpb.ListTimeSeriesResponse{}, conn, unreachable)
+	if status.Code(err) != codes.PermissionDenied || status.Convert(err).Message() != status.Convert(recordedErr).Message() {
+		t.Errorf("replaying denied: error = %v, want %v", err, recordedErr)
+	}
+
+	err = interceptor(context.Background(), method, newTimeSeriesRequest(`resource.labels.service = "new"`, replayedAt), &monitoringpb.ListTimeSeriesResponse{}, conn, unreachable)
+	if err == nil || !strings.Contains(err.Error(), "replay: no recorded response for grpc "+method) {
+		t.Errorf("replaying an unrecorded call: error = %v, want no recorded response", err)
+	}
+}
+
+func TestTargetHost(t *testing.T) {
+	tests := map[string]string{
+		"monitoring.googleapis.com:443":                "monitoring.googleapis.com",
+		"dns:///monitoring.googleapis.com:443":         "monitoring.googleapis.com",
+		"passthrough:///monitoring.googleapis.com:443": "monitoring.googleapis.com",
+		"monitoring.googleapis.com":                    "monitoring.googleapis.com",
+	}
+	for target, want := range tests {
+		if got := targetHost(target); got != want {
+			t.Errorf("targetHost(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
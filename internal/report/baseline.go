@@ -0,0 +1,125 @@
+// internal/report/baseline.go
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// LoadBaseline rebuilds the services of the most recent previous run of profile from its
+// projects.json; runs of other profiles that share the output directory are ignored. It returns
+// nil without an error when no previous JSON report of the profile exists.
+func LoadBaseline(outputDir, profile string) (*domain.AuditReport, error) {
+	matches, err := filepath.Glob(filepath.Join(outputDir, "*", "projects.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for previous reports: %w", err)
+	}
+
+	// Run directories are timestamps, so the lexically last one is the latest
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+	latest := ""
+	for _, path := range matches {
+		metadata, err := readRunMetadata(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse previous report %s: %w", path, err)
+		}
+		if metadata.Profile == profile {
+			latest = path
+			break
+		}
+	}
+	if latest == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(latest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous report: %w", err)
+	}
+
+	projectsReport, err := ParseProjectsReport(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse previous report %s: %w", latest, err)
+	}
+	projects := projectsReport.Projects
+
+	baseline := &domain.AuditReport{
+		Services: make(map[string][]domain.Service, len(projects)),
+	}
+	for _, project := range projects {
+		services := make([]domain.Service, 0, len(project.Services))
+		for _, s := range project.Services {
+			services = append(services, domain.Service{
+				Name:      s.Name,
+				Title:     s.Title,
+				State:     s.State,
+				ProjectID: project.ProjectID,
+				Usage: &domain.Usage{
+					RequestCount: s.RequestCount,
+					Status:       baselineStatus(s),
+				},
+			})
+		}
+		baseline.Services[project.ProjectID] = services
+	}
+
+	return baseline, nil
+}
+
+// baselineStatus falls back for reports written before usageStatus was recorded: only
+// services with requests can safely be considered successful lookups
+func baselineStatus(s ProjectService) domain.UsageStatus {
+	if s.UsageStatus != "" {
+		return domain.UsageStatus(s.UsageStatus)
+	}
+	if s.RequestCount > 0 {
+		return domain.UsageStatusSuccess
+	}
+	return ""
+}
+
+// readRunMetadata reads the run metadata at the start of a projects.json without decoding its
+// projects. Reports written before the metadata was recorded have none.
+func readRunMetadata(path string) (RunMetadata, error) {
+	var metadata RunMetadata
+	file, err := os.Open(path)
+	if err != nil {
+		return metadata, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	token, err := decoder.Token()
+	if err != nil {
+		return metadata, err
+	}
+	if token != json.Delim('{') {
+		return metadata, nil
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return metadata, err
+		}
+		switch token {
+		case "runId":
+			err = decoder.Decode(&metadata.RunID)
+		case "profile":
+			err = decoder.Decode(&metadata.Profile)
+		case "projects":
+			return metadata, nil // The metadata is written first
+		default:
+			err = decoder.Decode(&json.RawMessage{})
+		}
+		if err != nil {
+			return metadata, err
+		}
+	}
+	return metadata, nil
+}
@@ -0,0 +1,90 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadBaselineProfile(t *testing.T) {
+	outputDir := t.TempDir()
+	runs := map[string]string{
+		"20240101_000000": `{"runId": "20240101_000000", "profile": "prod", "projects": [{"projectId": "prod-old", "services": []}]}`,
+		"20240102_000000": `{"runId": "20240102_000000", "profile": "prod", "projects": [{"projectId": "prod", "services": [{"name": "bigquery.googleapis.com", "requestCount": 3, "usageStatus": "SUCCESS"}]}]}`,
+		"20240103_000000": `{"runId": "20240103_000000", "profile": "sandbox", "projects": [{"projectId": "sandbox", "services": []}]}`,
+		"20240104_000000": `{"runId": "20240104_000000", "projects": [{"projectId": "default", "services": []}]}`,
+		// Written before reports recorded their run, so it belongs to no profile
+		"20231231_000000": `[{"projectId": "legacy", "services": []}]`,
+	}
+	for run, content := range runs {
+		dir := filepath.Join(outputDir, run)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "projects.json"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		profile     string
+		wantProject string // Only project of the baseline, empty for none
+	}{
+		{profile: "prod", wantProject: "prod"},
+		{profile: "sandbox", wantProject: "sandbox"},
+		{profile: "", wantProject: "default"},
+		{profile: "staging"},
+	}
+
+	for _, tt := range tests {
+		t.Run("profile "+tt.profile, func(t *testing.T) {
+			baseline, err := LoadBaseline(outputDir, tt.profile)
+			if err != nil {
+				t.Fatalf("LoadBaseline() error = %v", err)
+			}
+			if tt.wantProject == "" {
+				if baseline != nil {
+					t.Errorf("LoadBaseline() = %v, want no baseline", baseline.Services)
+				}
+				return
+			}
+			if baseline == nil {
+				t.Fatal("LoadBaseline() = nil")
+			}
+			if _, ok := baseline.Services[tt.wantProject]; !ok || len(baseline.Services) != 1 {
+				t.Errorf("baseline projects = %v, want only %s", baseline.Services, tt.wantProject)
+			}
+		})
+	}
+
+	baseline, err := LoadBaseline(outputDir, "prod")
+	if err != nil || baseline == nil {
+		t.Fatalf("LoadBaseline() = %v, %v", baseline, err)
+	}
+	services := baseline.Services["prod"]
+	if len(services) != 1 || services[0].Usage.RequestCount != 3 || services[0].Usage.Status != "SUCCESS" {
+		t.Errorf("prod services = %+v, want bigquery with 3 requests", services)
+	}
+}
+
+func TestLoadBaselineLegacyReport(t *testing.T) {
+	outputDir := t.TempDir()
+	dir := filepath.Join(outputDir, "20231231_000000")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := `[{"projectId": "legacy", "services": [{"name": "storage.googleapis.com", "requestCount": 0}]}]`
+	if err := os.WriteFile(filepath.Join(dir, "projects.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline, err := LoadBaseline(outputDir, "")
+	if err != nil || baseline == nil {
+		t.Fatalf("LoadBaseline() = %v, %v", baseline, err)
+	}
+	services := baseline.Services["legacy"]
+	// Without a recorded status, a service without requests is not known to be unused
+	if len(services) != 1 || services[0].Usage.Status != "" {
+		t.Errorf("legacy services = %+v, want storage without a usage status", services)
+	}
+}
@@ -0,0 +1,234 @@
+// internal/report/csv.go
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+type CSVReporter struct {
+	outputDir string
+}
+
+var servicesCSVHeader = []string{
+	"project_id",
+	"service",
+	"title",
+	"state",
+	"request_count",
+	"usage_status",
+	"error",
+}
+
+func NewCSVReporter(outputDir string) *CSVReporter {
+	return &CSVReporter{
+		outputDir: outputDir,
+	}
+}
+
+func (r *CSVReporter) GenerateReport(report domain.AuditReport) error {
+	reportDir := runDir(r.outputDir, report)
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	if err := r.writeCSV(filepath.Join(reportDir, "services.csv"), report, r.servicesRows(report)); err != nil {
+		return fmt.Errorf("failed to write services CSV: %w", err)
+	}
+
+	if err := r.writeCSV(filepath.Join(reportDir, "projects.csv"), report, r.projectsRows(report)); err != nil {
+		return fmt.Errorf("failed to write projects CSV: %w", err)
+	}
+
+	if !report.Statistics.GroupBy.IsZero() {
+		if err := r.writeCSV(filepath.Join(reportDir, "groups.csv"), report, r.groupsRows(report.Statistics)); err != nil {
+			return fmt.Errorf("failed to write groups CSV: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// groupsRows returns one row per group rollup. Projects without a group come last,
+// with an empty group and group ID and ungrouped set to true.
+func (r *CSVReporter) groupsRows(stats domain.AuditStatistics) [][]string {
+	rows := [][]string{{
+		"group_by",
+		"group",
+		"group_id",
+		"ungrouped",
+		"projects",
+		"skipped_projects",
+		"enabled_services",
+		"active_services",
+		"inactive_services",
+		"total_requests",
+	}}
+
+	rollups := stats.Groups
+	if stats.Ungrouped != nil {
+		rollups = append(rollups[:len(rollups):len(rollups)], stats.Ungrouped)
+	}
+
+	for _, group := range rollups {
+		rows = append(rows, []string{
+			stats.GroupBy.String(),
+			group.Name,
+			group.ID,
+			strconv.FormatBool(group == stats.Ungrouped),
+			strconv.Itoa(len(group.Projects)),
+			strconv.Itoa(group.SkippedProjects),
+			strconv.Itoa(group.EnabledServices),
+			strconv.Itoa(group.ActiveServices),
+			strconv.Itoa(group.InactiveServices),
+			strconv.FormatInt(group.TotalRequests, 10),
+		})
+	}
+
+	return rows
+}
+
+// servicesRows returns one row per project/service pair, sorted by project and service name
+func (r *CSVReporter) servicesRows(report domain.AuditReport) [][]string {
+	rows := [][]string{servicesCSVHeader}
+
+	for _, projectID := range sortedProjectIDs(report.Services) {
+		services := sortedServices(report.Services[projectID])
+		for _, service := range services {
+			var requestCount, status, errMsg string
+			if service.Usage != nil {
+				requestCount = strconv.FormatInt(service.Usage.RequestCount, 10)
+				status = string(service.Usage.Status)
+				errMsg = service.Usage.Error
+			}
+
+			rows = append(rows, []string{
+				projectID,
+				service.Name,
+				service.Title,
+				service.State,
+				requestCount,
+				status,
+				errMsg,
+			})
+		}
+	}
+
+	return rows
+}
+
+// projectsRows returns one row per audited project with service totals.
+// Every label key found across projects becomes its own "label:<key>" column.
+func (r *CSVReporter) projectsRows(report domain.AuditReport) [][]string {
+	labelKeys := collectLabelKeys(report.Projects)
+
+	header := []string{
+		"project_id",
+		"name",
+		"project_number",
+		"create_time",
+		"total_services",
+		"active_services",
+		"inactive_services",
+		"no_access_services",
+		"error_services",
+		"total_requests",
+		"processing_time_seconds",
+		"skipped_error",
+	}
+	for _, key := range labelKeys {
+		header = append(header, "label:"+key)
+	}
+	rows := [][]string{header}
+
+	projects := make([]domain.Project, len(report.Projects))
+	copy(projects, report.Projects)
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].ID < projects[j].ID
+	})
+
+	for _, project := range projects {
+		stats := calculateProjectStats(report.Services[project.ID])
+
+		var createTime, skippedErr string
+		if !project.CreateTime.IsZero() {
+			createTime = project.CreateTime.Format(time.RFC3339)
+		}
+		if err, skipped := report.SkippedProjects[project.ID]; skipped && err != nil {
+			skippedErr = err.Error()
+		}
+
+		row := []string{
+			project.ID,
+			project.Name,
+			strconv.FormatInt(project.ProjectNum, 10),
+			createTime,
+			strconv.Itoa(stats.TotalServices),
+			strconv.Itoa(stats.ActiveServices),
+			strconv.Itoa(stats.InactiveServices),
+			strconv.Itoa(stats.NoAccessServices),
+			strconv.Itoa(stats.ErrorServices),
+			strconv.FormatInt(stats.TotalRequests, 10),
+			strconv.FormatFloat(report.ProjectDurations[project.ID].Seconds(), 'f', 3, 64),
+			skippedErr,
+		}
+		for _, key := range labelKeys {
+			row = append(row, project.Labels[key])
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// writeCSV writes rows, whose first row is the header, after the run_id and profile columns
+// identifying the audit run
+func (r *CSVReporter) writeCSV(filename string, report domain.AuditReport, rows [][]string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	defer file.Close()
+
+	runColumns := []string{report.RunID(), report.Profile}
+	for i, row := range rows {
+		if i == 0 {
+			rows[i] = append([]string{"run_id", "profile"}, row...)
+		} else {
+			rows[i] = append(runColumns[:2:2], row...)
+		}
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write CSV rows: %w", err)
+	}
+
+	return nil
+}
+
+// collectLabelKeys returns the sorted union of label keys across all projects
+func collectLabelKeys(projects []domain.Project) []string {
+	seen := make(map[string]bool)
+	for _, project := range projects {
+		for key := range project.Labels {
+			seen[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
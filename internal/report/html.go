@@ -0,0 +1,191 @@
+// internal/report/html.go
+package report
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+//go:embed templates/report.html
+var htmlTemplate string
+
+type HTMLReporter struct {
+	outputDir string
+	tmpl      *template.Template
+}
+
+// htmlData is the dataset embedded into the HTML report and rendered client-side
+type htmlData struct {
+	Meta     htmlMeta      `json:"meta"`
+	Projects []htmlProject `json:"projects"`
+	Services []htmlService `json:"services"`
+	Groups   *GroupsReport `json:"groups,omitempty"`
+}
+
+type htmlMeta struct {
+	Profile             string `json:"profile,omitempty"`
+	StartTime           string `json:"startTime"`
+	GeneratedAt         string `json:"generatedAt"`
+	PeriodDays          int    `json:"periodDays"`
+	TotalProjects       int    `json:"totalProjects"`
+	ValidProjects       int    `json:"validProjects"`
+	ExcludedProjects    int    `json:"excludedProjects"`
+	SkippedProjects     int    `json:"skippedProjects"`
+	UniqueServices      int    `json:"uniqueServices"`
+	ServicesWithNoUsage int    `json:"servicesWithNoUsage"`
+}
+
+type htmlProject struct {
+	ID               string               `json:"id"`
+	Name             string               `json:"name"`
+	Labels           map[string]string    `json:"labels,omitempty"`
+	TotalServices    int                  `json:"totalServices"`
+	ActiveServices   int                  `json:"activeServices"`
+	InactiveServices int                  `json:"inactiveServices"`
+	NoAccessServices int                  `json:"noAccessServices"`
+	ErrorServices    int                  `json:"errorServices"`
+	TotalRequests    int64                `json:"totalRequests"`
+	DurationSeconds  float64              `json:"durationSeconds"`
+	SkippedError     string               `json:"skippedError,omitempty"`
+	Services         []htmlProjectService `json:"services"`
+}
+
+type htmlProjectService struct {
+	Name         string `json:"name"`
+	Title        string `json:"title,omitempty"`
+	State        string `json:"state"`
+	RequestCount int64  `json:"requestCount"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+}
+
+type htmlService struct {
+	Name          string   `json:"name"`
+	ProjectCount  int      `json:"projectCount"`
+	TotalRequests int64    `json:"totalRequests"`
+	EnabledIn     []string `json:"enabledIn"`
+}
+
+func NewHTMLReporter(outputDir string) *HTMLReporter {
+	return &HTMLReporter{
+		outputDir: outputDir,
+		tmpl:      template.Must(template.New("report").Parse(htmlTemplate)),
+	}
+}
+
+func (r *HTMLReporter) GenerateReport(report domain.AuditReport) error {
+	reportDir := runDir(r.outputDir, report)
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	data, err := json.Marshal(r.buildData(report))
+	if err != nil {
+		return fmt.Errorf("failed to marshal report data: %w", err)
+	}
+
+	file, err := os.Create(filepath.Join(reportDir, "report.html"))
+	if err != nil {
+		return fmt.Errorf("failed to create HTML report file: %w", err)
+	}
+	defer file.Close()
+
+	// The JSON payload is passed as a string so html/template escapes it as a JS string literal
+	err = r.tmpl.Execute(file, struct {
+		GeneratedAt string
+		Data        string
+	}{
+		GeneratedAt: report.GeneratedAt.Format(time.RFC3339),
+		Data:        string(data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render HTML report: %w", err)
+	}
+
+	return nil
+}
+
+func (r *HTMLReporter) buildData(report domain.AuditReport) htmlData {
+	data := htmlData{
+		Meta: htmlMeta{
+			Profile:             report.Profile,
+			StartTime:           report.StartTime.Format(time.RFC3339),
+			GeneratedAt:         report.GeneratedAt.Format(time.RFC3339),
+			PeriodDays:          int(report.Period / (24 * time.Hour)),
+			TotalProjects:       report.Statistics.TotalProjects,
+			ValidProjects:       report.Statistics.ValidProjects,
+			ExcludedProjects:    report.Statistics.ExcludedProjects,
+			SkippedProjects:     report.Statistics.SkippedProjects,
+			UniqueServices:      report.Statistics.UniqueServices,
+			ServicesWithNoUsage: report.Statistics.ServicesWithNoUsage,
+		},
+		Projects: make([]htmlProject, 0, len(report.Projects)),
+		Services: make([]htmlService, 0, len(report.Statistics.ServiceDetails)),
+	}
+
+	for _, project := range report.Projects {
+		services := sortedServices(report.Services[project.ID])
+		stats := calculateProjectStats(services)
+
+		p := htmlProject{
+			ID:               project.ID,
+			Name:             project.Name,
+			Labels:           project.Labels,
+			TotalServices:    stats.TotalServices,
+			ActiveServices:   stats.ActiveServices,
+			InactiveServices: stats.InactiveServices,
+			NoAccessServices: stats.NoAccessServices,
+			ErrorServices:    stats.ErrorServices,
+			TotalRequests:    stats.TotalRequests,
+			DurationSeconds:  report.ProjectDurations[project.ID].Seconds(),
+			Services:         make([]htmlProjectService, 0, len(services)),
+		}
+		if err, skipped := report.SkippedProjects[project.ID]; skipped && err != nil {
+			p.SkippedError = err.Error()
+		}
+
+		for _, service := range services {
+			s := htmlProjectService{
+				Name:  service.Name,
+				Title: service.Title,
+				State: service.State,
+			}
+			if service.Usage != nil {
+				s.RequestCount = service.Usage.RequestCount
+				s.Status = string(service.Usage.Status)
+				s.Error = service.Usage.Error
+			}
+			p.Services = append(p.Services, s)
+		}
+
+		data.Projects = append(data.Projects, p)
+	}
+
+	sort.Slice(data.Projects, func(i, j int) bool {
+		return data.Projects[i].ID < data.Projects[j].ID
+	})
+
+	for _, detail := range report.Statistics.ServiceDetails {
+		data.Services = append(data.Services, htmlService{
+			Name:          detail.Name,
+			ProjectCount:  detail.ProjectCount,
+			TotalRequests: detail.TotalRequests,
+			EnabledIn:     detail.EnabledIn,
+		})
+	}
+
+	if !report.Statistics.GroupBy.IsZero() {
+		groups := newGroupsReport(report.Statistics)
+		data.Groups = &groups
+	}
+
+	return data
+}
@@ -2,22 +2,72 @@
 package report
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/ybonda/gcp-auditor/internal/domain"
 )
 
 type JSONReporter struct {
 	outputDir string
+	stream    *jsonStream // Report being streamed, nil outside of Begin and GenerateReport
+}
+
+// jsonStream is a JSON report written while the audit runs. Projects go straight to
+// projects.json; services.json is ordered by service, so its rows are spooled to a
+// temporary file until the audit ends.
+type jsonStream struct {
+	reportDir    string
+	metadata     RunMetadata
+	projectsFile *os.File
+	projects     *jsonArrayWriter
+	spoolFile    *os.File
+	spool        *bufio.Writer
+	spoolSize    int64
+	services     map[string]*spooledService
+}
+
+// spooledService locates the usage rows of a service in the spool file. The index keeps one
+// entry per project that enables the service, a few bytes instead of the row itself
+type spooledService struct {
+	title string
+	rows  []spoolRow
+}
+
+type spoolRow struct {
+	offset int64
+	length int
+}
+
+// RunMetadata identifies the audit run a JSON report belongs to
+type RunMetadata struct {
+	RunID   string `json:"runId"`
+	Profile string `json:"profile,omitempty"` // Config file profile the audit ran with
+}
+
+// ProjectsReport is the document written to projects.json
+type ProjectsReport struct {
+	RunMetadata
+	Projects []ProjectReport `json:"projects"`
+}
+
+// ServicesReport is the document written to services.json
+type ServicesReport struct {
+	RunMetadata
+	Services []ServiceReport `json:"services"`
 }
 
 // ServiceUsage represents service usage in a specific project
 type ServiceUsage struct {
 	ProjectID    string `json:"projectId"`
 	RequestCount int64  `json:"requestCount"`
+	UsageStatus  string `json:"usageStatus,omitempty"`
 	State        string `json:"state"`
 	LastUpdated  string `json:"lastUpdated,omitempty"`
 }
@@ -34,6 +84,7 @@ type ProjectService struct {
 	Name         string `json:"name"`
 	Title        string `json:"title,omitempty"`
 	RequestCount int64  `json:"requestCount"`
+	UsageStatus  string `json:"usageStatus,omitempty"`
 	State        string `json:"state"`
 	LastUpdated  string `json:"lastUpdated,omitempty"`
 }
@@ -41,9 +92,42 @@ type ProjectService struct {
 // ProjectReport represents the structure for project-based report
 type ProjectReport struct {
 	ProjectID string           `json:"projectId"`
+	Hygiene   *HygieneReport   `json:"hygiene,omitempty"`
 	Services  []ProjectService `json:"services"`
 }
 
+// HygieneReport represents the hygiene score of a project and the factors behind it
+type HygieneReport struct {
+	Score   float64         `json:"score"`
+	Factors []HygieneFactor `json:"factors"`
+}
+
+type HygieneFactor struct {
+	Name    string  `json:"name"`
+	Weight  float64 `json:"weight"`
+	Penalty float64 `json:"penalty"`
+}
+
+// GroupRollupReport represents the rollup of the projects in a group
+type GroupRollupReport struct {
+	ID               string   `json:"id,omitempty"`
+	Name             string   `json:"name,omitempty"`
+	Projects         []string `json:"projects"`
+	SkippedProjects  int      `json:"skippedProjects"`
+	EnabledServices  int      `json:"enabledServices"`
+	ActiveServices   int      `json:"activeServices"`
+	InactiveServices int      `json:"inactiveServices"`
+	TotalRequests    int64    `json:"totalRequests"`
+}
+
+// GroupsReport represents the structure for the group rollups report
+type GroupsReport struct {
+	RunMetadata
+	GroupBy   string              `json:"groupBy"`
+	Groups    []GroupRollupReport `json:"groups"`
+	Ungrouped *GroupRollupReport  `json:"ungrouped,omitempty"`
+}
+
 func NewJSONReporter(outputDir string) *JSONReporter {
 	return &JSONReporter{
 		outputDir: outputDir,
@@ -51,32 +135,265 @@ func NewJSONReporter(outputDir string) *JSONReporter {
 }
 
 func (r *JSONReporter) GenerateReport(report domain.AuditReport) error {
+	if r.stream != nil {
+		return r.finishStream(report)
+	}
+
 	// Create reports directory with timestamp
-	timestamp := report.GeneratedAt.Format("20060102_150405")
-	reportDir := filepath.Join(r.outputDir, timestamp)
+	reportDir := runDir(r.outputDir, report)
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
 
 	// Generate service-centric report
-	servicesReport := r.generateServicesReport(report)
+	servicesReport := ServicesReport{
+		RunMetadata: newRunMetadata(report),
+		Services:    r.generateServicesReport(report),
+	}
 	if err := r.writeJSONReport(filepath.Join(reportDir, "services.json"), servicesReport); err != nil {
 		return fmt.Errorf("failed to write services report: %w", err)
 	}
 
 	// Generate project-centric report
-	projectsReport := r.generateProjectsReport(report)
+	projectsReport := ProjectsReport{
+		RunMetadata: newRunMetadata(report),
+		Projects:    r.generateProjectsReport(report),
+	}
 	if err := r.writeJSONReport(filepath.Join(reportDir, "projects.json"), projectsReport); err != nil {
 		return fmt.Errorf("failed to write projects report: %w", err)
 	}
 
+	return r.writeGroupsReport(reportDir, report)
+}
+
+func newRunMetadata(report domain.AuditReport) RunMetadata {
+	return RunMetadata{
+		RunID:   report.RunID(),
+		Profile: report.Profile,
+	}
+}
+
+// ParseProjectsReport parses projects.json. Reports written before the run metadata was
+// recorded hold the bare list of projects.
+func ParseProjectsReport(data []byte) (ProjectsReport, error) {
+	var projectsReport ProjectsReport
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &projectsReport.Projects)
+		return projectsReport, err
+	}
+	err := json.Unmarshal(data, &projectsReport)
+	return projectsReport, err
+}
+
+// Begin starts streaming the report: projects.json is written as projects complete
+func (r *JSONReporter) Begin(report domain.AuditReport) error {
+	reportDir := runDir(r.outputDir, report)
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	projectsFile, err := os.Create(filepath.Join(reportDir, "projects.json"))
+	if err != nil {
+		return fmt.Errorf("failed to create projects report: %w", err)
+	}
+	projects, err := newJSONDocumentWriter(projectsFile, ProjectsReport{RunMetadata: newRunMetadata(report)})
+	if err != nil {
+		projectsFile.Close()
+		return err
+	}
+	spoolFile, err := os.CreateTemp(reportDir, ".services-*.spool")
+	if err != nil {
+		projectsFile.Close()
+		return fmt.Errorf("failed to create services spool: %w", err)
+	}
+
+	r.stream = &jsonStream{
+		reportDir:    reportDir,
+		metadata:     newRunMetadata(report),
+		projectsFile: projectsFile,
+		projects:     projects,
+		spoolFile:    spoolFile,
+		spool:        bufio.NewWriter(spoolFile),
+		services:     make(map[string]*spooledService),
+	}
+	return nil
+}
+
+// AddProject appends a completed project to projects.json and spools its service usage
+func (r *JSONReporter) AddProject(result domain.ProjectResult) error {
+	stream := r.stream
+	if result.Err != nil {
+		return nil
+	}
+
+	projectReport := newProjectReport(result.Project.ID, result.Services, newHygieneScoreReport(result.Hygiene))
+	if err := stream.projects.add(projectReport); err != nil {
+		return fmt.Errorf("failed to write projects report: %w", err)
+	}
+
+	for _, service := range result.Services {
+		row, err := json.Marshal(newServiceUsage(result.Project.ID, service))
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		if _, err := stream.spool.Write(row); err != nil {
+			return fmt.Errorf("failed to spool services report: %w", err)
+		}
+
+		spooled, exists := stream.services[service.Name]
+		if !exists {
+			spooled = &spooledService{title: service.Title}
+			stream.services[service.Name] = spooled
+		}
+		spooled.rows = append(spooled.rows, spoolRow{offset: stream.spoolSize, length: len(row)})
+		stream.spoolSize += int64(len(row))
+	}
+
+	return nil
+}
+
+// finishStream completes projects.json and writes the spooled services.json and the group
+// rollups
+func (r *JSONReporter) finishStream(report domain.AuditReport) error {
+	stream := r.stream
+	r.stream = nil
+	defer stream.close()
+
+	if err := stream.projects.close(); err != nil {
+		return fmt.Errorf("failed to write projects report: %w", err)
+	}
+	if err := stream.projectsFile.Close(); err != nil {
+		return fmt.Errorf("failed to write projects report: %w", err)
+	}
+
+	if err := stream.writeServicesReport(filepath.Join(stream.reportDir, "services.json")); err != nil {
+		return fmt.Errorf("failed to write services report: %w", err)
+	}
+
+	return r.writeGroupsReport(stream.reportDir, report)
+}
+
+// writeServicesReport writes services.json from the spool, one service at a time
+func (s *jsonStream) writeServicesReport(filename string) error {
+	if err := s.spool.Flush(); err != nil {
+		return fmt.Errorf("failed to spool services report: %w", err)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create JSON file: %w", err)
+	}
+	defer file.Close()
+
+	names := make([]string, 0, len(s.services))
+	for name := range s.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	services, err := newJSONDocumentWriter(file, ServicesReport{RunMetadata: s.metadata})
+	if err != nil {
+		return err
+	}
+	var buffer []byte
+	for _, name := range names {
+		spooled := s.services[name]
+		serviceReport := ServiceReport{
+			Name:     name,
+			Title:    spooled.title,
+			Projects: make([]ServiceUsage, len(spooled.rows)),
+		}
+		for i, row := range spooled.rows {
+			if cap(buffer) < row.length {
+				buffer = make([]byte, row.length)
+			}
+			buffer = buffer[:row.length]
+			if _, err := s.spoolFile.ReadAt(buffer, row.offset); err != nil {
+				return fmt.Errorf("failed to read services spool: %w", err)
+			}
+			if err := json.Unmarshal(buffer, &serviceReport.Projects[i]); err != nil {
+				return fmt.Errorf("failed to read services spool: %w", err)
+			}
+		}
+		if err := services.add(serviceReport); err != nil {
+			return err
+		}
+	}
+
+	if err := services.close(); err != nil {
+		return fmt.Errorf("failed to write JSON file: %w", err)
+	}
+	return file.Close()
+}
+
+// Abort discards the report being streamed, so that the reporter can Begin again
+func (r *JSONReporter) Abort() {
+	if r.stream == nil {
+		return
+	}
+	stream := r.stream
+	r.stream = nil
+	stream.close()
+	os.Remove(stream.projectsFile.Name())
+	os.Remove(stream.reportDir) // Only removed when no other report is left in it
+}
+
+// close releases the files of the stream and removes the spool
+func (s *jsonStream) close() {
+	s.projectsFile.Close()
+	s.spoolFile.Close()
+	os.Remove(s.spoolFile.Name())
+}
+
+func (r *JSONReporter) writeGroupsReport(reportDir string, report domain.AuditReport) error {
+	if report.Statistics.GroupBy.IsZero() {
+		return nil
+	}
+	groupsReport := newGroupsReport(report.Statistics)
+	groupsReport.RunMetadata = newRunMetadata(report)
+	if err := r.writeJSONReport(filepath.Join(reportDir, "groups.json"), groupsReport); err != nil {
+		return fmt.Errorf("failed to write groups report: %w", err)
+	}
 	return nil
 }
 
+func newGroupsReport(stats domain.AuditStatistics) GroupsReport {
+	groupsReport := GroupsReport{
+		GroupBy: stats.GroupBy.String(),
+		Groups:  make([]GroupRollupReport, 0, len(stats.Groups)),
+	}
+
+	for _, group := range stats.Groups {
+		groupsReport.Groups = append(groupsReport.Groups, newGroupRollupReport(group))
+	}
+	if stats.Ungrouped != nil {
+		ungrouped := newGroupRollupReport(stats.Ungrouped)
+		groupsReport.Ungrouped = &ungrouped
+	}
+
+	return groupsReport
+}
+
+func newGroupRollupReport(group *domain.GroupRollup) GroupRollupReport {
+	return GroupRollupReport{
+		ID:               group.ID,
+		Name:             group.Name,
+		Projects:         group.Projects,
+		SkippedProjects:  group.SkippedProjects,
+		EnabledServices:  group.EnabledServices,
+		ActiveServices:   group.ActiveServices,
+		InactiveServices: group.InactiveServices,
+		TotalRequests:    group.TotalRequests,
+	}
+}
+
 func (r *JSONReporter) generateServicesReport(report domain.AuditReport) []ServiceReport {
 	// Map to collect all services
 	serviceMap := make(map[string]*ServiceReport)
 
 	// Process all projects and their services
-	for projectID, services := range report.Services {
-		for _, service := range services {
+	for _, projectID := range sortedProjectIDs(report.Services) {
+		for _, service := range report.Services[projectID] {
 			// Get or create service report
 			serviceRep, exists := serviceMap[service.Name]
 			if !exists {
@@ -88,27 +405,18 @@ func (r *JSONReporter) generateServicesReport(report domain.AuditReport) []Servi
 			}
 
 			// Add project usage
-			usage := ServiceUsage{
-				ProjectID: projectID,
-				State:     service.State,
-			}
-
-			if service.Usage != nil {
-				usage.RequestCount = service.Usage.RequestCount
-				if !service.Usage.LastUpdated.IsZero() {
-					usage.LastUpdated = service.Usage.LastUpdated.Format("2006-01-02T15:04:05Z")
-				}
-			}
-
-			serviceRep.Projects = append(serviceRep.Projects, usage)
+			serviceRep.Projects = append(serviceRep.Projects, newServiceUsage(projectID, service))
 		}
 	}
 
-	// Convert map to slice
+	// Convert map to slice ordered by service name
 	services := make([]ServiceReport, 0, len(serviceMap))
 	for _, service := range serviceMap {
 		services = append(services, *service)
 	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
 
 	return services
 }
@@ -117,34 +425,80 @@ func (r *JSONReporter) generateProjectsReport(report domain.AuditReport) []Proje
 	projects := make([]ProjectReport, 0)
 
 	// Process each project
-	for projectID, services := range report.Services {
-		projectReport := ProjectReport{
-			ProjectID: projectID,
-			Services:  make([]ProjectService, 0, len(services)),
+	for _, projectID := range sortedProjectIDs(report.Services) {
+		projects = append(projects, newProjectReport(projectID, report.Services[projectID], newHygieneReport(report.Hygiene, projectID)))
+	}
+
+	return projects
+}
+
+func newServiceUsage(projectID string, service domain.Service) ServiceUsage {
+	usage := ServiceUsage{
+		ProjectID: projectID,
+		State:     service.State,
+	}
+
+	if service.Usage != nil {
+		usage.RequestCount = service.Usage.RequestCount
+		usage.UsageStatus = string(service.Usage.Status)
+		if !service.Usage.LastUpdated.IsZero() {
+			usage.LastUpdated = service.Usage.LastUpdated.Format("2006-01-02T15:04:05Z")
 		}
+	}
 
-		// Add each service
-		for _, service := range services {
-			projectService := ProjectService{
-				Name:  service.Name,
-				Title: service.Title,
-				State: service.State,
-			}
+	return usage
+}
 
-			if service.Usage != nil {
-				projectService.RequestCount = service.Usage.RequestCount
-				if !service.Usage.LastUpdated.IsZero() {
-					projectService.LastUpdated = service.Usage.LastUpdated.Format("2006-01-02T15:04:05Z")
-				}
-			}
+func newProjectReport(projectID string, services []domain.Service, hygiene *HygieneReport) ProjectReport {
+	projectReport := ProjectReport{
+		ProjectID: projectID,
+		Hygiene:   hygiene,
+		Services:  make([]ProjectService, 0, len(services)),
+	}
 
-			projectReport.Services = append(projectReport.Services, projectService)
+	// Add each service
+	for _, service := range services {
+		projectService := ProjectService{
+			Name:  service.Name,
+			Title: service.Title,
+			State: service.State,
+		}
+
+		if service.Usage != nil {
+			projectService.RequestCount = service.Usage.RequestCount
+			projectService.UsageStatus = string(service.Usage.Status)
+			if !service.Usage.LastUpdated.IsZero() {
+				projectService.LastUpdated = service.Usage.LastUpdated.Format("2006-01-02T15:04:05Z")
+			}
 		}
 
-		projects = append(projects, projectReport)
+		projectReport.Services = append(projectReport.Services, projectService)
 	}
 
-	return projects
+	return projectReport
+}
+
+func newHygieneReport(scores map[string]domain.HygieneScore, projectID string) *HygieneReport {
+	score, exists := scores[projectID]
+	if !exists {
+		return nil
+	}
+	return newHygieneScoreReport(score)
+}
+
+func newHygieneScoreReport(score domain.HygieneScore) *HygieneReport {
+	hygiene := &HygieneReport{
+		Score:   score.Score,
+		Factors: make([]HygieneFactor, 0, len(score.Factors)),
+	}
+	for _, factor := range score.Factors {
+		hygiene.Factors = append(hygiene.Factors, HygieneFactor{
+			Name:    factor.Name,
+			Weight:  factor.Weight,
+			Penalty: factor.Penalty,
+		})
+	}
+	return hygiene
 }
 
 func (r *JSONReporter) writeJSONReport(filepath string, data interface{}) error {
@@ -159,3 +513,64 @@ func (r *JSONReporter) writeJSONReport(filepath string, data interface{}) error
 
 	return nil
 }
+
+// jsonArrayWriter writes a JSON array one element at a time, formatted like
+// json.MarshalIndent with a two space indent. The array may be nested in a document,
+// which the writer completes on close.
+type jsonArrayWriter struct {
+	writer *bufio.Writer
+	indent string // Indent of the array within the document
+	footer string // Rest of the document after the array
+	count  int
+}
+
+func newJSONArrayWriter(w io.Writer) *jsonArrayWriter {
+	return &jsonArrayWriter{writer: bufio.NewWriter(w)}
+}
+
+// newJSONDocumentWriter starts writing document, whose last field must be a nil slice. The
+// elements added to the writer make up that field.
+func newJSONDocumentWriter(w io.Writer, document interface{}) (*jsonArrayWriter, error) {
+	jsonData, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	placeholder := bytes.LastIndex(jsonData, []byte("null"))
+	if placeholder < 0 {
+		return nil, fmt.Errorf("JSON document has no array to stream")
+	}
+
+	a := newJSONArrayWriter(w)
+	a.indent = "  "
+	a.footer = string(jsonData[placeholder+len("null"):])
+	a.writer.Write(jsonData[:placeholder])
+	return a, nil
+}
+
+func (a *jsonArrayWriter) add(element interface{}) error {
+	jsonData, err := json.MarshalIndent(element, a.indent+"  ", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	separator := ",\n" + a.indent + "  "
+	if a.count == 0 {
+		separator = "[\n" + a.indent + "  "
+	}
+	a.writer.WriteString(separator)
+	a.writer.Write(jsonData)
+	a.count++
+
+	// Write errors are kept by the buffered writer and returned by close
+	return nil
+}
+
+func (a *jsonArrayWriter) close() error {
+	if a.count == 0 {
+		a.writer.WriteString("[]")
+	} else {
+		a.writer.WriteString("\n" + a.indent + "]")
+	}
+	a.writer.WriteString(a.footer)
+	return a.writer.Flush()
+}
@@ -14,6 +14,16 @@ import (
 
 type MarkdownReporter struct {
 	outputDir string
+	stream    *markdownStream // Report being streamed, nil outside of Begin and GenerateReport
+}
+
+// markdownStream is a Markdown report written while the audit runs. Project reports are
+// written as projects complete; report.md only needs their overview rows.
+type markdownStream struct {
+	reportDir   string
+	projectsDir string
+	run         RunMetadata
+	projects    []projectOverview
 }
 
 type projectOverview struct {
@@ -21,6 +31,7 @@ type projectOverview struct {
 	TotalServices  int
 	ActiveServices int
 	Duration       time.Duration
+	Hygiene        string
 }
 
 func NewMarkdownReporter(outputDir string) *MarkdownReporter {
@@ -30,27 +41,33 @@ func NewMarkdownReporter(outputDir string) *MarkdownReporter {
 }
 
 func (r *MarkdownReporter) GenerateReport(report domain.AuditReport) error {
-	// Create timestamped directory
-	timestamp := time.Now().Format("20060102_150405")
-	reportDir := filepath.Join(r.outputDir, timestamp)
-	if err := os.MkdirAll(reportDir, 0755); err != nil {
-		return fmt.Errorf("failed to create report directory: %w", err)
+	if r.stream != nil {
+		stream := r.stream
+		r.stream = nil
+		if err := r.generateMainReport(stream.reportDir, stream.projectsDir, report, stream.projects); err != nil {
+			return fmt.Errorf("failed to generate main report: %w", err)
+		}
+		return nil
 	}
 
-	// Create projects directory
-	projectsDir := filepath.Join(reportDir, "projects_report")
-	if err := os.MkdirAll(projectsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create projects directory: %w", err)
+	reportDir, projectsDir, err := r.createDirs(report)
+	if err != nil {
+		return err
 	}
 
 	// Generate main report
-	if err := r.generateMainReport(reportDir, projectsDir, report); err != nil {
+	projects := make([]projectOverview, 0, len(report.Services))
+	for projectID, services := range report.Services {
+		projects = append(projects, newProjectOverview(projectID, services,
+			report.ProjectDurations[projectID], formatHygieneScore(report.Hygiene, projectID)))
+	}
+	if err := r.generateMainReport(reportDir, projectsDir, report, projects); err != nil {
 		return fmt.Errorf("failed to generate main report: %w", err)
 	}
 
 	// Generate individual project reports
 	for projectID, services := range report.Services {
-		if err := r.generateProjectReport(projectsDir, projectID, services, report.GeneratedAt); err != nil {
+		if err := r.generateProjectReport(projectsDir, newRunMetadata(report), projectID, services, report.Hygiene[projectID], report.GeneratedAt); err != nil {
 			return fmt.Errorf("failed to generate project report for %s: %w", projectID, err)
 		}
 	}
@@ -58,7 +75,84 @@ func (r *MarkdownReporter) GenerateReport(report domain.AuditReport) error {
 	return nil
 }
 
-func (r *MarkdownReporter) generateMainReport(reportDir, projectsDir string, report domain.AuditReport) error {
+// Begin starts streaming the report: project reports are written as projects complete
+func (r *MarkdownReporter) Begin(report domain.AuditReport) error {
+	reportDir, projectsDir, err := r.createDirs(report)
+	if err != nil {
+		return err
+	}
+	r.stream = &markdownStream{
+		reportDir:   reportDir,
+		projectsDir: projectsDir,
+		run:         newRunMetadata(report),
+		projects:    make([]projectOverview, 0, len(report.Projects)),
+	}
+	return nil
+}
+
+// AddProject writes the report of a completed project. The report is dated when the
+// project completed, since the run has not ended yet.
+func (r *MarkdownReporter) AddProject(result domain.ProjectResult) error {
+	if result.Err != nil {
+		return nil
+	}
+
+	projectID := result.Project.ID
+	if err := r.generateProjectReport(r.stream.projectsDir, r.stream.run, projectID, result.Services, result.Hygiene, time.Now()); err != nil {
+		return fmt.Errorf("failed to generate project report for %s: %w", projectID, err)
+	}
+	r.stream.projects = append(r.stream.projects, newProjectOverview(projectID, result.Services,
+		result.Duration, fmt.Sprintf("%.1f", result.Hygiene.Score)))
+	return nil
+}
+
+// Abort discards the report being streamed, so that the reporter can Begin again
+func (r *MarkdownReporter) Abort() {
+	if r.stream == nil {
+		return
+	}
+	stream := r.stream
+	r.stream = nil
+	os.RemoveAll(stream.projectsDir)
+	os.Remove(stream.reportDir) // Only removed when no other report is left in it
+}
+
+// createDirs creates the run directory and its projects directory
+func (r *MarkdownReporter) createDirs(report domain.AuditReport) (string, string, error) {
+	// Create timestamped directory
+	reportDir := runDir(r.outputDir, report)
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	// Create projects directory
+	projectsDir := filepath.Join(reportDir, "projects_report")
+	if err := os.MkdirAll(projectsDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create projects directory: %w", err)
+	}
+
+	return reportDir, projectsDir, nil
+}
+
+func newProjectOverview(projectID string, services []domain.Service, duration time.Duration, hygiene string) projectOverview {
+	activeCount := 0
+	for _, service := range services {
+		if service.Usage != nil &&
+			service.Usage.Status == domain.UsageStatusSuccess &&
+			service.Usage.RequestCount > 0 {
+			activeCount++
+		}
+	}
+	return projectOverview{
+		ProjectID:      projectID,
+		TotalServices:  len(services),
+		ActiveServices: activeCount,
+		Duration:       duration,
+		Hygiene:        hygiene,
+	}
+}
+
+func (r *MarkdownReporter) generateMainReport(reportDir, projectsDir string, report domain.AuditReport, projects []projectOverview) error {
 	filename := filepath.Join(reportDir, "report.md")
 	file, err := os.Create(filename)
 	if err != nil {
@@ -71,6 +165,9 @@ func (r *MarkdownReporter) generateMainReport(reportDir, projectsDir string, rep
 	// Write report header
 	fmt.Fprintf(file, "# GCP Services Audit Report\n\n")
 	fmt.Fprintf(file, "## Execution Information\n\n")
+	if report.Profile != "" {
+		fmt.Fprintf(file, "- Profile: %s\n", report.Profile)
+	}
 	fmt.Fprintf(file, "- Start time: %s\n", report.StartTime.Format(time.RFC3339))
 	fmt.Fprintf(file, "- End time: %s\n", report.GeneratedAt.Format(time.RFC3339))
 	fmt.Fprintf(file, "- Total execution time: %s\n\n", executionTime.Round(time.Second))
@@ -87,49 +184,39 @@ func (r *MarkdownReporter) generateMainReport(reportDir, projectsDir string, rep
 	fmt.Fprintf(file, "- Skipped Projects: %d\n", report.Statistics.SkippedProjects)
 	fmt.Fprintf(file, "- Unique Services: %d\n\n", report.Statistics.UniqueServices)
 
-	// Create and sort project overviews
-	projects := make([]projectOverview, 0, len(report.Services))
-	for projectID, services := range report.Services {
-		activeCount := 0
-		for _, service := range services {
-			if service.Usage != nil &&
-				service.Usage.Status == domain.UsageStatusSuccess &&
-				service.Usage.RequestCount > 0 {
-				activeCount++
-			}
-		}
-		projects = append(projects, projectOverview{
-			ProjectID:      projectID,
-			TotalServices:  len(services),
-			ActiveServices: activeCount,
-			Duration:       report.ProjectDurations[projectID],
-		})
+	if !report.Statistics.GroupBy.IsZero() {
+		r.writeGroupRollups(file, report.Statistics)
 	}
 
-	// Sort projects by total services count (descending)
+	// Sort projects by total services count (descending), and by ID for equal counts
 	sort.Slice(projects, func(i, j int) bool {
-		return projects[i].TotalServices > projects[j].TotalServices
+		if projects[i].TotalServices != projects[j].TotalServices {
+			return projects[i].TotalServices > projects[j].TotalServices
+		}
+		return projects[i].ProjectID < projects[j].ProjectID
 	})
 
 	projectsDirRelative := filepath.Base(projectsDir)
 
 	// Write projects overview
 	fmt.Fprintf(file, "## Projects Overview\n\n")
-	fmt.Fprintf(file, "| Project ID | Services | Active Services* | Processing Time |\n")
-	fmt.Fprintf(file, "|------------|----------|------------------|----------------|\n")
+	fmt.Fprintf(file, "| Project ID | Services | Active Services* | Hygiene Score | Processing Time |\n")
+	fmt.Fprintf(file, "|------------|----------|------------------|-----------------|----------------|\n")
 
 	for _, project := range projects {
 		// Use the relative path in the link
-		fmt.Fprintf(file, "| [%s](./%s/%s.md) | %d | %d | %s |\n",
+		fmt.Fprintf(file, "| [%s](./%s/%s.md) | %d | %d | %s | %s |\n",
 			project.ProjectID,
 			projectsDirRelative,
 			project.ProjectID,
 			project.TotalServices,
 			project.ActiveServices,
+			project.Hygiene,
 			project.Duration.Round(time.Second),
 		)
 	}
 	fmt.Fprintf(file, "\n*Active services are those with request count > 0 in the specified period\n\n")
+	fmt.Fprintf(file, "Hygiene scores range from 0 (neglected) to 100 (clean); the project reports list the factors.\n\n")
 
 	// Calculate and show timing statistics
 	var totalProjectTime time.Duration
@@ -187,7 +274,7 @@ func (r *MarkdownReporter) generateMainReport(reportDir, projectsDir string, rep
 	return nil
 }
 
-func (r *MarkdownReporter) generateProjectReport(reportDir, projectID string, services []domain.Service, generatedAt time.Time) error {
+func (r *MarkdownReporter) generateProjectReport(reportDir string, run RunMetadata, projectID string, services []domain.Service, hygiene domain.HygieneScore, generatedAt time.Time) error {
 	filename := filepath.Join(reportDir, fmt.Sprintf("%s.md", projectID))
 	file, err := os.Create(filename)
 	if err != nil {
@@ -201,6 +288,11 @@ func (r *MarkdownReporter) generateProjectReport(reportDir, projectID string, se
 	// Write project report header
 	fmt.Fprintf(file, "# Project: %s\n\n", projectID)
 	fmt.Fprintf(file, "Generated on: %s\n\n", generatedAt.Format(time.RFC3339))
+	fmt.Fprintf(file, "- Audit run: %s\n", run.RunID)
+	if run.Profile != "" {
+		fmt.Fprintf(file, "- Profile: %s\n", run.Profile)
+	}
+	fmt.Fprintln(file)
 
 	// Write project summary
 	fmt.Fprintf(file, "## Summary\n\n")
@@ -211,6 +303,17 @@ func (r *MarkdownReporter) generateProjectReport(reportDir, projectID string, se
 	fmt.Fprintf(file, "- Services with errors: %d\n", stats.ErrorServices)
 	fmt.Fprintf(file, "- Total Requests: %d\n\n", stats.TotalRequests)
 
+	// Write hygiene score breakdown
+	if len(hygiene.Factors) > 0 {
+		fmt.Fprintf(file, "## Hygiene Score: %.1f\n\n", hygiene.Score)
+		fmt.Fprintf(file, "| Factor | Weight | Penalty |\n")
+		fmt.Fprintf(file, "|--------|--------|---------|\n")
+		for _, factor := range hygiene.Factors {
+			fmt.Fprintf(file, "| %s | %g | %.0f%% |\n", factor.Name, factor.Weight, factor.Penalty*100)
+		}
+		fmt.Fprintf(file, "\n")
+	}
+
 	// Collect and sort active services
 	var activeServices []domain.Service
 	for _, service := range services {
@@ -219,9 +322,12 @@ func (r *MarkdownReporter) generateProjectReport(reportDir, projectID string, se
 		}
 	}
 
-	// Sort active services by request count (descending)
+	// Sort active services by request count (descending), and by name for equal counts
 	sort.Slice(activeServices, func(i, j int) bool {
-		return activeServices[i].Usage.RequestCount > activeServices[j].Usage.RequestCount
+		if activeServices[i].Usage.RequestCount != activeServices[j].Usage.RequestCount {
+			return activeServices[i].Usage.RequestCount > activeServices[j].Usage.RequestCount
+		}
+		return activeServices[i].Name < activeServices[j].Name
 	})
 
 	// Write active services
@@ -279,6 +385,47 @@ func (r *MarkdownReporter) generateProjectReport(reportDir, projectID string, se
 	return nil
 }
 
+func (r *MarkdownReporter) writeGroupRollups(file *os.File, stats domain.AuditStatistics) {
+	fmt.Fprintf(file, "## Group Rollups\n\n")
+	fmt.Fprintf(file, "Projects grouped by `%s`.\n\n", stats.GroupBy)
+	fmt.Fprintf(file, "| Group | Projects | Skipped | Enabled Services | Active Services | Inactive Services | Total Requests |\n")
+	fmt.Fprintf(file, "|-------|----------|---------|------------------|-----------------|-------------------|----------------|\n")
+
+	for _, group := range stats.Groups {
+		fmt.Fprintf(file, "| %s | %d | %d | %d | %d | %d | %d |\n",
+			groupTitle(group),
+			len(group.Projects),
+			group.SkippedProjects,
+			group.EnabledServices,
+			group.ActiveServices,
+			group.InactiveServices,
+			group.TotalRequests)
+	}
+	fmt.Fprintf(file, "\n")
+
+	if stats.Ungrouped != nil {
+		ungrouped := stats.Ungrouped
+		fmt.Fprintf(file, "### %s\n\n", ungroupedTitle(stats.GroupBy))
+		fmt.Fprintf(file, "- Projects: %d (skipped: %d)\n", len(ungrouped.Projects), ungrouped.SkippedProjects)
+		fmt.Fprintf(file, "- Enabled services: %d (active: %d, inactive: %d)\n",
+			ungrouped.EnabledServices, ungrouped.ActiveServices, ungrouped.InactiveServices)
+		fmt.Fprintf(file, "- Total requests: %d\n\n", ungrouped.TotalRequests)
+		fmt.Fprintf(file, "Project IDs:\n\n")
+		for _, projectID := range ungrouped.Projects {
+			fmt.Fprintf(file, "- %s\n", projectID)
+		}
+		fmt.Fprintf(file, "\n")
+	}
+}
+
+func formatHygieneScore(scores map[string]domain.HygieneScore, projectID string) string {
+	score, exists := scores[projectID]
+	if !exists {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", score.Score)
+}
+
 func calculateProjectStats(services []domain.Service) domain.ServiceStatistics {
 	stats := domain.ServiceStatistics{
 		TotalServices: len(services),
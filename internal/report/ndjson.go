@@ -0,0 +1,349 @@
+// internal/report/ndjson.go
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// NDJSONSchemaVersion is bumped whenever a field is added to NDJSONRecord. Fields are only
+// ever added: existing fields keep their name, type and meaning, so a changed field is added
+// under a new name instead (see docs/ndjson-schema.md).
+const NDJSONSchemaVersion = 2
+
+// pendingGeneratedAt stands for run_generated_at in streamed records, which are written before
+// the run ends; GenerateReport replaces it with the end of the run
+const pendingGeneratedAt = "0001-01-01T00:00:00Z"
+
+type NDJSONReporter struct {
+	outputDir string
+	stream    *ndjsonStream // Report being streamed, nil outside of Begin and GenerateReport
+}
+
+// ndjsonStream is an NDJSON report written while the audit runs, one project at a time
+type ndjsonStream struct {
+	reportDir string
+	run       domain.AuditReport // Run metadata of the records; its services are never read
+	file      *os.File
+	writer    *bufio.Writer
+	encoder   *json.Encoder
+}
+
+// NDJSONRecord is one flat row per (run, project, service). Skipped projects produce a
+// single record with empty service fields and ProjectSkipped set. Field names and types
+// must stay in sync with bigQuerySchema.
+type NDJSONRecord struct {
+	SchemaVersion    int           `json:"schema_version"`
+	RunID            string        `json:"run_id"`
+	RunStartTime     string        `json:"run_start_time"`
+	RunGeneratedAt   string        `json:"run_generated_at"`
+	PeriodDays       int           `json:"period_days"`
+	Profile          *string       `json:"profile"`
+	ProjectID        string        `json:"project_id"`
+	ProjectName      string        `json:"project_name,omitempty"`
+	ProjectNumber    int64         `json:"project_number,omitempty"`
+	ProjectCreatedAt *string       `json:"project_create_time"`
+	ProjectLabels    []NDJSONLabel `json:"project_labels"`
+	ProjectSkipped   bool          `json:"project_skipped"`
+	ProjectError     *string       `json:"project_error"`
+	ServiceName      *string       `json:"service_name"`
+	ServiceTitle     *string       `json:"service_title"`
+	ServiceState     *string       `json:"service_state"`
+	UsageStatus      *string       `json:"usage_status"`
+	RequestCount     *int64        `json:"request_count"`
+	UsageError       *string       `json:"usage_error"`
+	UsageLastUpdated *string       `json:"usage_last_updated"`
+}
+
+// NDJSONLabel is a project label as a key/value pair (a REPEATED RECORD in BigQuery)
+type NDJSONLabel struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// bigQueryField is a column in a BigQuery table schema file as accepted by `bq load --schema`
+type bigQueryField struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Mode        string          `json:"mode"`
+	Description string          `json:"description"`
+	Fields      []bigQueryField `json:"fields,omitempty"`
+}
+
+var bigQuerySchema = []bigQueryField{
+	{Name: "schema_version", Type: "INTEGER", Mode: "REQUIRED", Description: "Version of the record schema"},
+	{Name: "run_id", Type: "STRING", Mode: "REQUIRED", Description: "Audit run identifier, matching the report directory name (YYYYMMDD_HHMMSS)"},
+	{Name: "run_start_time", Type: "TIMESTAMP", Mode: "REQUIRED", Description: "Time the audit run started"},
+	{Name: "run_generated_at", Type: "TIMESTAMP", Mode: "REQUIRED", Description: "Time the audit run finished collecting data"},
+	{Name: "period_days", Type: "INTEGER", Mode: "REQUIRED", Description: "Length of the usage analysis window in days"},
+	{Name: "profile", Type: "STRING", Mode: "NULLABLE", Description: "Config file profile the audit ran with"},
+	{Name: "project_id", Type: "STRING", Mode: "REQUIRED", Description: "GCP project ID"},
+	{Name: "project_name", Type: "STRING", Mode: "NULLABLE", Description: "GCP project display name"},
+	{Name: "project_number", Type: "INTEGER", Mode: "NULLABLE", Description: "GCP project number"},
+	{Name: "project_create_time", Type: "TIMESTAMP", Mode: "NULLABLE", Description: "Project creation time"},
+	{Name: "project_labels", Type: "RECORD", Mode: "REPEATED", Description: "Project labels", Fields: []bigQueryField{
+		{Name: "key", Type: "STRING", Mode: "REQUIRED", Description: "Label key"},
+		{Name: "value", Type: "STRING", Mode: "NULLABLE", Description: "Label value"},
+	}},
+	{Name: "project_skipped", Type: "BOOLEAN", Mode: "REQUIRED", Description: "True when the project's services could not be listed"},
+	{Name: "project_error", Type: "STRING", Mode: "NULLABLE", Description: "Reason the project was skipped"},
+	{Name: "service_name", Type: "STRING", Mode: "NULLABLE", Description: "Service name, e.g. bigquery.googleapis.com"},
+	{Name: "service_title", Type: "STRING", Mode: "NULLABLE", Description: "Human-readable service title"},
+	{Name: "service_state", Type: "STRING", Mode: "NULLABLE", Description: "Service state, e.g. ENABLED"},
+	{Name: "usage_status", Type: "STRING", Mode: "NULLABLE", Description: "Usage lookup status: SUCCESS, NO_ACCESS or ERROR"},
+	{Name: "request_count", Type: "INTEGER", Mode: "NULLABLE", Description: "API requests during the analysis period"},
+	{Name: "usage_error", Type: "STRING", Mode: "NULLABLE", Description: "Error returned by the usage lookup"},
+	{Name: "usage_last_updated", Type: "TIMESTAMP", Mode: "NULLABLE", Description: "Time the usage metrics were collected"},
+}
+
+func NewNDJSONReporter(outputDir string) *NDJSONReporter {
+	return &NDJSONReporter{
+		outputDir: outputDir,
+	}
+}
+
+func (r *NDJSONReporter) GenerateReport(report domain.AuditReport) error {
+	var reportDir string
+	if r.stream != nil {
+		stream := r.stream
+		r.stream = nil
+		if err := stream.close(); err != nil {
+			return fmt.Errorf("failed to write NDJSON report: %w", err)
+		}
+		if err := fillGeneratedAt(stream.file.Name(), report.GeneratedAt); err != nil {
+			return fmt.Errorf("failed to write NDJSON report: %w", err)
+		}
+		reportDir = stream.reportDir
+	} else {
+		reportDir = runDir(r.outputDir, report)
+		if err := os.MkdirAll(reportDir, 0755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+
+		if err := r.writeRecords(filepath.Join(reportDir, "services.ndjson"), report); err != nil {
+			return fmt.Errorf("failed to write NDJSON report: %w", err)
+		}
+	}
+
+	schema, err := json.MarshalIndent(bigQuerySchema, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal BigQuery schema: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(reportDir, "bigquery_schema.json"), schema, 0644); err != nil {
+		return fmt.Errorf("failed to write BigQuery schema: %w", err)
+	}
+
+	return nil
+}
+
+// Begin starts streaming the report: services.ndjson is written as projects complete
+func (r *NDJSONReporter) Begin(report domain.AuditReport) error {
+	reportDir := runDir(r.outputDir, report)
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	file, err := os.Create(filepath.Join(reportDir, "services.ndjson"))
+	if err != nil {
+		return fmt.Errorf("failed to create NDJSON file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	run := report
+	run.Projects, run.Services = nil, nil
+	r.stream = &ndjsonStream{
+		reportDir: reportDir,
+		run:       run,
+		file:      file,
+		writer:    writer,
+		encoder:   encoder,
+	}
+	return nil
+}
+
+// AddProject writes the records of a completed or skipped project
+func (r *NDJSONReporter) AddProject(result domain.ProjectResult) error {
+	stream := r.stream
+	for _, record := range r.projectRecords(stream.run, result.Project, result.Services, result.Err != nil, result.Err) {
+		record.RunGeneratedAt = pendingGeneratedAt
+		if err := stream.encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode record for project %s: %w", result.Project.ID, err)
+		}
+	}
+	return nil
+}
+
+// Abort discards the report being streamed, so that the reporter can Begin again
+func (r *NDJSONReporter) Abort() {
+	if r.stream == nil {
+		return
+	}
+	stream := r.stream
+	r.stream = nil
+	stream.file.Close()
+	os.Remove(stream.file.Name())
+	os.Remove(stream.reportDir) // Only removed when no other report is left in it
+}
+
+func (s *ndjsonStream) close() error {
+	if err := s.writer.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// fillGeneratedAt sets run_generated_at to the end of the run in the streamed records of the
+// file, rewriting it line by line
+func fillGeneratedAt(filename string, generatedAt time.Time) error {
+	pending := []byte(`"run_generated_at":"` + pendingGeneratedAt + `"`)
+	generated := []byte(`"run_generated_at":"` + generatedAt.UTC().Format(time.RFC3339) + `"`)
+
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(filename), ".services-*.ndjson")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name()) // Fails harmlessly once renamed
+
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if _, writeErr := writer.Write(bytes.Replace(line, pending, generated, 1)); writeErr != nil {
+			out.Close()
+			return writeErr
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), filename)
+}
+
+func (r *NDJSONReporter) writeRecords(filename string, report domain.AuditReport) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create NDJSON file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	projects := make([]domain.Project, len(report.Projects))
+	copy(projects, report.Projects)
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].ID < projects[j].ID
+	})
+
+	for _, project := range projects {
+		err, skipped := report.SkippedProjects[project.ID]
+		for _, record := range r.projectRecords(report, project, report.Services[project.ID], skipped, err) {
+			if err := encoder.Encode(record); err != nil {
+				return fmt.Errorf("failed to encode record for project %s: %w", project.ID, err)
+			}
+		}
+	}
+
+	return writer.Flush()
+}
+
+// projectRecords returns the records of a project. Only the run metadata of the report is used.
+func (r *NDJSONReporter) projectRecords(report domain.AuditReport, project domain.Project, services []domain.Service, skipped bool, skipErr error) []NDJSONRecord {
+	base := NDJSONRecord{
+		SchemaVersion:  NDJSONSchemaVersion,
+		RunID:          report.RunID(),
+		RunStartTime:   report.StartTime.UTC().Format(time.RFC3339),
+		RunGeneratedAt: report.GeneratedAt.UTC().Format(time.RFC3339),
+		PeriodDays:     int(report.Period / (24 * time.Hour)),
+		ProjectID:      project.ID,
+		ProjectName:    project.Name,
+		ProjectNumber:  project.ProjectNum,
+		ProjectLabels:  make([]NDJSONLabel, 0, len(project.Labels)),
+	}
+	if report.Profile != "" {
+		base.Profile = stringPtr(report.Profile)
+	}
+	if !project.CreateTime.IsZero() {
+		base.ProjectCreatedAt = stringPtr(project.CreateTime.UTC().Format(time.RFC3339))
+	}
+
+	keys := make([]string, 0, len(project.Labels))
+	for key := range project.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		base.ProjectLabels = append(base.ProjectLabels, NDJSONLabel{Key: key, Value: project.Labels[key]})
+	}
+
+	if skipped {
+		base.ProjectSkipped = true
+		if skipErr != nil {
+			base.ProjectError = stringPtr(skipErr.Error())
+		}
+		return []NDJSONRecord{base}
+	}
+
+	services = sortedServices(services)
+	records := make([]NDJSONRecord, 0, len(services))
+	for _, service := range services {
+		record := base
+		record.ServiceName = stringPtr(service.Name)
+		record.ServiceTitle = stringPtr(service.Title)
+		record.ServiceState = stringPtr(service.State)
+
+		if service.Usage != nil {
+			record.UsageStatus = stringPtr(string(service.Usage.Status))
+			requests := service.Usage.RequestCount
+			record.RequestCount = &requests
+			if service.Usage.Error != "" {
+				record.UsageError = stringPtr(service.Usage.Error)
+			}
+			if !service.Usage.LastUpdated.IsZero() {
+				record.UsageLastUpdated = stringPtr(service.Usage.LastUpdated.UTC().Format(time.RFC3339))
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
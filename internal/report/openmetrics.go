@@ -0,0 +1,226 @@
+// internal/report/openmetrics.go
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// OpenMetricsContentType is the HTTP content type of the output of WriteOpenMetrics
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// OpenMetricsReporter writes audit results in the OpenMetrics text format. Besides the copy in
+// the run directory, the metrics can be written to a node_exporter textfile collector path.
+type OpenMetricsReporter struct {
+	outputDir    string // Empty skips the copy in the run directory
+	textfilePath string
+}
+
+func NewOpenMetricsReporter(outputDir, textfilePath string) *OpenMetricsReporter {
+	return &OpenMetricsReporter{
+		outputDir:    outputDir,
+		textfilePath: textfilePath,
+	}
+}
+
+// NewMetricsTextfileReporter returns a reporter that only writes the node_exporter textfile,
+// for runs whose report formats do not include OpenMetrics
+func NewMetricsTextfileReporter(textfilePath string) *OpenMetricsReporter {
+	return &OpenMetricsReporter{textfilePath: textfilePath}
+}
+
+func (r *OpenMetricsReporter) GenerateReport(report domain.AuditReport) error {
+	if r.outputDir != "" {
+		reportDir := runDir(r.outputDir, report)
+		if err := os.MkdirAll(reportDir, 0755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+
+		if err := writeMetricsFile(filepath.Join(reportDir, "metrics.prom"), report); err != nil {
+			return fmt.Errorf("failed to write metrics report: %w", err)
+		}
+	}
+
+	if r.textfilePath != "" {
+		if err := writeMetricsFile(r.textfilePath, report); err != nil {
+			return fmt.Errorf("failed to write metrics textfile: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeMetricsFile renders the metrics to a temporary file and renames it into place,
+// so node_exporter never reads a partially written textfile
+func writeMetricsFile(filename string, report domain.AuditReport) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := WriteOpenMetrics(tmp, report); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// WriteOpenMetrics writes the audit results as OpenMetrics text exposition
+func WriteOpenMetrics(w io.Writer, report domain.AuditReport) error {
+	bw := &metricsWriter{Writer: bufio.NewWriter(w)}
+	if report.Profile != "" {
+		bw.labels = []string{"profile", report.Profile}
+	}
+	stats := report.Statistics
+
+	bw.family("gcp_auditor_run_info", "Audit run the metrics belong to")
+	bw.sample("gcp_auditor_run_info", 1, "run_id", report.RunID())
+
+	bw.gauge("gcp_auditor_run_duration_seconds", "Duration of the last audit run",
+		report.GeneratedAt.Sub(report.StartTime).Seconds())
+	bw.gauge("gcp_auditor_run_timestamp_seconds", "Time the last audit run finished",
+		float64(report.GeneratedAt.UnixNano())/float64(time.Second))
+	bw.gauge("gcp_auditor_period_days", "Length of the usage analysis window in days",
+		float64(report.Period/(24*time.Hour)))
+	bw.gauge("gcp_auditor_projects", "Projects returned by Resource Manager", float64(stats.TotalProjects))
+	bw.gauge("gcp_auditor_projects_valid", "Projects included in the audit", float64(stats.ValidProjects))
+	bw.gauge("gcp_auditor_projects_excluded", "Projects excluded from the audit", float64(stats.ExcludedProjects))
+	bw.gauge("gcp_auditor_projects_skipped", "Projects whose services could not be listed", float64(stats.SkippedProjects))
+	bw.gauge("gcp_auditor_unique_services", "Unique services enabled across projects", float64(stats.UniqueServices))
+	bw.gauge("gcp_auditor_services_unused", "Enabled services without requests in the period", float64(stats.ServicesWithNoUsage))
+
+	projectIDs := sortedProjectIDs(report.Services)
+
+	bw.family("gcp_auditor_service_enabled", "Service is enabled in the project")
+	for _, projectID := range projectIDs {
+		for _, service := range sortedServices(report.Services[projectID]) {
+			bw.sample("gcp_auditor_service_enabled", 1, "project", projectID, "service", service.Name)
+		}
+	}
+
+	bw.family("gcp_auditor_service_requests_total", "API requests to the service during the analysis period")
+	for _, projectID := range projectIDs {
+		for _, service := range sortedServices(report.Services[projectID]) {
+			if service.Usage == nil || service.Usage.Status != domain.UsageStatusSuccess {
+				continue
+			}
+			bw.sample("gcp_auditor_service_requests_total", float64(service.Usage.RequestCount),
+				"project", projectID, "service", service.Name)
+		}
+	}
+
+	bw.family("gcp_auditor_service_usage_status", "Usage lookup status of the service (1 for the current status)")
+	for _, projectID := range projectIDs {
+		for _, service := range sortedServices(report.Services[projectID]) {
+			if service.Usage == nil {
+				continue
+			}
+			bw.sample("gcp_auditor_service_usage_status", 1,
+				"project", projectID, "service", service.Name, "status", string(service.Usage.Status))
+		}
+	}
+
+	bw.family("gcp_auditor_project_duration_seconds", "Time spent processing the project")
+	for _, projectID := range projectIDs {
+		bw.sample("gcp_auditor_project_duration_seconds", report.ProjectDurations[projectID].Seconds(),
+			"project", projectID)
+	}
+
+	if !stats.GroupBy.IsZero() {
+		bw.groupMetrics(stats)
+	}
+
+	fmt.Fprintf(bw, "# EOF\n")
+	return bw.Flush()
+}
+
+// groupMetrics writes the group rollups. Projects without a group have empty group and group_id
+// labels.
+func (w *metricsWriter) groupMetrics(stats domain.AuditStatistics) {
+	rollups := stats.Groups
+	if stats.Ungrouped != nil {
+		rollups = append(rollups[:len(rollups):len(rollups)], stats.Ungrouped)
+	}
+
+	families := []struct {
+		name  string
+		help  string
+		value func(*domain.GroupRollup) float64
+	}{
+		{"gcp_auditor_group_projects", "Projects in the group",
+			func(g *domain.GroupRollup) float64 { return float64(len(g.Projects)) }},
+		{"gcp_auditor_group_services_enabled", "Enabled services across the group's projects",
+			func(g *domain.GroupRollup) float64 { return float64(g.EnabledServices) }},
+		{"gcp_auditor_group_services_active", "Services with requests in the period across the group's projects",
+			func(g *domain.GroupRollup) float64 { return float64(g.ActiveServices) }},
+		{"gcp_auditor_group_services_inactive", "Services without requests in the period across the group's projects",
+			func(g *domain.GroupRollup) float64 { return float64(g.InactiveServices) }},
+		{"gcp_auditor_group_requests_total", "API requests during the analysis period across the group's projects",
+			func(g *domain.GroupRollup) float64 { return float64(g.TotalRequests) }},
+	}
+
+	for _, family := range families {
+		w.family(family.name, family.help)
+		for _, group := range rollups {
+			w.sample(family.name, family.value(group), "group_by", stats.GroupBy.String(), "group", group.Name, "group_id", group.ID)
+		}
+	}
+}
+
+// metricsWriter writes metric families, adding its labels to every sample. The profile label
+// keeps apart the metrics of audits with different profiles, e.g. in one textfile directory.
+type metricsWriter struct {
+	*bufio.Writer
+	labels []string // Alternating name/value pairs
+}
+
+func (w *metricsWriter) family(name, help string) {
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+}
+
+func (w *metricsWriter) gauge(name, help string, value float64) {
+	w.family(name, help)
+	w.sample(name, value)
+}
+
+// sample writes a single sample; labels are given as alternating name/value pairs
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	labels = append(w.labels[:len(w.labels):len(w.labels)], labels...)
+	if len(labels) == 0 {
+		fmt.Fprintf(w, "%s %s\n", name, formatMetricValue(value))
+		return
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1])))
+	}
+	fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), formatMetricValue(value))
+}
+
+func formatMetricValue(value float64) string {
+	return fmt.Sprintf("%g", value)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
@@ -0,0 +1,51 @@
+// internal/report/report.go
+package report
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// runDir returns the timestamped directory shared by all reporters of a single audit run
+func runDir(outputDir string, report domain.AuditReport) string {
+	return filepath.Join(outputDir, report.RunID())
+}
+
+// sortedProjectIDs returns the project IDs of the services map in lexical order
+func sortedProjectIDs(services map[string][]domain.Service) []string {
+	ids := make([]string, 0, len(services))
+	for projectID := range services {
+		ids = append(ids, projectID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sortedServices returns a copy of services ordered by service name
+func sortedServices(services []domain.Service) []domain.Service {
+	sorted := make([]domain.Service, len(services))
+	copy(sorted, services)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// groupTitle names a group in prose reports, followed by its ID when it differs from the name
+func groupTitle(group *domain.GroupRollup) string {
+	if group.Name == group.ID {
+		return group.Name
+	}
+	return fmt.Sprintf("%s (%s)", group.Name, group.ID)
+}
+
+// ungroupedTitle describes the projects that fall outside of every group
+func ungroupedTitle(groupBy domain.GroupBy) string {
+	if groupBy.Folder {
+		return "Projects not in a folder"
+	}
+	return fmt.Sprintf("Projects missing label %q", groupBy.Label)
+}
@@ -0,0 +1,392 @@
+// internal/report/xlsx.go
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/pkg/gcp"
+)
+
+const (
+	sheetSummary          = "Summary"
+	sheetProjects         = "Projects"
+	sheetServices         = "Services"
+	sheetInactiveServices = "Inactive Services"
+	sheetSkippedProjects  = "Skipped Projects"
+	sheetGroups           = "Groups"
+)
+
+type XLSXReporter struct {
+	outputDir string
+}
+
+// xlsxSheet describes a tabular worksheet with a frozen header row and an auto-filter
+type xlsxSheet struct {
+	name        string
+	header      []string
+	rows        [][]interface{}
+	numberCols  []int // zero-based columns formatted with thousand separators
+	columnWidth []float64
+}
+
+func NewXLSXReporter(outputDir string) *XLSXReporter {
+	return &XLSXReporter{
+		outputDir: outputDir,
+	}
+}
+
+func (r *XLSXReporter) GenerateReport(report domain.AuditReport) error {
+	reportDir := runDir(r.outputDir, report)
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newXLSXStyles(f)
+	if err != nil {
+		return err
+	}
+
+	sheets := []xlsxSheet{
+		r.summarySheet(report),
+		r.projectsSheet(report),
+		r.servicesSheet(report),
+		r.inactiveServicesSheet(report),
+		r.skippedProjectsSheet(report),
+	}
+	if !report.Statistics.GroupBy.IsZero() {
+		sheets = append(sheets, r.groupsSheet(report.Statistics))
+	}
+
+	for i, sheet := range sheets {
+		if i == 0 {
+			if err := f.SetSheetName("Sheet1", sheet.name); err != nil {
+				return fmt.Errorf("failed to rename default sheet: %w", err)
+			}
+		} else if _, err := f.NewSheet(sheet.name); err != nil {
+			return fmt.Errorf("failed to create sheet %s: %w", sheet.name, err)
+		}
+
+		if err := writeXLSXSheet(f, sheet, styles); err != nil {
+			return fmt.Errorf("failed to write sheet %s: %w", sheet.name, err)
+		}
+	}
+
+	if err := f.SaveAs(filepath.Join(reportDir, "report.xlsx")); err != nil {
+		return fmt.Errorf("failed to save XLSX report: %w", err)
+	}
+
+	return nil
+}
+
+// summarySheet lists the audit execution details and statistics as metric/value pairs
+func (r *XLSXReporter) summarySheet(report domain.AuditReport) xlsxSheet {
+	stats := report.Statistics
+	sheet := xlsxSheet{
+		name:        sheetSummary,
+		header:      []string{"Metric", "Value"},
+		numberCols:  []int{1},
+		columnWidth: []float64{30, 25},
+		rows: [][]interface{}{
+			{"Start Time", report.StartTime.Format(time.RFC3339)},
+			{"End Time", report.GeneratedAt.Format(time.RFC3339)},
+			{"Execution Time (s)", report.GeneratedAt.Sub(report.StartTime).Round(time.Second).Seconds()},
+			{"Analysis Period (days)", int(report.Period / (24 * time.Hour))},
+			{"Total Projects", stats.TotalProjects},
+			{"Valid Projects", stats.ValidProjects},
+			{"Excluded Projects", stats.ExcludedProjects},
+			{"Skipped Projects", stats.SkippedProjects},
+			{"Unique Services", stats.UniqueServices},
+			{"Services With No Usage", stats.ServicesWithNoUsage},
+		},
+	}
+	if report.Profile != "" {
+		sheet.rows = append([][]interface{}{{"Profile", report.Profile}}, sheet.rows...)
+	}
+	return sheet
+}
+
+func (r *XLSXReporter) projectsSheet(report domain.AuditReport) xlsxSheet {
+	labelKeys := collectLabelKeys(report.Projects)
+	sheet := xlsxSheet{
+		name: sheetProjects,
+		header: []string{
+			"Project ID", "Name", "Project Number", "Created",
+			"Services", "Active Services", "Inactive Services", "No Access Services", "Error Services",
+			"Total Requests", "Processing Time (s)", "Skipped Error",
+		},
+		numberCols:  []int{4, 5, 6, 7, 8, 9},
+		columnWidth: []float64{30, 30, 16, 20, 10, 16, 18, 20, 15, 16, 20, 40},
+	}
+	for _, key := range labelKeys {
+		sheet.header = append(sheet.header, "label:"+key)
+	}
+
+	projects := make([]domain.Project, len(report.Projects))
+	copy(projects, report.Projects)
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].ID < projects[j].ID
+	})
+
+	for _, project := range projects {
+		stats := calculateProjectStats(report.Services[project.ID])
+
+		var created, skippedErr string
+		if !project.CreateTime.IsZero() {
+			created = project.CreateTime.Format("2006-01-02")
+		}
+		if err, skipped := report.SkippedProjects[project.ID]; skipped && err != nil {
+			skippedErr = err.Error()
+		}
+
+		row := []interface{}{
+			project.ID,
+			project.Name,
+			fmt.Sprintf("%d", project.ProjectNum),
+			created,
+			stats.TotalServices,
+			stats.ActiveServices,
+			stats.InactiveServices,
+			stats.NoAccessServices,
+			stats.ErrorServices,
+			stats.TotalRequests,
+			report.ProjectDurations[project.ID].Round(time.Millisecond).Seconds(),
+			skippedErr,
+		}
+		for _, key := range labelKeys {
+			row = append(row, project.Labels[key])
+		}
+		sheet.rows = append(sheet.rows, row)
+	}
+
+	return sheet
+}
+
+// servicesSheet is a flat project/service table suitable for pivoting
+func (r *XLSXReporter) servicesSheet(report domain.AuditReport) xlsxSheet {
+	sheet := xlsxSheet{
+		name:        sheetServices,
+		header:      []string{"Project ID", "Service", "Title", "Category", "State", "Usage Status", "Active", "Request Count", "Service Projects Count", "Error"},
+		numberCols:  []int{7, 8},
+		columnWidth: []float64{30, 45, 40, 14, 12, 14, 10, 16, 22, 60},
+	}
+
+	projectCounts := make(map[string]int, len(report.Statistics.ServiceDetails))
+	for _, detail := range report.Statistics.ServiceDetails {
+		projectCounts[detail.Name] = detail.ProjectCount
+	}
+
+	for _, projectID := range sortedProjectIDs(report.Services) {
+		for _, service := range sortedServices(report.Services[projectID]) {
+			var status, errMsg string
+			var requests int64
+			if service.Usage != nil {
+				status = string(service.Usage.Status)
+				requests = service.Usage.RequestCount
+				errMsg = service.Usage.Error
+			}
+			active := service.Usage != nil && service.Usage.Status == domain.UsageStatusSuccess && requests > 0
+
+			sheet.rows = append(sheet.rows, []interface{}{
+				projectID,
+				service.Name,
+				service.Title,
+				gcp.ServiceCategory(service.Name),
+				service.State,
+				status,
+				active,
+				requests,
+				projectCounts[service.Name],
+				errMsg,
+			})
+		}
+	}
+
+	return sheet
+}
+
+func (r *XLSXReporter) inactiveServicesSheet(report domain.AuditReport) xlsxSheet {
+	sheet := xlsxSheet{
+		name:        sheetInactiveServices,
+		header:      []string{"Project ID", "Service", "Title", "State"},
+		columnWidth: []float64{30, 45, 40, 12},
+	}
+
+	for _, projectID := range sortedProjectIDs(report.Services) {
+		for _, service := range sortedServices(report.Services[projectID]) {
+			if service.Usage != nil && service.Usage.Status == domain.UsageStatusSuccess && service.Usage.RequestCount == 0 {
+				sheet.rows = append(sheet.rows, []interface{}{
+					projectID,
+					service.Name,
+					service.Title,
+					service.State,
+				})
+			}
+		}
+	}
+
+	return sheet
+}
+
+func (r *XLSXReporter) skippedProjectsSheet(report domain.AuditReport) xlsxSheet {
+	sheet := xlsxSheet{
+		name:        sheetSkippedProjects,
+		header:      []string{"Project ID", "Error"},
+		columnWidth: []float64{30, 100},
+	}
+
+	skippedIDs := make([]string, 0, len(report.SkippedProjects))
+	for projectID := range report.SkippedProjects {
+		skippedIDs = append(skippedIDs, projectID)
+	}
+	sort.Strings(skippedIDs)
+
+	for _, projectID := range skippedIDs {
+		var errMsg string
+		if err := report.SkippedProjects[projectID]; err != nil {
+			errMsg = err.Error()
+		}
+		sheet.rows = append(sheet.rows, []interface{}{projectID, errMsg})
+	}
+
+	return sheet
+}
+
+// groupsSheet lists the group rollups, followed by the projects without a group
+func (r *XLSXReporter) groupsSheet(stats domain.AuditStatistics) xlsxSheet {
+	sheet := xlsxSheet{
+		name: sheetGroups,
+		header: []string{
+			"Group (" + stats.GroupBy.String() + ")",
+			"Group ID",
+			"Projects",
+			"Skipped Projects",
+			"Enabled Services",
+			"Active Services",
+			"Inactive Services",
+			"Total Requests",
+		},
+		numberCols:  []int{7},
+		columnWidth: []float64{35, 20, 12, 16, 16, 16, 18, 16},
+	}
+
+	rollups := stats.Groups
+	if stats.Ungrouped != nil {
+		rollups = append(rollups[:len(rollups):len(rollups)], stats.Ungrouped)
+	}
+
+	for _, group := range rollups {
+		name := group.Name
+		if group == stats.Ungrouped {
+			name = "(" + ungroupedTitle(stats.GroupBy) + ")"
+		}
+		sheet.rows = append(sheet.rows, []interface{}{
+			name,
+			group.ID,
+			len(group.Projects),
+			group.SkippedProjects,
+			group.EnabledServices,
+			group.ActiveServices,
+			group.InactiveServices,
+			group.TotalRequests,
+		})
+	}
+
+	return sheet
+}
+
+type xlsxStyles struct {
+	header int
+	number int
+}
+
+func newXLSXStyles(f *excelize.File) (xlsxStyles, error) {
+	header, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}},
+	})
+	if err != nil {
+		return xlsxStyles{}, fmt.Errorf("failed to create header style: %w", err)
+	}
+
+	// Built-in number format 3 is "#,##0"
+	number, err := f.NewStyle(&excelize.Style{NumFmt: 3})
+	if err != nil {
+		return xlsxStyles{}, fmt.Errorf("failed to create number style: %w", err)
+	}
+
+	return xlsxStyles{header: header, number: number}, nil
+}
+
+func writeXLSXSheet(f *excelize.File, sheet xlsxSheet, styles xlsxStyles) error {
+	header := make([]interface{}, len(sheet.header))
+	for i, h := range sheet.header {
+		header[i] = h
+	}
+	if err := f.SetSheetRow(sheet.name, "A1", &header); err != nil {
+		return err
+	}
+
+	for i, row := range sheet.rows {
+		row := row
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheet.name, cell, &row); err != nil {
+			return err
+		}
+	}
+
+	lastCol, err := excelize.ColumnNumberToName(len(sheet.header))
+	if err != nil {
+		return err
+	}
+	lastRow := len(sheet.rows) + 1
+
+	if err := f.SetCellStyle(sheet.name, "A1", fmt.Sprintf("%s1", lastCol), styles.header); err != nil {
+		return err
+	}
+
+	if len(sheet.rows) > 0 {
+		for _, col := range sheet.numberCols {
+			name, err := excelize.ColumnNumberToName(col + 1)
+			if err != nil {
+				return err
+			}
+			if err := f.SetCellStyle(sheet.name, name+"2", fmt.Sprintf("%s%d", name, lastRow), styles.number); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, width := range sheet.columnWidth {
+		name, err := excelize.ColumnNumberToName(i + 1)
+		if err != nil {
+			return err
+		}
+		if err := f.SetColWidth(sheet.name, name, name, width); err != nil {
+			return err
+		}
+	}
+
+	// Freeze the header row
+	if err := f.SetPanes(sheet.name, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+
+	return f.AutoFilter(sheet.name, fmt.Sprintf("A1:%s%d", lastCol, lastRow), nil)
+}
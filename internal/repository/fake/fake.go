@@ -0,0 +1,198 @@
+// internal/repository/fake/fake.go
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/repository/gcp"
+)
+
+// Project is a project served by the fake repository together with its enabled services
+type Project struct {
+	domain.Project
+	Services  []Service
+	Latency   time.Duration // Delay before the services are listed
+	ListError error         // Returned instead of the services
+}
+
+// Service is an enabled service and the usage reported for it
+type Service struct {
+	Name         string // e.g. "bigquery.googleapis.com"
+	Title        string
+	RequestCount int64
+	UsageStatus  domain.UsageStatus // Defaults to domain.UsageStatusSuccess
+	UsageError   string             // Reported with domain.UsageStatusNoAccess or domain.UsageStatusError
+	Latency      time.Duration      // Delay of the usage lookup
+}
+
+// Repository is an in-memory domain.ProjectRepository and domain.ServiceRepository. Latencies
+// honor context cancellation the way the GCP repositories do.
+type Repository struct {
+	projects          []Project
+	listLatency       time.Duration
+	listProjectsError error
+	now               func() time.Time
+}
+
+type Option func(*Repository)
+
+// WithProjects sets the projects returned by ListProjects, in order
+func WithProjects(projects ...Project) Option {
+	return func(r *Repository) {
+		r.projects = append(r.projects, projects...)
+	}
+}
+
+// WithListProjectsLatency delays ListProjects
+func WithListProjectsLatency(latency time.Duration) Option {
+	return func(r *Repository) {
+		r.listLatency = latency
+	}
+}
+
+// WithListProjectsError makes ListProjects fail
+func WithListProjectsError(err error) Option {
+	return func(r *Repository) {
+		r.listProjectsError = err
+	}
+}
+
+// WithClock sets the time reported as the last update of the usage
+func WithClock(now func() time.Time) Option {
+	return func(r *Repository) {
+		r.now = now
+	}
+}
+
+func NewRepository(opts ...Option) *Repository {
+	r := &Repository{
+		now: time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func (r *Repository) ListProjects(ctx context.Context) ([]domain.Project, error) {
+	if err := sleep(ctx, r.listLatency); err != nil {
+		return nil, err
+	}
+	if r.listProjectsError != nil {
+		return nil, r.listProjectsError
+	}
+
+	projects := make([]domain.Project, 0, len(r.projects))
+	for _, project := range r.projects {
+		projects = append(projects, project.Project)
+	}
+	return projects, nil
+}
+
+func (r *Repository) IsValidProject(project domain.Project) bool {
+	return !gcp.IsSystemProject(project.ID)
+}
+
+func (r *Repository) ListServices(ctx context.Context, projectID string, period time.Duration) ([]domain.Service, error) {
+	project, ok := r.project(projectID)
+	if !ok {
+		return nil, fmt.Errorf("project %s not found", projectID)
+	}
+
+	if err := sleep(ctx, project.Latency); err != nil {
+		return nil, err
+	}
+	if project.ListError != nil {
+		return nil, project.ListError
+	}
+
+	services := make([]domain.Service, 0, len(project.Services))
+	for _, fakeService := range project.Services {
+		usage, err := r.GetServiceUsage(ctx, projectID, fakeService.Name, period)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, domain.Service{
+			Name:      fakeService.Name,
+			Title:     fakeService.Title,
+			State:     "ENABLED",
+			ProjectID: projectID,
+			Usage:     usage,
+		})
+	}
+	return services, nil
+}
+
+// GetServiceUsage reports the configured usage. Like the GCP repository, failed lookups are
+// reported in the usage status rather than as an error.
+func (r *Repository) GetServiceUsage(ctx context.Context, projectID, serviceName string, period time.Duration) (*domain.Usage, error) {
+	usage := &domain.Usage{
+		Period:      period,
+		LastUpdated: r.now(),
+		Status:      domain.UsageStatusSuccess,
+	}
+
+	service, ok := r.service(projectID, serviceName)
+	if !ok {
+		usage.Status = domain.UsageStatusError
+		usage.Error = "service " + serviceName + " is not enabled"
+		return usage, nil
+	}
+
+	if err := sleep(ctx, service.Latency); err != nil {
+		usage.Status = domain.UsageStatusError
+		usage.Error = err.Error()
+		return usage, nil
+	}
+
+	if service.UsageStatus != "" {
+		usage.Status = service.UsageStatus
+	}
+	usage.Error = service.UsageError
+	if usage.Status == domain.UsageStatusSuccess {
+		usage.RequestCount = service.RequestCount
+	}
+	return usage, nil
+}
+
+func (r *Repository) project(projectID string) (Project, bool) {
+	for _, project := range r.projects {
+		if project.ID == projectID {
+			return project, true
+		}
+	}
+	return Project{}, false
+}
+
+func (r *Repository) service(projectID, serviceName string) (Service, bool) {
+	project, _ := r.project(projectID)
+	serviceName = strings.TrimPrefix(serviceName, "services/")
+	for _, service := range project.Services {
+		if service.Name == serviceName {
+			return service, true
+		}
+	}
+	return Service{}, false
+}
+
+// sleep waits for d or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,118 @@
+// internal/repository/gcp/adaptive.go
+package gcp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxQuotaRetries is the number of times a request rejected for quota is retried in adaptive mode
+const maxQuotaRetries = 3
+
+// quotaRetryDelay is the delay before the first retry of a request rejected for quota; every
+// further retry waits one more delay
+var quotaRetryDelay = time.Second
+
+// adaptiveLimiter bounds the API requests in flight across all projects. The limit follows
+// additive increase, multiplicative decrease: it grows by one for every limit requests that
+// succeed and is halved when a request is rejected for quota, between 1 and max.
+type adaptiveLimiter struct {
+	mu       sync.Mutex
+	limit    float64
+	max      float64
+	inFlight int
+	epoch    uint64        // Incremented on every decrease
+	changed  chan struct{} // Closed and replaced whenever a request completes
+}
+
+func newAdaptiveLimiter(maxInFlight int) *adaptiveLimiter {
+	return &adaptiveLimiter{
+		limit:   math.Max(float64(maxInFlight)/4, 1),
+		max:     float64(maxInFlight),
+		changed: make(chan struct{}),
+	}
+}
+
+// acquire blocks until a request may start. The returned epoch is passed to release.
+func (l *adaptiveLimiter) acquire(ctx context.Context) (uint64, error) {
+	for {
+		l.mu.Lock()
+		if l.inFlight < int(l.limit) {
+			l.inFlight++
+			epoch := l.epoch
+			l.mu.Unlock()
+			return epoch, nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// release completes a request and adapts the limit to its outcome. It reports whether the
+// limit was decreased.
+func (l *adaptiveLimiter) release(epoch uint64, err error) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	decreased := false
+	l.inFlight--
+	switch {
+	case err == nil:
+		l.limit = math.Min(l.limit+1/l.limit, l.max)
+	case isQuotaError(err) && epoch == l.epoch:
+		// Requests started before the last decrease do not decrease the limit again,
+		// so a burst of rejections halves it only once
+		l.limit = math.Max(l.limit/2, 1)
+		l.epoch++
+		decreased = true
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+	return decreased
+}
+
+// current returns the number of requests allowed in flight
+func (l *adaptiveLimiter) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// isQuotaError reports whether an API request was rejected because of rate limits or quota
+func isQuotaError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if status.Code(err) == codes.ResourceExhausted {
+		return true
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Code == http.StatusTooManyRequests {
+			return true
+		}
+		for _, item := range apiErr.Errors {
+			switch item.Reason {
+			case "rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded":
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/repository/stub"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errQuota     = status.Error(codes.ResourceExhausted, "Quota exceeded")
+	errRateLimit = &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}
+)
+
+func TestAdaptiveLimiterRelease(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxInFlight   int
+		rounds        [][]error // Every round starts its requests, then releases them with these errors
+		wantLimit     int
+		wantDecreases int
+	}{
+		{
+			name:        "starts at a quarter of the maximum",
+			maxInFlight: 16,
+			wantLimit:   4,
+		},
+		{
+			name:          "burst of quota errors decreases once",
+			maxInFlight:   16,
+			rounds:        [][]error{{errQuota, errQuota, errQuota, errQuota}},
+			wantLimit:     2,
+			wantDecreases: 1,
+		},
+		{
+			name:          "quota errors of later epochs decrease again",
+			maxInFlight:   16,
+			rounds:        [][]error{{errQuota, errQuota}, {errRateLimit}},
+			wantLimit:     1,
+			wantDecreases: 2,
+		},
+		{
+			name:          "limit stays at least one",
+			maxInFlight:   4,
+			rounds:        [][]error{{errQuota}, {errQuota}},
+			wantLimit:     1,
+			wantDecreases: 2,
+		},
+		{
+			name:        "other errors keep the limit",
+			maxInFlight: 16,
+			rounds:      [][]error{{errors.New("boom"), status.Error(codes.PermissionDenied, "denied")}},
+			wantLimit:   4,
+		},
+		{
+			name:        "successes increase the limit",
+			maxInFlight: 16,
+			rounds:      [][]error{{nil, nil, nil, nil}, {nil, nil, nil, nil}},
+			wantLimit:   5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newAdaptiveLimiter(tt.maxInFlight)
+			decreases := 0
+			for _, round := range tt.rounds {
+				epochs := make([]uint64, len(round))
+				for i := range round {
+					epoch, err := l.acquire(context.Background())
+					if err != nil {
+						t.Fatalf("acquire() error = %v", err)
+					}
+					epochs[i] = epoch
+				}
+				for i, err := range round {
+					if l.release(epochs[i], err) {
+						decreases++
+					}
+				}
+			}
+
+			if got := l.current(); got != tt.wantLimit {
+				t.Errorf("current() = %d, want %d", got, tt.wantLimit)
+			}
+			if decreases != tt.wantDecreases {
+				t.Errorf("release() decreased the limit %d times, want %d", decreases, tt.wantDecreases)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiterRecovers(t *testing.T) {
+	l := newAdaptiveLimiter(8)
+	for l.current() > 1 {
+		epoch, _ := l.acquire(context.Background())
+		l.release(epoch, errQuota)
+	}
+
+	releases := 0
+	for ; l.current() < 8; releases++ {
+		if releases == 100 {
+			t.Fatalf("limit is %d after %d successful requests, want 8", l.current(), releases)
+		}
+		epoch, _ := l.acquire(context.Background())
+		l.release(epoch, nil)
+	}
+
+	for i := 0; i < 100; i++ {
+		epoch, _ := l.acquire(context.Background())
+		l.release(epoch, nil)
+	}
+	if got := l.current(); got != 8 {
+		t.Errorf("current() = %d after more successful requests, want the maximum 8", got)
+	}
+}
+
+func TestAdaptiveLimiterContention(t *testing.T) {
+	const maxInFlight = 8
+	l := newAdaptiveLimiter(maxInFlight)
+
+	var inFlight, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			epoch, err := l.acquire(context.Background())
+			if err != nil {
+				t.Errorf("acquire() error = %v", err)
+				return
+			}
+			n := inFlight.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			inFlight.Add(-1)
+
+			var outcome error
+			if i%10 == 9 {
+				outcome = errQuota
+			}
+			l.release(epoch, outcome)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > maxInFlight {
+		t.Errorf("%d requests in flight, want at most %d", got, maxInFlight)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight != 0 {
+		t.Errorf("inFlight = %d after all requests completed, want 0", l.inFlight)
+	}
+}
+
+func TestAdaptiveLimiterBlocksAtLimit(t *testing.T) {
+	l := newAdaptiveLimiter(4) // Limit of one request
+	epoch, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		_, err := l.acquire(context.Background())
+		acquired <- err
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire() returned while the limit was reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	l.release(epoch, nil)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("acquire() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire() did not return after a request completed")
+	}
+}
+
+func TestAdaptiveLimiterContextCancelled(t *testing.T) {
+	l := newAdaptiveLimiter(4) // Limit of one request
+	if _, err := l.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	acquired := make(chan error, 1)
+	go func() {
+		_, err := l.acquire(ctx)
+		acquired <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-acquired:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("acquire() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire() did not return after the context was cancelled")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight != 1 {
+		t.Errorf("inFlight = %d, want 1: a cancelled acquire must not take a slot", l.inFlight)
+	}
+}
+
+func TestServiceRepositoryCallRetries(t *testing.T) {
+	previous := quotaRetryDelay
+	quotaRetryDelay = time.Millisecond
+	t.Cleanup(func() { quotaRetryDelay = previous })
+
+	tests := []struct {
+		name         string
+		projectID    string
+		adaptive     bool
+		wantRequests int
+		wantErr      bool
+		wantLimit    int
+	}{
+		{name: "quota errors are retried", projectID: "gamma", adaptive: true, wantRequests: maxQuotaRetries + 1, wantErr: true, wantLimit: 1},
+		{name: "other errors are not retried", projectID: "beta", adaptive: true, wantRequests: 1, wantErr: true, wantLimit: 4},
+		{name: "success", projectID: "delta", adaptive: true, wantRequests: 1, wantLimit: 4},
+		{name: "no retries without adaptive mode", projectID: "gamma", wantRequests: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newStubClient(t, loadScenario(t))
+			var calls atomic.Int32
+			opts := []ServiceOption{WithCallObserver(func(error) { calls.Add(1) })}
+			if tt.adaptive {
+				opts = append(opts, WithAdaptiveConcurrency(16))
+			}
+			repo := NewServiceRepository(client.ServiceUsage, client.Monitoring, nil, opts...)
+
+			_, err := repo.ListServices(context.Background(), tt.projectID, 24*time.Hour)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListServices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := server.Requests(stub.MethodListServices); got != tt.wantRequests {
+				t.Errorf("services.list requests = %d, want %d", got, tt.wantRequests)
+			}
+			if got := int(calls.Load()); got != tt.wantRequests {
+				t.Errorf("observed calls = %d, want %d", got, tt.wantRequests)
+			}
+			if tt.adaptive {
+				if got := repo.adaptive.current(); got != tt.wantLimit {
+					t.Errorf("in-flight limit = %d, want %d", got, tt.wantLimit)
+				}
+			}
+		})
+	}
+}
+
+func TestServiceRepositoryCallContextCancelled(t *testing.T) {
+	previous := quotaRetryDelay
+	quotaRetryDelay = time.Hour
+	t.Cleanup(func() { quotaRetryDelay = previous })
+
+	client, server := newStubClient(t, loadScenario(t))
+	repo := NewServiceRepository(client.ServiceUsage, client.Monitoring, nil, WithAdaptiveConcurrency(16))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := repo.ListServices(ctx, "gamma", 24*time.Hour)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ListServices() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := server.Requests(stub.MethodListServices); got != 1 {
+		t.Errorf("services.list requests = %d, want 1 before the retry delay", got)
+	}
+}
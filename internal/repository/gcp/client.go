@@ -3,45 +3,278 @@ package gcp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
 
 	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"github.com/ybonda/gcp-auditor/internal/replay"
+	"golang.org/x/oauth2/google"
 	resourcemanager "google.golang.org/api/cloudresourcemanager/v1"
+	resourcemanagerv3 "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
 	serviceusage "google.golang.org/api/serviceusage/v1"
+	htransport "google.golang.org/api/transport/http"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
 type Client struct {
-	ResourceManager *resourcemanager.Service
-	ServiceUsage    *serviceusage.Service
-	Monitoring      *monitoring.MetricClient
+	ResourceManager   *resourcemanager.Service
+	ResourceManagerV3 *resourcemanagerv3.Service // Resolves folder ancestry
+	ServiceUsage      *serviceusage.Service
+	Monitoring        *monitoring.MetricClient
+	QuotaProject      string // Project the API quota is billed to, empty when unknown
+}
+
+type clientOptions struct {
+	credentialsFile string
+	delegationChain []string
+	quotaProject    string
+	cassette        *replay.Cassette
+	emulator        *emulator
+}
+
+// emulator is a local server standing in for the GCP APIs
+type emulator struct {
+	httpURL  string
+	grpcAddr string
+}
+
+type ClientOption func(*clientOptions)
+
+// WithCredentialsFile authenticates with a service account key or external account file
+// instead of Application Default Credentials
+func WithCredentialsFile(path string) ClientOption {
+	return func(o *clientOptions) {
+		o.credentialsFile = path
+	}
+}
+
+// WithImpersonation impersonates the last service account of the chain. The preceding accounts
+// are delegates, each of which must be allowed to impersonate the next one.
+func WithImpersonation(chain []string) ClientOption {
+	return func(o *clientOptions) {
+		o.delegationChain = chain
+	}
+}
+
+// WithQuotaProject bills the quota of Monitoring API calls to a project
+func WithQuotaProject(projectID string) ClientOption {
+	return func(o *clientOptions) {
+		o.quotaProject = projectID
+	}
+}
+
+// WithCassette records all API calls into the cassette's fixtures, or replays them from there.
+// Replaying clients need neither credentials nor network access.
+func WithCassette(cassette *replay.Cassette) ClientOption {
+	return func(o *clientOptions) {
+		o.cassette = cassette
+	}
 }
 
-func NewClient(ctx context.Context) (*Client, error) {
-	// Initialize Resource Manager client
-	resourceManagerService, err := resourcemanager.NewService(ctx)
+// WithEmulator sends the REST API calls to httpURL and the gRPC calls to grpcAddr instead of
+// Google, without credentials or TLS. It is meant for local stub servers in tests. With
+// WithCassette, calls are recorded under the hosts of the Google APIs, so that the fixtures
+// replay without the emulator.
+func WithEmulator(httpURL, grpcAddr string) ClientOption {
+	return func(o *clientOptions) {
+		o.emulator = &emulator{httpURL: httpURL, grpcAddr: grpcAddr}
+	}
+}
+
+func NewClient(ctx context.Context, opts ...ClientOption) (*Client, error) {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	authOpts, err := o.authOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Monitoring uses gRPC, the other APIs use HTTP
+	monitoringOpts := authOpts
+	if o.quotaProject != "" {
+		monitoringOpts = append(monitoringOpts[:len(monitoringOpts):len(monitoringOpts)], option.WithQuotaProject(o.quotaProject))
+	}
+	switch {
+	case o.emulator != nil && o.cassette != nil:
+		authOpts, monitoringOpts = o.emulator.cassetteOptions(o.cassette)
+	case o.emulator != nil:
+		authOpts, monitoringOpts = o.emulator.options()
+	case o.cassette != nil:
+		authOpts, monitoringOpts, err = o.cassetteOptions(ctx, authOpts, monitoringOpts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Initialize Resource Manager clients
+	resourceManagerService, err := resourcemanager.NewService(ctx, authOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource manager client: %w", err)
 	}
+	resourceManagerV3Service, err := resourcemanagerv3.NewService(ctx, authOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource manager v3 client: %w", err)
+	}
 
 	// Initialize Service Usage client
-	serviceUsageService, err := serviceusage.NewService(ctx)
+	serviceUsageService, err := serviceusage.NewService(ctx, authOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create service usage client: %w", err)
 	}
 
 	// Initialize Monitoring client
-	monitoringClient, err := monitoring.NewMetricClient(ctx)
+	monitoringClient, err := monitoring.NewMetricClient(ctx, monitoringOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create monitoring client: %w", err)
 	}
 
 	return &Client{
-		ResourceManager: resourceManagerService,
-		ServiceUsage:    serviceUsageService,
-		Monitoring:      monitoringClient,
+		ResourceManager:   resourceManagerService,
+		ResourceManagerV3: resourceManagerV3Service,
+		ServiceUsage:      serviceUsageService,
+		Monitoring:        monitoringClient,
+		QuotaProject:      o.resolveQuotaProject(ctx),
 	}, nil
 }
 
+// resolveQuotaProject returns the project the API quota is billed to: the one set with
+// WithQuotaProject or GOOGLE_CLOUD_QUOTA_PROJECT, or else the quota_project_id of the
+// credentials file or Application Default Credentials. Impersonated credentials carry no
+// quota project of their own.
+func (o clientOptions) resolveQuotaProject(ctx context.Context) string {
+	if o.emulator != nil || (o.cassette != nil && !o.cassette.Recording()) {
+		return o.quotaProject
+	}
+	if o.quotaProject != "" {
+		return o.quotaProject
+	}
+	if project := os.Getenv("GOOGLE_CLOUD_QUOTA_PROJECT"); project != "" {
+		return project
+	}
+	if len(o.delegationChain) > 0 {
+		return ""
+	}
+
+	var data []byte
+	if o.credentialsFile != "" {
+		data, _ = os.ReadFile(o.credentialsFile)
+	} else if credentials, err := google.FindDefaultCredentials(ctx, cloudPlatformScope); err == nil {
+		data = credentials.JSON
+	}
+	var file struct {
+		QuotaProjectID string `json:"quota_project_id"`
+	}
+	if json.Unmarshal(data, &file) != nil {
+		return ""
+	}
+	return file.QuotaProjectID
+}
+
+// cassetteOptions routes the HTTP and gRPC clients through the cassette. When recording, the
+// HTTP transport is authenticated with the credentials before it is wrapped.
+func (o clientOptions) cassetteOptions(ctx context.Context, httpOpts, grpcOpts []option.ClientOption) ([]option.ClientOption, []option.ClientOption, error) {
+	interceptor := option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(o.cassette.UnaryInterceptor()))
+	if !o.cassette.Recording() {
+		httpClient := &http.Client{Transport: o.cassette.RoundTripper(nil)}
+		return []option.ClientOption{option.WithHTTPClient(httpClient)},
+			[]option.ClientOption{option.WithoutAuthentication(), interceptor}, nil
+	}
+
+	authenticated, err := htransport.NewTransport(ctx, http.DefaultTransport, append(httpOpts, option.WithScopes(cloudPlatformScope))...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create recording transport: %w", err)
+	}
+	httpClient := &http.Client{Transport: o.cassette.RoundTripper(authenticated)}
+	return []option.ClientOption{option.WithHTTPClient(httpClient)}, append(grpcOpts, interceptor), nil
+}
+
+// options returns the HTTP and gRPC client options connecting to the emulator
+func (e *emulator) options() ([]option.ClientOption, []option.ClientOption) {
+	httpOpts := []option.ClientOption{
+		option.WithEndpoint(strings.TrimSuffix(e.httpURL, "/") + "/"),
+		option.WithoutAuthentication(),
+	}
+	grpcOpts := []option.ClientOption{
+		option.WithEndpoint(e.grpcAddr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+	return httpOpts, grpcOpts
+}
+
+// cassetteOptions returns the HTTP and gRPC client options connecting to the emulator through
+// the cassette, with the endpoints of the Google APIs
+func (e *emulator) cassetteOptions(cassette *replay.Cassette) ([]option.ClientOption, []option.ClientOption) {
+	httpClient := &http.Client{Transport: cassette.RoundTripper(&emulatorTransport{url: e.httpURL})}
+	httpOpts := []option.ClientOption{option.WithHTTPClient(httpClient)}
+	grpcOpts := []option.ClientOption{
+		option.WithEndpoint("passthrough:///monitoring.googleapis.com:443"),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		option.WithGRPCDialOption(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "tcp", e.grpcAddr)
+		})),
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(cassette.UnaryInterceptor())),
+	}
+	return httpOpts, grpcOpts
+}
+
+// emulatorTransport sends HTTP requests to the emulator, whatever their host
+type emulatorTransport struct {
+	url string
+}
+
+func (t *emulatorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(t.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid emulator URL: %w", err)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.Host = ""
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// authOptions returns the client options selecting the credentials
+func (o clientOptions) authOptions(ctx context.Context) ([]option.ClientOption, error) {
+	if o.emulator != nil || (o.cassette != nil && !o.cassette.Recording()) {
+		return nil, nil
+	}
+
+	var opts []option.ClientOption
+	if o.credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(o.credentialsFile))
+	}
+	if len(o.delegationChain) == 0 {
+		return opts, nil
+	}
+
+	target := o.delegationChain[len(o.delegationChain)-1]
+	tokenSource, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: target,
+		Delegates:       o.delegationChain[:len(o.delegationChain)-1],
+		Scopes:          []string{cloudPlatformScope},
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to impersonate service account %s: %w", target, err)
+	}
+	return []option.ClientOption{option.WithTokenSource(tokenSource)}, nil
+}
+
 func (c *Client) Close() error {
 	if err := c.Monitoring.Close(); err != nil {
 		return fmt.Errorf("failed to close monitoring client: %w", err)
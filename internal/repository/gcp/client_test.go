@@ -0,0 +1,51 @@
+package gcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveQuotaProject(t *testing.T) {
+	dir := t.TempDir()
+	writeCredentials := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	withQuota := writeCredentials("with-quota.json",
+		`{"type": "authorized_user", "client_id": "id", "client_secret": "secret", "refresh_token": "token", "quota_project_id": "billing"}`)
+	withoutQuota := writeCredentials("without-quota.json",
+		`{"type": "authorized_user", "client_id": "id", "client_secret": "secret", "refresh_token": "token"}`)
+
+	tests := []struct {
+		name string
+		opts clientOptions
+		adc  string // GOOGLE_APPLICATION_CREDENTIALS
+		env  string // GOOGLE_CLOUD_QUOTA_PROJECT
+		want string
+	}{
+		{name: "configured", opts: clientOptions{quotaProject: "ops", credentialsFile: withQuota}, env: "env", want: "ops"},
+		{name: "environment", opts: clientOptions{credentialsFile: withQuota}, env: "env", want: "env"},
+		{name: "credentials file", opts: clientOptions{credentialsFile: withQuota}, adc: withoutQuota, want: "billing"},
+		{name: "application default credentials", adc: withQuota, want: "billing"},
+		{name: "credentials without quota project", adc: withoutQuota},
+		{name: "impersonation", opts: clientOptions{delegationChain: []string{"auditor@ops.iam.gserviceaccount.com"}}, adc: withQuota},
+		{name: "emulator", opts: clientOptions{emulator: &emulator{}}, adc: withQuota},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", dir) // No gcloud application default credentials
+			t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", tt.adc)
+			t.Setenv("GOOGLE_CLOUD_QUOTA_PROJECT", tt.env)
+
+			if got := tt.opts.resolveQuotaProject(context.Background()); got != tt.want {
+				t.Errorf("resolveQuotaProject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,557 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/replay"
+	"github.com/ybonda/gcp-auditor/internal/repository/stub"
+	"github.com/ybonda/gcp-auditor/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/api/googleapi"
+)
+
+// newStubClient starts a stub server for the scenario and returns a client connected to it
+func newStubClient(t *testing.T, scenario *stub.Scenario) (*Client, *stub.Server) {
+	t.Helper()
+
+	server, err := stub.NewServer(scenario)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(context.Background(), WithEmulator(server.URL, server.GRPCAddr))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client, server
+}
+
+func loadScenario(t *testing.T) *stub.Scenario {
+	t.Helper()
+
+	scenario, err := stub.LoadScenario("testdata/scenario.yaml")
+	if err != nil {
+		t.Fatalf("LoadScenario() error = %v", err)
+	}
+	return scenario
+}
+
+func TestIntegrationListProjects(t *testing.T) {
+	client, server := newStubClient(t, loadScenario(t))
+	repo := NewProjectRepository(client.ResourceManager, nil)
+
+	projects, err := repo.ListProjects(context.Background())
+	if err != nil {
+		t.Fatalf("ListProjects() error = %v", err)
+	}
+
+	var ids []string
+	for _, project := range projects {
+		ids = append(ids, project.ID)
+	}
+	wantIDs := []string{"alpha", "beta", "gamma", "delta", "sys-12345678901234567890"}
+	if !reflect.DeepEqual(ids, wantIDs) {
+		t.Errorf("ListProjects() = %v, want %v", ids, wantIDs)
+	}
+	if got := server.Requests(stub.MethodListProjects); got != 3 {
+		t.Errorf("projects.list requests = %d, want 3 pages", got)
+	}
+
+	want := domain.Project{
+		ID:         "alpha",
+		Name:       "Alpha",
+		ProjectNum: 1001,
+		Labels:     map[string]string{"env": "prod"},
+		CreateTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Parent:     "folders/10",
+	}
+	if !reflect.DeepEqual(projects[0], want) {
+		t.Errorf("ListProjects()[0] = %+v, want %+v", projects[0], want)
+	}
+	if projects[3].Parent != "" {
+		t.Errorf("project delta has parent %q, want none", projects[3].Parent)
+	}
+}
+
+func TestIntegrationListProjectsOfOrganization(t *testing.T) {
+	// A project shared from another organization sits in a folder the caller may not read
+	unreadable, err := stub.ParseScenario([]byte(`folders:
+  - name: folders/10
+    parent: organizations/123
+projects:
+  - id: alpha
+    parent: folders/10
+  - id: shared
+    parent: folders/99
+  - id: shared-too
+    parent: folders/99
+  - id: beta
+    parent: organizations/123
+`))
+	if err != nil {
+		t.Fatalf("ParseScenario() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		scenario    *stub.Scenario
+		want        []string
+		wantFolders int
+	}{
+		{
+			name:     "folders",
+			scenario: loadScenario(t),
+			want:     []string{"alpha", "beta", "sys-12345678901234567890"},
+			// folders/10 and folders/11 for alpha, folders/20 for gamma
+			wantFolders: 3,
+		},
+		{
+			name:     "unreadable folder",
+			scenario: unreadable,
+			want:     []string{"alpha", "beta"},
+			// folders/10 for alpha, folders/99 once for both shared projects
+			wantFolders: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newStubClient(t, tt.scenario)
+			repo := NewProjectRepository(client.ResourceManager, nil, WithOrganization("123", client.ResourceManagerV3))
+
+			projects, err := repo.ListProjects(context.Background())
+			if err != nil {
+				t.Fatalf("ListProjects() error = %v", err)
+			}
+
+			var ids []string
+			for _, project := range projects {
+				ids = append(ids, project.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ListProjects() = %v, want %v", ids, tt.want)
+			}
+			if got := server.Requests(stub.MethodGetFolder); got != tt.wantFolders {
+				t.Errorf("folders.get requests = %d, want %d", got, tt.wantFolders)
+			}
+		})
+	}
+}
+
+func TestIntegrationListProjectsFolderNames(t *testing.T) {
+	scenario, err := stub.ParseScenario([]byte(`folders:
+  - name: folders/10
+    display_name: Analytics
+    parent: organizations/123
+projects:
+  - id: alpha
+    parent: folders/10
+  - id: beta
+    parent: folders/10
+  - id: shared
+    parent: folders/99
+  - id: gamma
+    parent: organizations/123
+`))
+	if err != nil {
+		t.Fatalf("ParseScenario() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		organization string
+		want         map[string]string // Project ID to folder name
+		wantFolders  int
+	}{
+		{
+			name: "folder names",
+			want: map[string]string{"alpha": "Analytics", "beta": "Analytics", "shared": "", "gamma": ""},
+			// folders/10 once for alpha and beta, folders/99 once
+			wantFolders: 2,
+		},
+		{
+			name:         "organization",
+			organization: "123",
+			// The project in the unreadable folder is outside of the organization
+			want: map[string]string{"alpha": "Analytics", "beta": "Analytics", "gamma": ""},
+			// The names are read by the ancestry walk
+			wantFolders: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newStubClient(t, scenario)
+			opts := []ProjectOption{WithFolderNames(client.ResourceManagerV3)}
+			if tt.organization != "" {
+				opts = append(opts, WithOrganization(tt.organization, client.ResourceManagerV3))
+			}
+			repo := NewProjectRepository(client.ResourceManager, nil, opts...)
+
+			projects, err := repo.ListProjects(context.Background())
+			if err != nil {
+				t.Fatalf("ListProjects() error = %v", err)
+			}
+
+			names := make(map[string]string)
+			for _, project := range projects {
+				names[project.ID] = project.FolderName
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("folder names = %v, want %v", names, tt.want)
+			}
+			if got := server.Requests(stub.MethodGetFolder); got != tt.wantFolders {
+				t.Errorf("folders.get requests = %d, want %d", got, tt.wantFolders)
+			}
+		})
+	}
+}
+
+func TestIntegrationListProjectsTimeout(t *testing.T) {
+	scenario, err := stub.ParseScenario([]byte("latency: 10s\nprojects:\n  - id: alpha\n"))
+	if err != nil {
+		t.Fatalf("ParseScenario() error = %v", err)
+	}
+	client, _ := newStubClient(t, scenario)
+	repo := NewProjectRepository(client.ResourceManager, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = repo.ListProjects(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ListProjects() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ListProjects() returned after %s", elapsed)
+	}
+}
+
+func TestIntegrationListServices(t *testing.T) {
+	client, server := newStubClient(t, loadScenario(t))
+	repo := NewServiceRepository(client.ServiceUsage, client.Monitoring, nil, WithUsageTimeout(200*time.Millisecond))
+
+	services, err := repo.ListServices(context.Background(), "alpha", 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("ListServices() error = %v", err)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	type result struct {
+		name         string
+		title        string
+		status       domain.UsageStatus
+		requestCount int64
+		error        string // Substring of the usage error
+	}
+	want := []result{
+		{"bigquery.googleapis.com", "BigQuery API", domain.UsageStatusSuccess, 42, ""},
+		{"compute.googleapis.com", "Compute Engine API", domain.UsageStatusNoAccess, 0, "No access to monitoring data"},
+		{"pubsub.googleapis.com", "Cloud Pub/Sub API", domain.UsageStatusError, 0, "Internal error encountered"},
+		{"slow.googleapis.com", "Slow API", domain.UsageStatusError, 0, "deadline exceeded"},
+		{"storage.googleapis.com", "Cloud Storage API", domain.UsageStatusSuccess, 0, ""},
+	}
+	if len(services) != len(want) {
+		t.Fatalf("ListServices() returned %d services, want %d", len(services), len(want))
+	}
+	for i, service := range services {
+		w := want[i]
+		if service.Name != w.name || service.Title != w.title || service.State != "ENABLED" || service.ProjectID != "alpha" {
+			t.Errorf("service %d = %s %q %s %s, want %s %q ENABLED alpha", i, service.Name, service.Title, service.State, service.ProjectID, w.name, w.title)
+		}
+		if service.Usage == nil {
+			t.Errorf("service %s has no usage", service.Name)
+			continue
+		}
+		if service.Usage.Status != w.status || service.Usage.RequestCount != w.requestCount {
+			t.Errorf("service %s usage = %s %d, want %s %d", service.Name, service.Usage.Status, service.Usage.RequestCount, w.status, w.requestCount)
+		}
+		if !strings.Contains(service.Usage.Error, w.error) {
+			t.Errorf("service %s usage error = %q, want it to contain %q", service.Name, service.Usage.Error, w.error)
+		}
+	}
+
+	// Five services on three pages, and the three time series of bigquery on two pages
+	if got := server.Requests(stub.MethodListServices); got != 3 {
+		t.Errorf("services.list requests = %d, want 3 pages", got)
+	}
+	if got := server.Requests(stub.MethodListTimeSeries); got != 6 {
+		t.Errorf("ListTimeSeries requests = %d, want 6", got)
+	}
+}
+
+func TestIntegrationListServicesErrors(t *testing.T) {
+	client, _ := newStubClient(t, loadScenario(t))
+	repo := NewServiceRepository(client.ServiceUsage, client.Monitoring, nil)
+
+	tests := []struct {
+		projectID string
+		code      int
+		quota     bool
+	}{
+		{projectID: "beta", code: http.StatusForbidden},
+		{projectID: "gamma", code: http.StatusTooManyRequests, quota: true},
+		{projectID: "unknown", code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.projectID, func(t *testing.T) {
+			_, err := repo.ListServices(context.Background(), tt.projectID, 24*time.Hour)
+			if err == nil {
+				t.Fatal("ListServices() error = nil")
+			}
+
+			var apiErr *googleapi.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("ListServices() error = %v, want a googleapi.Error", err)
+			}
+			if apiErr.Code != tt.code {
+				t.Errorf("ListServices() error code = %d, want %d", apiErr.Code, tt.code)
+			}
+			if got := isQuotaError(err); got != tt.quota {
+				t.Errorf("isQuotaError() = %v, want %v", got, tt.quota)
+			}
+		})
+	}
+}
+
+func TestIntegrationListServicesSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	client, _ := newStubClient(t, loadScenario(t))
+	repo := NewServiceRepository(client.ServiceUsage, client.Monitoring, nil, WithUsageTimeout(200*time.Millisecond))
+
+	if _, err := repo.ListServices(context.Background(), "alpha", 30*24*time.Hour); err != nil {
+		t.Fatalf("ListServices() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	byName := make(map[string][]tracetest.SpanStub)
+	for _, span := range spans {
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+
+	root := byName["ListServices"]
+	if len(root) != 1 {
+		t.Fatalf("ListServices spans = %d, want 1", len(root))
+	}
+	if got := spanAttribute(root[0], tracing.AttrProject); got != "alpha" {
+		t.Errorf("ListServices project = %q, want alpha", got)
+	}
+	if got := spanAttribute(root[0], tracing.AttrCount); got != "5" {
+		t.Errorf("ListServices count = %q, want 5", got)
+	}
+
+	var pages []string
+	for _, span := range byName["serviceusage.services.list"] {
+		pages = append(pages, spanAttribute(span, tracing.AttrPage))
+		if span.Parent.SpanID() != root[0].SpanContext.SpanID() {
+			t.Errorf("services.list page %s is not a child of ListServices", spanAttribute(span, tracing.AttrPage))
+		}
+		if got := spanAttribute(span, tracing.AttrRetries); got != "0" {
+			t.Errorf("services.list page %s retries = %q, want 0", spanAttribute(span, tracing.AttrPage), got)
+		}
+	}
+	sort.Strings(pages)
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("services.list pages = %v, want %v", pages, want)
+	}
+
+	statuses := make(map[string]string)
+	for _, span := range byName["GetServiceUsage"] {
+		service := spanAttribute(span, tracing.AttrService)
+		statuses[service] = spanAttribute(span, tracing.AttrStatus)
+		if wantError := statuses[service] == string(domain.UsageStatusError); (span.Status.Code == codes.Error) != wantError {
+			t.Errorf("GetServiceUsage %s span status = %v, usage status %s", service, span.Status.Code, statuses[service])
+		}
+	}
+	wantStatuses := map[string]string{
+		"bigquery.googleapis.com": "SUCCESS",
+		"compute.googleapis.com":  "NO_ACCESS",
+		"pubsub.googleapis.com":   "ERROR",
+		"slow.googleapis.com":     "ERROR",
+		"storage.googleapis.com":  "SUCCESS",
+	}
+	if !reflect.DeepEqual(statuses, wantStatuses) {
+		t.Errorf("GetServiceUsage statuses = %v, want %v", statuses, wantStatuses)
+	}
+}
+
+// spanAttribute returns the value of an attribute of a span as a string, or "" if it is not set
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) string {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+// TestIntegrationReplay records the calls to the stub server and replays them without it
+func TestIntegrationReplay(t *testing.T) {
+	dir := t.TempDir()
+	recording, err := replay.Record(dir)
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	server, err := stub.NewServer(loadScenario(t))
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	recorded := auditWithClient(t, WithEmulator(server.URL, server.GRPCAddr), WithCassette(recording))
+	server.Close()
+	if err := recording.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	for _, host := range []string{"cloudresourcemanager.googleapis.com", "serviceusage.googleapis.com", "monitoring.googleapis.com"} {
+		if _, err := os.Stat(filepath.Join(dir, host+".jsonl")); err != nil {
+			t.Errorf("no fixtures recorded for %s: %v", host, err)
+		}
+	}
+
+	cassette, err := replay.Replay(dir)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	replayed := auditWithClient(t, WithCassette(cassette))
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed audit = %v, want the recorded %v", replayed, recorded)
+	}
+}
+
+// auditWithClient lists the projects of a client and the services of alpha, beta and gamma,
+// and summarizes the results as one line per project, service or error
+func auditWithClient(t *testing.T, opts ...ClientOption) []string {
+	t.Helper()
+
+	client, err := NewClient(context.Background(), opts...)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	projects, err := NewProjectRepository(client.ResourceManager, nil).ListProjects(context.Background())
+	if err != nil {
+		t.Fatalf("ListProjects() error = %v", err)
+	}
+	var lines []string
+	for _, project := range projects {
+		lines = append(lines, fmt.Sprintf("project %s %q %s", project.ID, project.Name, project.Parent))
+	}
+
+	repo := NewServiceRepository(client.ServiceUsage, client.Monitoring, nil, WithUsageTimeout(200*time.Millisecond))
+	for _, projectID := range []string{"alpha", "beta", "gamma"} {
+		services, err := repo.ListServices(context.Background(), projectID, 30*24*time.Hour)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("error %s %v", projectID, err))
+			continue
+		}
+		for _, service := range services {
+			lines = append(lines, fmt.Sprintf("service %s %s %s %d %q", projectID, service.Name,
+				service.Usage.Status, service.Usage.RequestCount, service.Usage.Error))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// TestIntegrationListServicesResults checks that every usage lookup of a project is returned
+// once, however many workers run them
+func TestIntegrationListServicesResults(t *testing.T) {
+	scenario := &stub.Scenario{PageSize: 7, Projects: []stub.Project{{ID: "alpha"}}}
+	want := make(map[string]int64)
+	for i := 0; i < 60; i++ {
+		name := fmt.Sprintf("api%02d.googleapis.com", i)
+		scenario.Projects[0].Services = append(scenario.Projects[0].Services, stub.Service{Name: name, Requests: []int64{int64(i)}})
+		want[name] = int64(i)
+	}
+	client, _ := newStubClient(t, scenario)
+
+	for _, workers := range []int{1, 8, 100} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			repo := NewServiceRepository(client.ServiceUsage, client.Monitoring, nil, WithWorkerCount(workers))
+			services, err := repo.ListServices(context.Background(), "alpha", 24*time.Hour)
+			if err != nil {
+				t.Fatalf("ListServices() error = %v", err)
+			}
+
+			got := make(map[string]int64)
+			for _, service := range services {
+				if service.Usage == nil {
+					t.Fatalf("service %q has no usage", service.Name)
+				}
+				if _, ok := got[service.Name]; ok {
+					t.Errorf("service %q returned more than once", service.Name)
+				}
+				got[service.Name] = service.Usage.RequestCount
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ListServices() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestIntegrationListServicesEmptyNames checks that services without a name are left out of
+// the results rather than returned as empty services
+func TestIntegrationListServicesEmptyNames(t *testing.T) {
+	server, err := stub.NewServer(loadScenario(t))
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	// Service Usage lists services without names next to bigquery, whose usage the stub serves
+	serviceUsage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"services": [
+			{"name": "projects/alpha/services/", "state": "ENABLED"},
+			{"name": "", "state": "ENABLED"},
+			{"name": "projects/alpha/services/bigquery.googleapis.com", "state": "ENABLED", "config": {"title": "BigQuery API"}}
+		]}`)
+	}))
+	t.Cleanup(serviceUsage.Close)
+
+	client, err := NewClient(context.Background(), WithEmulator(serviceUsage.URL, server.GRPCAddr))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	repo := NewServiceRepository(client.ServiceUsage, client.Monitoring, nil)
+
+	services, err := repo.ListServices(context.Background(), "alpha", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("ListServices() error = %v", err)
+	}
+	if len(services) != 1 || services[0].Name != "bigquery.googleapis.com" || services[0].Usage.RequestCount != 42 {
+		t.Errorf("ListServices() = %+v, want only bigquery.googleapis.com with 42 requests", services)
+	}
+}
@@ -0,0 +1,93 @@
+// internal/repository/gcp/organizations.go
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// OrganizationSource lists the projects of one organization and reads their services with
+// the organization's credentials
+type OrganizationSource struct {
+	Organization string
+	Projects     *ProjectRepository
+	Services     *ServiceRepository
+}
+
+// MultiRepository audits several organizations in one run. It implements both
+// domain.ProjectRepository and domain.ServiceRepository: the services of a project are read
+// with the credentials of the source that listed it. Projects visible to several sources are
+// listed once, by the first source.
+type MultiRepository struct {
+	sources []OrganizationSource
+
+	mu     sync.RWMutex
+	owners map[string]*ServiceRepository // Project ID to the repository of its source
+}
+
+func NewMultiRepository(sources []OrganizationSource) *MultiRepository {
+	return &MultiRepository{
+		sources: sources,
+		owners:  make(map[string]*ServiceRepository),
+	}
+}
+
+func (r *MultiRepository) ListProjects(ctx context.Context) ([]domain.Project, error) {
+	owners := make(map[string]*ServiceRepository)
+	var projects []domain.Project
+	for _, source := range r.sources {
+		sourceProjects, err := source.Projects.ListProjects(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("organization %s: %w", source.Organization, err)
+		}
+
+		for _, project := range sourceProjects {
+			if _, listed := owners[project.ID]; listed {
+				continue
+			}
+			owners[project.ID] = source.Services
+			projects = append(projects, project)
+		}
+	}
+
+	r.mu.Lock()
+	r.owners = owners
+	r.mu.Unlock()
+
+	return projects, nil
+}
+
+func (r *MultiRepository) IsValidProject(project domain.Project) bool {
+	return !IsSystemProject(project.ID)
+}
+
+func (r *MultiRepository) ListServices(ctx context.Context, projectID string, period time.Duration) ([]domain.Service, error) {
+	services, err := r.owner(projectID)
+	if err != nil {
+		return nil, err
+	}
+	return services.ListServices(ctx, projectID, period)
+}
+
+func (r *MultiRepository) GetServiceUsage(ctx context.Context, projectID, serviceName string, period time.Duration) (*domain.Usage, error) {
+	services, err := r.owner(projectID)
+	if err != nil {
+		return nil, err
+	}
+	return services.GetServiceUsage(ctx, projectID, serviceName, period)
+}
+
+func (r *MultiRepository) owner(projectID string) (*ServiceRepository, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	services, ok := r.owners[projectID]
+	if !ok {
+		return nil, fmt.Errorf("project %s was not listed by any organization", projectID)
+	}
+	return services, nil
+}
@@ -0,0 +1,106 @@
+// internal/repository/gcp/permissions.go
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	resourcemanager "google.golang.org/api/cloudresourcemanager/v1"
+	serviceusage "google.golang.org/api/serviceusage/v1"
+)
+
+// PermissionRepository checks the permissions and APIs an audit needs without crawling projects
+type PermissionRepository struct {
+	resourceManager *resourcemanager.Service
+	serviceUsage    *serviceusage.Service
+}
+
+func NewPermissionRepository(client *Client) *PermissionRepository {
+	return &PermissionRepository{
+		resourceManager: client.ResourceManager,
+		serviceUsage:    client.ServiceUsage,
+	}
+}
+
+// SampleProjects returns up to n visible projects accepted by keep, in listing order
+func (r *PermissionRepository) SampleProjects(ctx context.Context, n int, keep func(domain.Project) bool) ([]domain.Project, error) {
+	var sample []domain.Project
+	pageToken := ""
+	for len(sample) < n {
+		call := r.resourceManager.Projects.List().PageSize(100).Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		resp, err := call.Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list projects: %w", err)
+		}
+
+		for _, p := range resp.Projects {
+			createTime, _ := time.Parse(time.RFC3339, p.CreateTime)
+			project := domain.Project{
+				ID:         p.ProjectId,
+				Name:       p.Name,
+				ProjectNum: p.ProjectNumber,
+				Labels:     p.Labels,
+				CreateTime: createTime,
+				Parent:     parentName(p.Parent),
+			}
+			if keep(project) && len(sample) < n {
+				sample = append(sample, project)
+			}
+		}
+
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	return sample, nil
+}
+
+// TestProjectPermissions returns the permissions the caller holds on a project
+func (r *PermissionRepository) TestProjectPermissions(ctx context.Context, projectID string, permissions []string) ([]string, error) {
+	resp, err := r.resourceManager.Projects.TestIamPermissions(projectID, &resourcemanager.TestIamPermissionsRequest{
+		Permissions: permissions,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to test permissions on project %s: %w", projectID, err)
+	}
+	return resp.Permissions, nil
+}
+
+// TestOrganizationPermissions returns the permissions the caller holds on an organization
+func (r *PermissionRepository) TestOrganizationPermissions(ctx context.Context, organizationID string, permissions []string) ([]string, error) {
+	resp, err := r.resourceManager.Organizations.TestIamPermissions("organizations/"+organizationID, &resourcemanager.TestIamPermissionsRequest{
+		Permissions: permissions,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to test permissions on organization %s: %w", organizationID, err)
+	}
+	return resp.Permissions, nil
+}
+
+// EnabledServices reports which of the services, e.g. "monitoring.googleapis.com", are enabled
+// on a project
+func (r *PermissionRepository) EnabledServices(ctx context.Context, projectID string, services []string) (map[string]bool, error) {
+	parent := fmt.Sprintf("projects/%s", projectID)
+	names := make([]string, 0, len(services))
+	for _, service := range services {
+		names = append(names, parent+"/services/"+service)
+	}
+
+	resp, err := r.serviceUsage.Services.BatchGet(parent).Names(names...).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get services of project %s: %w", projectID, err)
+	}
+
+	enabled := make(map[string]bool, len(services))
+	for _, service := range resp.Services {
+		enabled[cleanServiceName(service.Name)] = service.State == "ENABLED"
+	}
+	return enabled, nil
+}
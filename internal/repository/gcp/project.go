@@ -2,30 +2,81 @@ package gcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/tracing"
 	"github.com/ybonda/gcp-auditor/pkg/logging"
+	"go.opentelemetry.io/otel/trace"
 	resourcemanager "google.golang.org/api/cloudresourcemanager/v1"
+	resourcemanagerv3 "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/googleapi"
 )
 
 var systemPattern = regexp.MustCompile(`^sys-\d+`)
 
+var tracer = tracing.Tracer("github.com/ybonda/gcp-auditor/internal/repository/gcp")
+
 type ProjectRepository struct {
-	service *resourcemanager.Service
-	logger  *logging.Logger
+	service      *resourcemanager.Service
+	logger       *slog.Logger
+	organization string                     // Only list projects of this organization; empty lists all
+	folders      *resourcemanagerv3.Service // Resolves the organization and display name of folders
+	folderRoots  map[string]string          // Folder name to the organization it belongs to, "" if none or unreadable
+	folderNames  map[string]string          // Folder name to its display name, "" if unreadable; nil when not resolved
+}
+
+type ProjectOption func(*ProjectRepository)
+
+// WithOrganization only lists the projects of an organization, including those in its folders.
+// Folder ancestry is resolved with the Resource Manager v3 API.
+func WithOrganization(organizationID string, folders *resourcemanagerv3.Service) ProjectOption {
+	return func(r *ProjectRepository) {
+		r.organization = organizationID
+		r.folders = folders
+	}
+}
+
+// WithFolderNames sets the display name of the folder each project is in, used to title
+// rollups by folder. Folders are read with the Resource Manager v3 API.
+func WithFolderNames(folders *resourcemanagerv3.Service) ProjectOption {
+	return func(r *ProjectRepository) {
+		r.folders = folders
+		r.folderNames = make(map[string]string)
+	}
 }
 
-func NewProjectRepository(service *resourcemanager.Service, verbose bool) *ProjectRepository {
-	return &ProjectRepository{
-		service: service,
-		logger:  logging.NewLogger(verbose),
+// NewProjectRepository returns a repository listing projects with service. A nil logger
+// discards log records.
+func NewProjectRepository(service *resourcemanager.Service, logger *slog.Logger, opts ...ProjectOption) *ProjectRepository {
+	r := &ProjectRepository{
+		service:     service,
+		logger:      logging.OrDiscard(logger),
+		folderRoots: make(map[string]string),
+	}
+
+	for _, opt := range opts {
+		opt(r)
 	}
+
+	return r
 }
 
 func (r *ProjectRepository) ListProjects(ctx context.Context) ([]domain.Project, error) {
+	ctx, span := tracer.Start(ctx, "ListProjects")
+	projects, err := r.listProjects(ctx)
+	span.SetAttributes(tracing.AttrCount.Int(len(projects)))
+	tracing.End(span, err)
+	return projects, err
+}
+
+func (r *ProjectRepository) listProjects(ctx context.Context) ([]domain.Project, error) {
 	var projects []domain.Project
 	pageToken := ""
 	pageCount := 0
@@ -33,28 +84,32 @@ func (r *ProjectRepository) ListProjects(ctx context.Context) ([]domain.Project,
 
 	for {
 		pageCount++
-		r.logger.Debug("Fetching projects page %d ", pageCount)
+		r.logger.Debug("Fetching projects page", "page", pageCount)
 
 		// Create list request with page token
-		call := r.service.Projects.List().Context(ctx)
+		pageCtx, span := tracer.Start(ctx, "resourcemanager.projects.list",
+			trace.WithAttributes(tracing.AttrPage.Int(pageCount)))
+		call := r.service.Projects.List().Context(pageCtx)
 		if pageToken != "" {
 			call = call.PageToken(pageToken)
 		}
 
 		// Execute the request
 		resp, err := call.Do()
+		if err == nil {
+			span.SetAttributes(tracing.AttrCount.Int(len(resp.Projects)))
+		}
+		tracing.End(span, err)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list projects on page %d: %w", pageCount, err)
 		}
 
-		r.logger.Debug("Page %d: received %d projects", pageCount, len(resp.Projects))
-
 		// Process projects from this page
 		currentPageProjects := 0
 		for _, p := range resp.Projects {
 			createTime, err := time.Parse(time.RFC3339, p.CreateTime)
 			if err != nil {
-				r.logger.Debug("Failed to parse create time for project %s: %v", p.ProjectId, err)
+				r.logger.Debug("Failed to parse project create time", "project", p.ProjectId, "error", err)
 				createTime = time.Time{}
 			}
 
@@ -64,27 +119,145 @@ func (r *ProjectRepository) ListProjects(ctx context.Context) ([]domain.Project,
 				ProjectNum: p.ProjectNumber,
 				Labels:     p.Labels,
 				CreateTime: createTime,
+				Parent:     parentName(p.Parent),
 			})
 			currentPageProjects++
 		}
 
-		r.logger.Debug("Page %d: processed %d projects (total so far: %d)",
-			pageCount, currentPageProjects, len(projects))
+		r.logger.Debug("Fetched projects page",
+			"page", pageCount, "projects", currentPageProjects, "total", len(projects))
 
 		// Check if there are more pages
 		pageToken = resp.NextPageToken
 		if pageToken == "" {
-			r.logger.Debug("No more pages available after page %d", pageCount)
 			break
 		}
 	}
 
-	r.logger.Debug("Completed project listing: %d pages, %d total projects",
-		pageCount, len(projects))
+	r.logger.Debug("Completed project listing", "pages", pageCount, "projects", len(projects))
 
+	if r.organization != "" {
+		var err error
+		if projects, err = r.organizationProjects(ctx, projects); err != nil {
+			return nil, err
+		}
+	}
+	if r.folderNames != nil {
+		if err := r.setFolderNames(ctx, projects); err != nil {
+			return nil, err
+		}
+	}
 	return projects, nil
 }
 
+// organizationProjects keeps the projects that belong to the configured organization
+func (r *ProjectRepository) organizationProjects(ctx context.Context, projects []domain.Project) ([]domain.Project, error) {
+	want := "organizations/" + r.organization
+	var result []domain.Project
+	for _, project := range projects {
+		root, err := r.organizationOf(ctx, project.ID, project.Parent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve organization of project %s: %w", project.ID, err)
+		}
+		if root == want {
+			result = append(result, project)
+		}
+	}
+
+	r.logger.Debug("Filtered projects by organization",
+		"organization", r.organization, "projects", len(result), "visible", len(projects))
+	return result, nil
+}
+
+// organizationOf returns the organization a parent resource belongs to, or "" for projects
+// without an organization. A folder the caller may not read, as for a project shared from
+// another organization, is taken to be outside of every organization.
+func (r *ProjectRepository) organizationOf(ctx context.Context, projectID, parent string) (string, error) {
+	var visited []string
+	for strings.HasPrefix(parent, "folders/") {
+		if root, ok := r.folderRoots[parent]; ok {
+			parent = root
+			break
+		}
+		visited = append(visited, parent)
+
+		folder, err := r.folders.Folders.Get(parent).Context(ctx).Do()
+		if isInaccessible(err) {
+			r.logger.Warn("Cannot read folder, skipping its projects as outside the organization",
+				"folder", parent, "project", projectID, "error", err)
+			parent = ""
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get %s: %w", parent, err)
+		}
+		if r.folderNames != nil {
+			r.folderNames[parent] = folder.DisplayName
+		}
+		parent = folder.Parent
+	}
+
+	for _, folder := range visited {
+		r.folderRoots[folder] = parent
+	}
+	if strings.HasPrefix(parent, "organizations/") {
+		return parent, nil
+	}
+	return "", nil
+}
+
+// setFolderNames sets the display name of the folder of each project in a folder. The projects
+// of a folder the caller may not read keep no name and are grouped under the folder ID.
+func (r *ProjectRepository) setFolderNames(ctx context.Context, projects []domain.Project) error {
+	for i := range projects {
+		parent := projects[i].Parent
+		if !strings.HasPrefix(parent, "folders/") {
+			continue
+		}
+
+		name, ok := r.folderNames[parent]
+		if !ok {
+			folder, err := r.folders.Folders.Get(parent).Context(ctx).Do()
+			switch {
+			case isInaccessible(err):
+				r.logger.Warn("Cannot read folder, grouping its projects by folder ID",
+					"folder", parent, "project", projects[i].ID, "error", err)
+			case err != nil:
+				return fmt.Errorf("failed to get folder of project %s: %w", projects[i].ID, err)
+			default:
+				name = folder.DisplayName
+			}
+			r.folderNames[parent] = name
+		}
+		projects[i].FolderName = name
+	}
+	return nil
+}
+
+// isInaccessible reports whether a Resource Manager request was denied or the resource was not
+// found, as opposed to failing or running out of quota
+func isInaccessible(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || isQuotaError(err) {
+		return false
+	}
+	return apiErr.Code == http.StatusForbidden || apiErr.Code == http.StatusNotFound
+}
+
 func (r *ProjectRepository) IsValidProject(project domain.Project) bool {
-	return !systemPattern.MatchString(project.ID)
+	return !IsSystemProject(project.ID)
+}
+
+// IsSystemProject reports whether a project is created by Google for internal use and
+// excluded from audits
+func IsSystemProject(projectID string) bool {
+	return systemPattern.MatchString(projectID)
+}
+
+// parentName converts a v1 resource ID to its resource name, e.g. "folders/123"
+func parentName(parent *resourcemanager.ResourceId) string {
+	if parent == nil || parent.Id == "" {
+		return ""
+	}
+	return parent.Type + "s/" + parent.Id
 }
@@ -4,6 +4,7 @@ package gcp
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 	"sync"
 	"time"
@@ -11,8 +12,12 @@ import (
 	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
 	monitoringpb "cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
 	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/tracing"
 	"github.com/ybonda/gcp-auditor/pkg/logging"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 	"google.golang.org/api/iterator"
 	serviceusage "google.golang.org/api/serviceusage/v1"
 	"google.golang.org/protobuf/types/known/durationpb"
@@ -22,36 +27,168 @@ import (
 type ServiceRepository struct {
 	usageService     *serviceusage.Service
 	monitoringClient *monitoring.MetricClient
-	logger           *logging.Logger
+	logger           *slog.Logger
 	workerCount      int
+	usageTimeout     time.Duration
+	limiter          *rate.Limiter    // Shared by all API calls; nil disables rate limiting
+	adaptive         *adaptiveLimiter // Shared by all API calls; nil disables adaptive concurrency
+	observeCall      func(err error)  // Called after every API request; nil when not observed
 }
 
+type ServiceOption func(*ServiceRepository)
+
+// WithWorkerCount sets the number of usage lookups run in parallel within a project
+func WithWorkerCount(n int) ServiceOption {
+	return func(r *ServiceRepository) {
+		if n > 0 {
+			r.workerCount = n
+		}
+	}
+}
+
+// WithUsageTimeout sets the maximum duration of a single usage lookup
+func WithUsageTimeout(timeout time.Duration) ServiceOption {
+	return func(r *ServiceRepository) {
+		if timeout > 0 {
+			r.usageTimeout = timeout
+		}
+	}
+}
+
+// WithRateLimit limits Service Usage and Monitoring API requests to requestsPerSecond.
+// Zero disables the limit.
+func WithRateLimit(requestsPerSecond float64, burst int) ServiceOption {
+	return func(r *ServiceRepository) {
+		if requestsPerSecond > 0 {
+			r.limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), max(burst, 1))
+		}
+	}
+}
+
+// WithAdaptiveConcurrency adapts the number of API requests in flight across all projects
+// to quota errors, up to maxInFlight. Zero disables adaptive concurrency.
+func WithAdaptiveConcurrency(maxInFlight int) ServiceOption {
+	return func(r *ServiceRepository) {
+		if maxInFlight > 0 {
+			r.adaptive = newAdaptiveLimiter(maxInFlight)
+		}
+	}
+}
+
+// WithCallObserver calls observe after every Service Usage and Monitoring API request,
+// including retries, with the error of the request
+func WithCallObserver(observe func(err error)) ServiceOption {
+	return func(r *ServiceRepository) {
+		r.observeCall = observe
+	}
+}
+
+// WithSharedLimits applies the rate limit and adaptive in-flight limit of another repository,
+// so that repositories using different credentials stay within common limits
+func WithSharedLimits(other *ServiceRepository) ServiceOption {
+	return func(r *ServiceRepository) {
+		r.limiter = other.limiter
+		r.adaptive = other.adaptive
+	}
+}
+
+// NewServiceRepository returns a repository listing the services of projects and their
+// usage. A nil logger discards log records.
 func NewServiceRepository(
 	usageService *serviceusage.Service,
 	monitoringClient *monitoring.MetricClient,
-	verbose bool,
+	logger *slog.Logger,
+	opts ...ServiceOption,
 ) *ServiceRepository {
-	return &ServiceRepository{
+	r := &ServiceRepository{
 		usageService:     usageService,
 		monitoringClient: monitoringClient,
-		logger:           logging.NewLogger(verbose),
+		logger:           logging.OrDiscard(logger),
 		workerCount:      10,
+		usageTimeout:     30 * time.Second,
 	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// wait blocks until the rate limiter allows the next API request
+func (r *ServiceRepository) wait(ctx context.Context) error {
+	if r.limiter == nil {
+		return nil
+	}
+	return r.limiter.Wait(ctx)
+}
+
+// call runs an API request within the rate limit and, in adaptive mode, the in-flight
+// limit. Requests rejected for quota in adaptive mode are retried once the limit is lowered.
+// The number of retries is recorded on the span of ctx.
+func (r *ServiceRepository) call(ctx context.Context, request func() error) error {
+	attempt := 1
+	defer func() {
+		trace.SpanFromContext(ctx).SetAttributes(tracing.AttrRetries.Int(attempt - 1))
+	}()
+
+	for ; ; attempt++ {
+		if err := r.wait(ctx); err != nil {
+			return err
+		}
+		if r.adaptive == nil {
+			return r.observe(request())
+		}
+
+		epoch, err := r.adaptive.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		err = r.observe(request())
+		if r.adaptive.release(epoch, err) {
+			r.logger.Debug("Quota exceeded, lowering in-flight API requests", "limit", r.adaptive.current())
+		}
+
+		if err == nil || !isQuotaError(err) || attempt > maxQuotaRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(time.Duration(attempt) * quotaRetryDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// observe reports the outcome of an API request to the call observer and returns it
+func (r *ServiceRepository) observe(err error) error {
+	if r.observeCall != nil {
+		r.observeCall(err)
+	}
+	return err
 }
 
 type serviceWork struct {
 	service *serviceusage.GoogleApiServiceusageV1Service
-	index   int
 }
 
 func (r *ServiceRepository) ListServices(ctx context.Context, projectID string, period time.Duration) ([]domain.Service, error) {
+	ctx, span := tracer.Start(ctx, "ListServices", trace.WithAttributes(tracing.AttrProject.String(projectID)))
+	services, err := r.listServices(ctx, projectID, period)
+	span.SetAttributes(tracing.AttrCount.Int(len(services)))
+	tracing.End(span, err)
+	return services, err
+}
+
+func (r *ServiceRepository) listServices(ctx context.Context, projectID string, period time.Duration) ([]domain.Service, error) {
 	// First, get all services
 	services, err := r.listAllServices(ctx, projectID)
 	if err != nil {
 		return nil, err
 	}
 
-	r.logger.Debug("Found %d services for project %s", len(services), projectID)
+	r.logger.Debug("Found services", "project", projectID, "services", len(services))
 
 	// Create channels for work distribution and results
 	workChan := make(chan serviceWork, len(services))
@@ -67,7 +204,7 @@ func (r *ServiceRepository) ListServices(ctx context.Context, projectID string,
 				serviceName := cleanServiceName(work.service.Name)
 				// Skip services with empty names
 				if serviceName == "" {
-					r.logger.Debug("Skipping service with empty name in project %s", projectID)
+					r.logger.Debug("Skipping service with empty name", "project", projectID)
 					continue
 				}
 
@@ -82,12 +219,12 @@ func (r *ServiceRepository) ListServices(ctx context.Context, projectID string,
 				}
 
 				// Get usage metrics with timeout
-				usageCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+				usageCtx, cancel := context.WithTimeout(ctx, r.usageTimeout)
 				usage, err := r.GetServiceUsage(usageCtx, projectID, serviceName, period)
 				cancel()
 
 				if err != nil {
-					r.logger.Debug("Failed to get usage for service %s %s: %v", serviceName, projectID, err)
+					r.logger.Debug("Failed to get service usage", "project", projectID, "service", serviceName, "error", err)
 					service.Usage = &domain.Usage{}
 				} else {
 					service.Usage = usage
@@ -105,9 +242,9 @@ func (r *ServiceRepository) ListServices(ctx context.Context, projectID string,
 
 	// Send work to workers
 	go func() {
-		for i, service := range services {
+		for _, service := range services {
 			select {
-			case workChan <- serviceWork{service: service, index: i}:
+			case workChan <- serviceWork{service: service}:
 			case <-ctx.Done():
 				return
 			}
@@ -115,25 +252,18 @@ func (r *ServiceRepository) ListServices(ctx context.Context, projectID string,
 		close(workChan)
 	}()
 
-	// Collect results
-	results := make([]domain.Service, len(services))
-	go func() {
-		for i := 0; i < len(services); i++ {
-			select {
-			case result := <-resultsChan:
-				results[i] = result
-			case <-ctx.Done():
-				return
-			}
-		}
-		close(resultsChan)
-	}()
-
 	// Wait for all workers to complete
 	if err := g.Wait(); err != nil {
 		return nil, fmt.Errorf("error processing services: %w", err)
 	}
 
+	// Collect results; the channel holds every result, so workers never block on it
+	close(resultsChan)
+	results := make([]domain.Service, 0, len(services))
+	for result := range resultsChan {
+		results = append(results, result)
+	}
+
 	return results, nil
 }
 
@@ -142,14 +272,26 @@ func (r *ServiceRepository) listAllServices(ctx context.Context, projectID strin
 	var mu sync.Mutex
 	pageToken := ""
 
-	for {
+	for page := 1; ; page++ {
+		pageCtx, span := tracer.Start(ctx, "serviceusage.services.list", trace.WithAttributes(
+			tracing.AttrProject.String(projectID),
+			tracing.AttrPage.Int(page),
+		))
 		parent := fmt.Sprintf("projects/%s", projectID)
-		call := r.usageService.Services.List(parent).Filter("state:ENABLED").Context(ctx)
+		call := r.usageService.Services.List(parent).Filter("state:ENABLED").Context(pageCtx)
 		if pageToken != "" {
 			call = call.PageToken(pageToken)
 		}
 
-		resp, err := call.Do()
+		var resp *serviceusage.ListServicesResponse
+		err := r.call(pageCtx, func() (err error) {
+			resp, err = call.Do()
+			return err
+		})
+		if err == nil {
+			span.SetAttributes(tracing.AttrCount.Int(len(resp.Services)))
+		}
+		tracing.End(span, err)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list services for project %s: %w", projectID, err)
 		}
@@ -173,6 +315,12 @@ func (r *ServiceRepository) GetServiceUsage(
 	serviceName string,
 	period time.Duration,
 ) (*domain.Usage, error) {
+	ctx, span := tracer.Start(ctx, "GetServiceUsage", trace.WithAttributes(
+		tracing.AttrProject.String(projectID),
+		tracing.AttrService.String(serviceName),
+	))
+	defer span.End()
+
 	endTime := time.Now()
 	startTime := endTime.Add(-period)
 
@@ -200,32 +348,45 @@ func (r *ServiceRepository) GetServiceUsage(
 		},
 	}
 
-	it := r.monitoringClient.ListTimeSeries(ctx, req)
-	for {
-		resp, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			if strings.Contains(err.Error(), "PermissionDenied") {
-				usage.Status = domain.UsageStatusNoAccess
-				usage.Error = "No access to monitoring data"
-				return usage, nil
+	err := r.call(ctx, func() error {
+		// A retried request starts counting from zero again
+		usage.RequestCount = 0
+
+		it := r.monitoringClient.ListTimeSeries(ctx, req)
+		for {
+			resp, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
 			}
-			usage.Status = domain.UsageStatusError
-			usage.Error = err.Error()
-			return usage, nil
-		}
 
-		for _, point := range resp.Points {
-			if val := point.Value.GetInt64Value(); val != 0 {
-				usage.RequestCount += val
-			} else if val := point.Value.GetDoubleValue(); val != 0 {
-				usage.RequestCount += int64(val)
+			for _, point := range resp.Points {
+				if val := point.Value.GetInt64Value(); val != 0 {
+					usage.RequestCount += val
+				} else if val := point.Value.GetDoubleValue(); val != 0 {
+					usage.RequestCount += int64(val)
+				}
 			}
 		}
+	})
+	switch {
+	case err == nil:
+	case strings.Contains(err.Error(), "PermissionDenied"):
+		usage.Status = domain.UsageStatusNoAccess
+		usage.Error = "No access to monitoring data"
+	default:
+		usage.Status = domain.UsageStatusError
+		usage.Error = err.Error()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, usage.Error)
 	}
 
+	span.SetAttributes(
+		tracing.AttrStatus.String(string(usage.Status)),
+		tracing.AttrRequests.Int64(usage.RequestCount),
+	)
 	return usage, nil
 }
 
@@ -0,0 +1,289 @@
+// internal/repository/stub/generate.go
+package stub
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SyntheticOptions describes a synthetic organization for load tests
+type SyntheticOptions struct {
+	Seed           int64
+	Organization   string        // Numeric ID of the organization holding the folders
+	Projects       int           // Number of projects
+	Folders        int           // Folders the projects are spread over; zero puts them in the organization
+	Services       int           // Mean number of enabled services per project
+	ServicesSpread float64       // Services per project vary uniformly by up to this share of Services
+	ServicePool    int           // Distinct services across the organization
+	ServiceSkew    float64       // Zipf exponent of the service popularity; zero makes all services equally common
+	UnusedRate     float64       // Share of services without requests
+	RequestsMedian float64       // Median request count of used services
+	RequestsSigma  float64       // Log-normal spread of the request counts
+	SkipRate       float64       // Share of projects whose services cannot be listed
+	NoAccessRate   float64       // Share of services without access to their metrics
+	ErrorRate      float64       // Share of services whose metrics cannot be read
+	PageSize       int           // Items per page of every list call
+	Latency        time.Duration // Delay of every services.list and ListTimeSeries call
+}
+
+// DefaultSyntheticOptions returns a mid-sized organization with realistic usage and error rates
+func DefaultSyntheticOptions() SyntheticOptions {
+	return SyntheticOptions{
+		Seed:           1,
+		Organization:   "123456789012",
+		Projects:       1000,
+		Folders:        20,
+		Services:       40,
+		ServicesSpread: 0.5,
+		ServicePool:    300,
+		ServiceSkew:    1.1,
+		UnusedRate:     0.4,
+		RequestsMedian: 1000,
+		RequestsSigma:  2,
+		SkipRate:       0.01,
+		NoAccessRate:   0.02,
+		ErrorRate:      0.005,
+		PageSize:       50,
+	}
+}
+
+func (o SyntheticOptions) Validate() error {
+	if o.Projects < 0 || o.Folders < 0 || o.Services < 0 || o.PageSize < 0 || o.Latency < 0 {
+		return fmt.Errorf("projects, folders, services, page size and latency must not be negative")
+	}
+	if _, err := strconv.ParseUint(o.Organization, 10, 64); err != nil {
+		return fmt.Errorf("organization %q must be a numeric ID", o.Organization)
+	}
+	if o.ServicesSpread < 0 || o.ServicesSpread > 1 {
+		return fmt.Errorf("services spread must be between 0 and 1")
+	}
+	if maxServices := int(math.Ceil(float64(o.Services) * (1 + o.ServicesSpread))); o.ServicePool < maxServices {
+		return fmt.Errorf("service pool of %d is smaller than the up to %d services per project", o.ServicePool, maxServices)
+	}
+	if o.ServiceSkew < 0 || o.RequestsMedian < 1 || o.RequestsSigma < 0 {
+		return fmt.Errorf("service skew and requests sigma must not be negative, and the requests median must be at least 1")
+	}
+	for name, rate := range map[string]float64{
+		"unused":    o.UnusedRate,
+		"skip":      o.SkipRate,
+		"no access": o.NoAccessRate,
+		"error":     o.ErrorRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s rate must be between 0 and 1", name)
+		}
+	}
+	if o.NoAccessRate+o.ErrorRate > 1 {
+		return fmt.Errorf("no access and error rates must not add up to more than 1")
+	}
+	return nil
+}
+
+// wellKnownServices name the most popular services of synthetic organizations
+var wellKnownServices = []struct{ name, title string }{
+	{"storage.googleapis.com", "Cloud Storage API"},
+	{"logging.googleapis.com", "Cloud Logging API"},
+	{"monitoring.googleapis.com", "Cloud Monitoring API"},
+	{"iam.googleapis.com", "Identity and Access Management (IAM) API"},
+	{"compute.googleapis.com", "Compute Engine API"},
+	{"cloudresourcemanager.googleapis.com", "Cloud Resource Manager API"},
+	{"serviceusage.googleapis.com", "Service Usage API"},
+	{"bigquery.googleapis.com", "BigQuery API"},
+	{"pubsub.googleapis.com", "Cloud Pub/Sub API"},
+	{"container.googleapis.com", "Kubernetes Engine API"},
+	{"run.googleapis.com", "Cloud Run Admin API"},
+	{"cloudfunctions.googleapis.com", "Cloud Functions API"},
+	{"sqladmin.googleapis.com", "Cloud SQL Admin API"},
+	{"secretmanager.googleapis.com", "Secret Manager API"},
+	{"cloudkms.googleapis.com", "Cloud Key Management Service (KMS) API"},
+	{"artifactregistry.googleapis.com", "Artifact Registry API"},
+	{"cloudbuild.googleapis.com", "Cloud Build API"},
+	{"dns.googleapis.com", "Cloud DNS API"},
+	{"firestore.googleapis.com", "Cloud Firestore API"},
+	{"dataflow.googleapis.com", "Dataflow API"},
+}
+
+var environments = []string{"prod", "staging", "dev"}
+
+// Generate builds a synthetic organization. The same options always produce the same scenario.
+func Generate(opts SyntheticOptions) (*Scenario, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	organization := "organizations/" + opts.Organization
+
+	scenario := &Scenario{
+		PageSize: opts.PageSize,
+		Folders:  make([]Folder, 0, opts.Folders),
+		Projects: make([]Project, 0, opts.Projects),
+	}
+	for i := 0; i < opts.Folders; i++ {
+		scenario.Folders = append(scenario.Folders, Folder{
+			Name:        fmt.Sprintf("folders/%d", 1000+i),
+			DisplayName: fmt.Sprintf("Folder %d", i+1),
+			Parent:      organization,
+		})
+	}
+
+	pool := servicePool(opts.ServicePool)
+	weights := make([]float64, len(pool))
+	for i := range weights {
+		weights[i] = 1 / math.Pow(float64(i+1), opts.ServiceSkew)
+	}
+
+	created := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < opts.Projects; i++ {
+		project := Project{
+			ID:         fmt.Sprintf("synthetic-%06d", i),
+			Name:       fmt.Sprintf("Synthetic %d", i),
+			Number:     100000000000 + int64(i),
+			CreateTime: created.Add(time.Duration(rng.Int63n(int64(7 * 365 * 24 * time.Hour)))).Truncate(time.Second),
+			Parent:     organization,
+			Latency:    opts.Latency,
+		}
+		if opts.Folders > 0 {
+			project.Parent = scenario.Folders[rng.Intn(opts.Folders)].Name
+		}
+		// A tenth of the projects are unlabeled
+		if rng.Float64() >= 0.1 {
+			project.Labels = map[string]string{
+				"env":  environments[rng.Intn(len(environments))],
+				"team": fmt.Sprintf("team-%02d", rng.Intn(20)),
+			}
+		}
+
+		if rng.Float64() < opts.SkipRate {
+			project.Error = &Error{
+				Code:    "PERMISSION_DENIED",
+				Message: fmt.Sprintf("Permission denied to list services for consumer container [projects/%d]", project.Number),
+			}
+		}
+
+		count := opts.Services
+		if spread := int(float64(opts.Services) * opts.ServicesSpread); spread > 0 {
+			count += rng.Intn(2*spread+1) - spread
+		}
+		for _, index := range weightedSample(rng, weights, count) {
+			project.Services = append(project.Services, syntheticService(rng, pool[index], opts))
+		}
+		sort.Slice(project.Services, func(i, j int) bool {
+			return project.Services[i].Name < project.Services[j].Name
+		})
+
+		scenario.Projects = append(scenario.Projects, project)
+	}
+
+	return scenario, nil
+}
+
+func syntheticService(rng *rand.Rand, service Service, opts SyntheticOptions) Service {
+	service.Latency = opts.Latency
+
+	switch r := rng.Float64(); {
+	case r < opts.NoAccessRate:
+		service.Error = &Error{Code: "PERMISSION_DENIED", Message: "Permission monitoring.timeSeries.list denied"}
+	case r < opts.NoAccessRate+opts.ErrorRate:
+		service.Error = &Error{Code: "INTERNAL", Message: "Internal error encountered"}
+	}
+
+	if rng.Float64() >= opts.UnusedRate {
+		requests := math.Exp(math.Log(opts.RequestsMedian) + rng.NormFloat64()*opts.RequestsSigma)
+		service.Requests = []int64{max(1, int64(requests))}
+	}
+	return service
+}
+
+// servicePool returns n distinct services, the well-known ones first
+func servicePool(n int) []Service {
+	pool := make([]Service, 0, n)
+	for i := 0; i < n; i++ {
+		if i < len(wellKnownServices) {
+			pool = append(pool, Service{Name: wellKnownServices[i].name, Title: wellKnownServices[i].title})
+			continue
+		}
+		pool = append(pool, Service{
+			Name:  fmt.Sprintf("synthetic%d.googleapis.com", i),
+			Title: fmt.Sprintf("Synthetic API %d", i),
+		})
+	}
+	return pool
+}
+
+// weightedSample picks k distinct indexes with probabilities proportional to the weights
+// (Efraimidis-Spirakis sampling without replacement)
+func weightedSample(rng *rand.Rand, weights []float64, k int) []int {
+	type keyed struct {
+		index int
+		key   float64
+	}
+	keys := make([]keyed, len(weights))
+	for i, weight := range weights {
+		keys[i] = keyed{index: i, key: rng.ExpFloat64() / weight}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].key < keys[j].key
+	})
+
+	indexes := make([]int, 0, k)
+	for _, key := range keys[:min(k, len(keys))] {
+		indexes = append(indexes, key.index)
+	}
+	return indexes
+}
+
+// WriteScenario writes a scenario as YAML. Projects are encoded one at a time, so that large
+// scenarios do not need a second copy in memory.
+func WriteScenario(w io.Writer, scenario *Scenario) error {
+	buffered := bufio.NewWriter(w)
+
+	// A scenario without settings or folders encodes as {}, which cannot precede the projects
+	header := *scenario
+	header.Projects = nil
+	var data bytes.Buffer
+	if err := encodeYAML(&data, header); err != nil {
+		return err
+	}
+	if data.String() != "{}\n" {
+		if _, err := buffered.Write(data.Bytes()); err != nil {
+			return fmt.Errorf("failed to write scenario: %w", err)
+		}
+	}
+
+	if len(scenario.Projects) > 0 {
+		if _, err := buffered.WriteString("projects:\n"); err != nil {
+			return fmt.Errorf("failed to write scenario: %w", err)
+		}
+	}
+	for i := range scenario.Projects {
+		if err := encodeYAML(buffered, scenario.Projects[i:i+1]); err != nil {
+			return err
+		}
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write scenario: %w", err)
+	}
+	return nil
+}
+
+func encodeYAML(w io.Writer, value any) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("failed to write scenario: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to write scenario: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// internal/repository/stub/monitoring.go
+package stub
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	monitoringpb "cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const requestCountMetric = "serviceruntime.googleapis.com/api/request_count"
+
+var serviceFilter = regexp.MustCompile(`resource\.labels\.service\s*=\s*"([^"]+)"`)
+
+// metricService serves the request count time series of the scenario's services
+type metricService struct {
+	monitoringpb.UnimplementedMetricServiceServer
+	server *Server
+}
+
+// ListTimeSeries returns one time series per entry of a service's requests. Services that are
+// not enabled in the project have no time series.
+func (m *metricService) ListTimeSeries(ctx context.Context, req *monitoringpb.ListTimeSeriesRequest) (*monitoringpb.ListTimeSeriesResponse, error) {
+	s := m.server
+	s.count(MethodListTimeSeries)
+
+	projectID := strings.TrimPrefix(req.Name, "projects/")
+	if _, ok := s.projects[projectID]; !ok {
+		return nil, status.Errorf(codes.NotFound, "Project %s not found", projectID)
+	}
+	if !strings.Contains(req.Filter, requestCountMetric) {
+		return nil, status.Errorf(codes.InvalidArgument, "stub: only %s is supported", requestCountMetric)
+	}
+	match := serviceFilter.FindStringSubmatch(req.Filter)
+	if match == nil {
+		return nil, status.Error(codes.InvalidArgument, "stub: filter must select resource.labels.service")
+	}
+
+	service, ok := s.services[projectID][match[1]]
+	if !ok {
+		return &monitoringpb.ListTimeSeriesResponse{}, nil
+	}
+	if err := sleep(ctx, service.Latency); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	if service.Error != nil {
+		return nil, status.Error(errorCodes[service.Error.Code].grpc, service.Error.Message)
+	}
+
+	start, end, next, pageErr := s.page(req.PageToken, len(service.Requests))
+	if pageErr != nil {
+		return nil, status.Error(codes.InvalidArgument, pageErr.Message)
+	}
+
+	resp := &monitoringpb.ListTimeSeriesResponse{NextPageToken: next}
+	for _, requests := range service.Requests[start:end] {
+		resp.TimeSeries = append(resp.TimeSeries, &monitoringpb.TimeSeries{
+			Metric: &metricpb.Metric{Type: requestCountMetric},
+			Points: []*monitoringpb.Point{{
+				Interval: req.Interval,
+				Value:    &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: requests}},
+			}},
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,148 @@
+// internal/repository/stub/scenario.go
+package stub
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is the organization served by the stub server
+type Scenario struct {
+	PageSize int           `yaml:"page_size,omitempty"` // Items per page of every list call; zero returns a single page
+	Latency  time.Duration `yaml:"latency,omitempty"`   // Delay of every projects.list page
+	Error    *Error        `yaml:"error,omitempty"`     // Returned by projects.list
+	Folders  []Folder      `yaml:"folders,omitempty"`
+	Projects []Project     `yaml:"projects,omitempty"`
+}
+
+// Folder is a Resource Manager folder, used to resolve the organization and folder name of projects
+type Folder struct {
+	Name        string `yaml:"name"`                   // e.g. "folders/123"
+	DisplayName string `yaml:"display_name,omitempty"` // e.g. "Engineering"
+	Parent      string `yaml:"parent,omitempty"`       // e.g. "organizations/456" or "folders/789"
+}
+
+type Project struct {
+	ID         string            `yaml:"id"`
+	Name       string            `yaml:"name,omitempty"`
+	Number     int64             `yaml:"number,omitempty"`
+	Labels     map[string]string `yaml:"labels,omitempty"`
+	CreateTime time.Time         `yaml:"create_time,omitempty"`
+	Parent     string            `yaml:"parent,omitempty"`  // e.g. "folders/123" or "organizations/456"
+	Latency    time.Duration     `yaml:"latency,omitempty"` // Delay of every services.list page
+	Error      *Error            `yaml:"error,omitempty"`   // Returned by services.list
+	Services   []Service         `yaml:"services,omitempty"`
+}
+
+// Service is an enabled service and its request count time series
+type Service struct {
+	Name     string        `yaml:"name"` // e.g. "bigquery.googleapis.com"
+	Title    string        `yaml:"title,omitempty"`
+	Requests []int64       `yaml:"requests,flow,omitempty"` // Requests per time series returned by ListTimeSeries
+	Latency  time.Duration `yaml:"latency,omitempty"`       // Delay of every ListTimeSeries page
+	Error    *Error        `yaml:"error,omitempty"`         // Returned by ListTimeSeries
+}
+
+// Error is an API error. Code is a canonical gRPC code name such as PERMISSION_DENIED; HTTP
+// APIs respond with the matching HTTP status.
+type Error struct {
+	Code    string `yaml:"code"`
+	Message string `yaml:"message"`
+}
+
+// errorCodes maps canonical code names to gRPC codes and HTTP statuses
+var errorCodes = map[string]struct {
+	grpc codes.Code
+	http int
+}{
+	"INVALID_ARGUMENT":   {codes.InvalidArgument, 400},
+	"UNAUTHENTICATED":    {codes.Unauthenticated, 401},
+	"PERMISSION_DENIED":  {codes.PermissionDenied, 403},
+	"NOT_FOUND":          {codes.NotFound, 404},
+	"RESOURCE_EXHAUSTED": {codes.ResourceExhausted, 429},
+	"INTERNAL":           {codes.Internal, 500},
+	"UNAVAILABLE":        {codes.Unavailable, 503},
+	"DEADLINE_EXCEEDED":  {codes.DeadlineExceeded, 504},
+}
+
+// LoadScenario reads a scenario from a YAML file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario decodes and validates a YAML scenario. Unknown fields are rejected.
+func ParseScenario(data []byte) (*Scenario, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var scenario Scenario
+	if err := decoder.Decode(&scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	return &scenario, nil
+}
+
+func (s *Scenario) Validate() error {
+	if s.PageSize < 0 {
+		return fmt.Errorf("page_size must not be negative")
+	}
+	if err := s.Error.validate(); err != nil {
+		return err
+	}
+
+	for _, folder := range s.Folders {
+		if !strings.HasPrefix(folder.Name, "folders/") {
+			return fmt.Errorf("folder %q: name must start with folders/", folder.Name)
+		}
+	}
+
+	projectIDs := make(map[string]bool)
+	for _, project := range s.Projects {
+		if project.ID == "" {
+			return fmt.Errorf("project without id")
+		}
+		if projectIDs[project.ID] {
+			return fmt.Errorf("project %s is defined more than once", project.ID)
+		}
+		projectIDs[project.ID] = true
+
+		if project.Parent != "" && !strings.HasPrefix(project.Parent, "folders/") && !strings.HasPrefix(project.Parent, "organizations/") {
+			return fmt.Errorf("project %s: parent must start with folders/ or organizations/", project.ID)
+		}
+		if err := project.Error.validate(); err != nil {
+			return fmt.Errorf("project %s: %w", project.ID, err)
+		}
+		for _, service := range project.Services {
+			if !strings.HasSuffix(service.Name, ".googleapis.com") {
+				return fmt.Errorf("project %s: service %q must end with .googleapis.com", project.ID, service.Name)
+			}
+			if err := service.Error.validate(); err != nil {
+				return fmt.Errorf("project %s: service %s: %w", project.ID, service.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Error) validate() error {
+	if e == nil {
+		return nil
+	}
+	if _, ok := errorCodes[e.Code]; !ok {
+		return fmt.Errorf("unknown error code %q", e.Code)
+	}
+	return nil
+}
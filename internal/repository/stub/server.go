@@ -0,0 +1,311 @@
+// internal/repository/stub/server.go
+package stub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	monitoringpb "cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"google.golang.org/grpc"
+)
+
+// Server is a local stand-in for the GCP APIs used by the auditor. It serves the Resource
+// Manager v1 and v3 and Service Usage v1 REST APIs over HTTP, and the Monitoring v3
+// ListTimeSeries gRPC API, from a scenario.
+type Server struct {
+	URL      string // Base URL of the REST APIs
+	GRPCAddr string // Address of the Monitoring gRPC API
+
+	scenario *Scenario
+	projects map[string]*Project            // Project ID to project
+	services map[string]map[string]*Service // Project ID to service name to service
+	folders  map[string]*Folder
+
+	http     *httptest.Server
+	grpc     *grpc.Server
+	listener net.Listener
+
+	mu       sync.Mutex
+	requests map[string]int // Requests per API method
+}
+
+// API methods counted by Requests
+const (
+	MethodListProjects   = "projects.list"
+	MethodListServices   = "services.list"
+	MethodGetFolder      = "folders.get"
+	MethodListTimeSeries = "ListTimeSeries"
+)
+
+// NewServer starts a server on local ports. Close stops it.
+func NewServer(scenario *Scenario) (*Server, error) {
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		scenario: scenario,
+		projects: make(map[string]*Project),
+		services: make(map[string]map[string]*Service),
+		folders:  make(map[string]*Folder),
+		requests: make(map[string]int),
+	}
+	for i := range scenario.Projects {
+		project := &scenario.Projects[i]
+		s.projects[project.ID] = project
+		s.services[project.ID] = make(map[string]*Service)
+		for j := range project.Services {
+			s.services[project.ID][project.Services[j].Name] = &project.Services[j]
+		}
+	}
+	for i := range scenario.Folders {
+		s.folders[scenario.Folders[i].Name] = &scenario.Folders[i]
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for gRPC: %w", err)
+	}
+	s.listener = listener
+	s.GRPCAddr = listener.Addr().String()
+	s.grpc = grpc.NewServer()
+	monitoringpb.RegisterMetricServiceServer(s.grpc, &metricService{server: s})
+	go s.grpc.Serve(listener)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/projects", s.handleListProjects)
+	mux.HandleFunc("GET /v1/projects/{project}/services", s.handleListServices)
+	mux.HandleFunc("GET /v3/folders/{folder}", s.handleGetFolder)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, &Error{Code: "NOT_FOUND", Message: fmt.Sprintf("stub: %s %s is not implemented", r.Method, r.URL.Path)})
+	})
+	s.http = httptest.NewServer(mux)
+	s.URL = s.http.URL
+
+	return s, nil
+}
+
+// Requests returns the number of requests received for an API method
+func (s *Server) Requests(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[method]
+}
+
+// TotalRequests returns the number of requests received for all API methods
+func (s *Server) TotalRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for _, n := range s.requests {
+		total += n
+	}
+	return total
+}
+
+func (s *Server) Close() {
+	s.http.Close()
+	s.grpc.Stop()
+}
+
+func (s *Server) count(method string) {
+	s.mu.Lock()
+	s.requests[method]++
+	s.mu.Unlock()
+}
+
+type v1Project struct {
+	ProjectID      string            `json:"projectId"`
+	Name           string            `json:"name,omitempty"`
+	ProjectNumber  string            `json:"projectNumber,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	CreateTime     string            `json:"createTime,omitempty"`
+	Parent         *v1ResourceID     `json:"parent,omitempty"`
+	LifecycleState string            `json:"lifecycleState"`
+}
+
+type v1ResourceID struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+func (s *Server) handleListProjects(w http.ResponseWriter, r *http.Request) {
+	s.count(MethodListProjects)
+	if err := sleep(r.Context(), s.scenario.Latency); err != nil {
+		return
+	}
+	if s.scenario.Error != nil {
+		writeError(w, s.scenario.Error)
+		return
+	}
+
+	start, end, next, err := s.page(r.URL.Query().Get("pageToken"), len(s.scenario.Projects))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	projects := make([]v1Project, 0, end-start)
+	for _, project := range s.scenario.Projects[start:end] {
+		projects = append(projects, newV1Project(project))
+	}
+	writeJSON(w, map[string]any{"projects": projects, "nextPageToken": next})
+}
+
+func newV1Project(project Project) v1Project {
+	p := v1Project{
+		ProjectID:      project.ID,
+		Name:           project.Name,
+		Labels:         project.Labels,
+		LifecycleState: "ACTIVE",
+	}
+	if project.Number != 0 {
+		p.ProjectNumber = strconv.FormatInt(project.Number, 10)
+	}
+	if !project.CreateTime.IsZero() {
+		p.CreateTime = project.CreateTime.UTC().Format(time.RFC3339)
+	}
+	if kind, id, ok := strings.Cut(project.Parent, "s/"); ok {
+		p.Parent = &v1ResourceID{Type: kind, ID: id}
+	}
+	return p
+}
+
+type v1Service struct {
+	Name   string          `json:"name"`
+	Parent string          `json:"parent"`
+	State  string          `json:"state"`
+	Config v1ServiceConfig `json:"config"`
+}
+
+type v1ServiceConfig struct {
+	Name  string `json:"name"`
+	Title string `json:"title,omitempty"`
+}
+
+func (s *Server) handleListServices(w http.ResponseWriter, r *http.Request) {
+	s.count(MethodListServices)
+
+	projectID := r.PathValue("project")
+	project, ok := s.projects[projectID]
+	if !ok {
+		writeError(w, &Error{Code: "PERMISSION_DENIED", Message: fmt.Sprintf("Permission denied on resource project %s.", projectID)})
+		return
+	}
+	if err := sleep(r.Context(), project.Latency); err != nil {
+		return
+	}
+	if project.Error != nil {
+		writeError(w, project.Error)
+		return
+	}
+
+	start, end, next, err := s.page(r.URL.Query().Get("pageToken"), len(project.Services))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	parent := "projects/" + projectID
+	if project.Number != 0 {
+		parent = "projects/" + strconv.FormatInt(project.Number, 10)
+	}
+	services := make([]v1Service, 0, end-start)
+	for _, service := range project.Services[start:end] {
+		services = append(services, v1Service{
+			Name:   parent + "/services/" + service.Name,
+			Parent: parent,
+			State:  "ENABLED",
+			Config: v1ServiceConfig{Name: service.Name, Title: service.Title},
+		})
+	}
+	writeJSON(w, map[string]any{"services": services, "nextPageToken": next})
+}
+
+func (s *Server) handleGetFolder(w http.ResponseWriter, r *http.Request) {
+	s.count(MethodGetFolder)
+
+	name := "folders/" + r.PathValue("folder")
+	folder, ok := s.folders[name]
+	if !ok {
+		writeError(w, &Error{Code: "PERMISSION_DENIED", Message: fmt.Sprintf("Permission 'resourcemanager.folders.get' denied on resource '%s'.", name)})
+		return
+	}
+	writeJSON(w, map[string]string{"name": folder.Name, "displayName": folder.DisplayName, "parent": folder.Parent, "state": "ACTIVE"})
+}
+
+// page returns the bounds of the page starting at pageToken and the token of the next page.
+// Page tokens are offsets into the list.
+func (s *Server) page(pageToken string, total int) (int, int, string, *Error) {
+	start := 0
+	if pageToken != "" {
+		offset, err := strconv.Atoi(pageToken)
+		if err != nil || offset < 0 || offset > total {
+			return 0, 0, "", &Error{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("invalid page token %q", pageToken)}
+		}
+		start = offset
+	}
+
+	end := total
+	if s.scenario.PageSize > 0 {
+		end = min(start+s.scenario.PageSize, total)
+	}
+
+	next := ""
+	if end < total {
+		next = strconv.Itoa(end)
+	}
+	return start, end, next, nil
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError responds with an error in the format of Google REST APIs
+func writeError(w http.ResponseWriter, e *Error) {
+	code := errorCodes[e.Code].http
+	reason := ""
+	if e.Code == "RESOURCE_EXHAUSTED" {
+		reason = "rateLimitExceeded"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": e.Message,
+			"status":  e.Code,
+			"errors": []map[string]string{
+				{"message": e.Message, "domain": "global", "reason": reason},
+			},
+		},
+	})
+}
+
+// sleep waits for d or until the request is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,514 @@
+// internal/server/api.go
+package server
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// Usage statuses exposed by the API. UNUSED is a successful lookup with zero requests.
+const (
+	usageActive   = "ACTIVE"
+	usageUnused   = "UNUSED"
+	usageNoAccess = "NO_ACCESS"
+	usageError    = "ERROR"
+	usageUnknown  = "UNKNOWN"
+)
+
+type listResponse struct {
+	Items         interface{} `json:"items"`
+	TotalSize     int         `json:"totalSize"`
+	NextPageToken string      `json:"nextPageToken,omitempty"`
+}
+
+type projectSummary struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	Skipped          bool              `json:"skipped"`
+	TotalServices    int               `json:"totalServices"`
+	ActiveServices   int               `json:"activeServices"`
+	UnusedServices   int               `json:"unusedServices"`
+	NoAccessServices int               `json:"noAccessServices"`
+	ErrorServices    int               `json:"errorServices"`
+	TotalRequests    int64             `json:"totalRequests"`
+}
+
+type serviceAggregate struct {
+	Name             string `json:"name"`
+	Title            string `json:"title,omitempty"`
+	ProjectCount     int    `json:"projectCount"`
+	ActiveProjects   int    `json:"activeProjects"`
+	UnusedProjects   int    `json:"unusedProjects"`
+	NoAccessProjects int    `json:"noAccessProjects"`
+	ErrorProjects    int    `json:"errorProjects"`
+	TotalRequests    int64  `json:"totalRequests"`
+}
+
+type serviceProject struct {
+	ProjectID    string            `json:"projectId"`
+	ProjectName  string            `json:"projectName,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	State        string            `json:"state"`
+	UsageStatus  string            `json:"usageStatus"`
+	RequestCount int64             `json:"requestCount"`
+	Error        string            `json:"error,omitempty"`
+}
+
+type projectServiceResponse struct {
+	serviceResponse
+	Status string `json:"status"`
+}
+
+// queryFilter holds the filters shared by the list endpoints
+type queryFilter struct {
+	labels      map[string]*string // nil value means the label only has to be present
+	statuses    map[string]bool
+	minRequests *int64
+	maxRequests *int64
+}
+
+func (s *Server) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/openapi.yaml", s.handleOpenAPI)
+	mux.HandleFunc("GET /api/v1/projects", s.handleListProjects)
+	mux.HandleFunc("GET /api/v1/projects/{project}", s.handleGetProject)
+	mux.HandleFunc("GET /api/v1/projects/{project}/services", s.handleListProjectServices)
+	mux.HandleFunc("GET /api/v1/services", s.handleListServices)
+	mux.HandleFunc("GET /api/v1/services/{service}/projects", s.handleListServiceProjects)
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+// handleListProjects lists projects matching the label filters. Usage status and request
+// filters select projects having at least one matching service.
+func (s *Server) handleListProjects(w http.ResponseWriter, r *http.Request) {
+	latest, filter, ok := s.prepareQuery(w, r)
+	if !ok {
+		return
+	}
+
+	var items []projectSummary
+	for _, project := range latest.Projects {
+		if !filter.matchesLabels(project) {
+			continue
+		}
+
+		services := latest.Services[project.ID]
+		if filter.hasServiceFilters() && !filter.anyService(services) {
+			continue
+		}
+
+		_, skipped := latest.SkippedProjects[project.ID]
+		items = append(items, newProjectSummary(project, services, skipped))
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+
+	writePage(w, r, latest.RunID(), items)
+}
+
+func (s *Server) handleGetProject(w http.ResponseWriter, r *http.Request) {
+	latest := s.Latest()
+	if latest == nil {
+		writeError(w, http.StatusServiceUnavailable, "no audit has completed yet")
+		return
+	}
+
+	project, found := findProject(*latest, r.PathValue("project"))
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("project %q not found", r.PathValue("project")))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newProjectResponse(*latest, project))
+}
+
+func (s *Server) handleListProjectServices(w http.ResponseWriter, r *http.Request) {
+	latest, filter, ok := s.prepareQuery(w, r)
+	if !ok {
+		return
+	}
+
+	project, found := findProject(*latest, r.PathValue("project"))
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("project %q not found", r.PathValue("project")))
+		return
+	}
+
+	var items []projectServiceResponse
+	for _, service := range latest.Services[project.ID] {
+		if !filter.matchesService(service) {
+			continue
+		}
+		items = append(items, projectServiceResponse{
+			serviceResponse: newServiceResponse(service),
+			Status:          usageStatusOf(service),
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	writePage(w, r, latest.RunID(), items)
+}
+
+// handleListServices aggregates services across the projects matching the label filters
+func (s *Server) handleListServices(w http.ResponseWriter, r *http.Request) {
+	latest, filter, ok := s.prepareQuery(w, r)
+	if !ok {
+		return
+	}
+
+	aggregates := make(map[string]*serviceAggregate)
+	for _, project := range latest.Projects {
+		if !filter.matchesLabels(project) {
+			continue
+		}
+
+		for _, service := range latest.Services[project.ID] {
+			if !filter.matchesService(service) {
+				continue
+			}
+
+			agg, exists := aggregates[service.Name]
+			if !exists {
+				agg = &serviceAggregate{Name: service.Name, Title: service.Title}
+				aggregates[service.Name] = agg
+			}
+
+			agg.ProjectCount++
+			switch usageStatusOf(service) {
+			case usageActive:
+				agg.ActiveProjects++
+				agg.TotalRequests += service.Usage.RequestCount
+			case usageUnused:
+				agg.UnusedProjects++
+			case usageNoAccess:
+				agg.NoAccessProjects++
+			case usageError:
+				agg.ErrorProjects++
+			}
+		}
+	}
+
+	items := make([]serviceAggregate, 0, len(aggregates))
+	for _, agg := range aggregates {
+		items = append(items, *agg)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	writePage(w, r, latest.RunID(), items)
+}
+
+func (s *Server) handleListServiceProjects(w http.ResponseWriter, r *http.Request) {
+	latest, filter, ok := s.prepareQuery(w, r)
+	if !ok {
+		return
+	}
+
+	serviceName := r.PathValue("service")
+	enabledAnywhere := false
+
+	var items []serviceProject
+	for _, project := range latest.Projects {
+		for _, service := range latest.Services[project.ID] {
+			if service.Name != serviceName {
+				continue
+			}
+			enabledAnywhere = true
+
+			if !filter.matchesLabels(project) || !filter.matchesService(service) {
+				continue
+			}
+
+			item := serviceProject{
+				ProjectID:   project.ID,
+				ProjectName: project.Name,
+				Labels:      project.Labels,
+				State:       service.State,
+				UsageStatus: usageStatusOf(service),
+			}
+			if service.Usage != nil {
+				item.RequestCount = service.Usage.RequestCount
+				item.Error = service.Usage.Error
+			}
+			items = append(items, item)
+		}
+	}
+
+	if !enabledAnywhere {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("service %q is not enabled in any project", serviceName))
+		return
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ProjectID < items[j].ProjectID
+	})
+
+	writePage(w, r, latest.RunID(), items)
+}
+
+// prepareQuery returns the latest report and the parsed filters, or writes an error response
+func (s *Server) prepareQuery(w http.ResponseWriter, r *http.Request) (*domain.AuditReport, queryFilter, bool) {
+	filter, err := parseQueryFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, filter, false
+	}
+
+	latest := s.Latest()
+	if latest == nil {
+		writeError(w, http.StatusServiceUnavailable, "no audit has completed yet")
+		return nil, filter, false
+	}
+
+	return latest, filter, true
+}
+
+func parseQueryFilter(query url.Values) (queryFilter, error) {
+	filter := queryFilter{
+		labels:   make(map[string]*string),
+		statuses: make(map[string]bool),
+	}
+
+	for _, label := range query["label"] {
+		key, value, hasValue := strings.Cut(label, ":")
+		if key == "" {
+			return filter, fmt.Errorf("invalid label filter %q, expected key or key:value", label)
+		}
+		if hasValue {
+			filter.labels[key] = &value
+		} else {
+			filter.labels[key] = nil
+		}
+	}
+
+	for _, param := range query["usage_status"] {
+		for _, status := range strings.Split(param, ",") {
+			status = strings.ToUpper(strings.TrimSpace(status))
+			switch status {
+			case usageActive, usageUnused, usageNoAccess, usageError, usageUnknown:
+				filter.statuses[status] = true
+			default:
+				return filter, fmt.Errorf("invalid usage_status %q, must be one of: active, unused, no_access, error, unknown", status)
+			}
+		}
+	}
+
+	var err error
+	if filter.minRequests, err = parseInt64Param(query, "min_requests"); err != nil {
+		return filter, err
+	}
+	if filter.maxRequests, err = parseInt64Param(query, "max_requests"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func parseInt64Param(query url.Values, name string) (*int64, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("invalid %s %q, must be a non-negative integer", name, raw)
+	}
+	return &value, nil
+}
+
+func (f queryFilter) matchesLabels(project domain.Project) bool {
+	for key, want := range f.labels {
+		got, exists := project.Labels[key]
+		if !exists || (want != nil && got != *want) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f queryFilter) hasServiceFilters() bool {
+	return len(f.statuses) > 0 || f.minRequests != nil || f.maxRequests != nil
+}
+
+func (f queryFilter) matchesService(service domain.Service) bool {
+	if len(f.statuses) > 0 && !f.statuses[usageStatusOf(service)] {
+		return false
+	}
+
+	if f.minRequests != nil || f.maxRequests != nil {
+		if service.Usage == nil || service.Usage.Status != domain.UsageStatusSuccess {
+			return false
+		}
+		if f.minRequests != nil && service.Usage.RequestCount < *f.minRequests {
+			return false
+		}
+		if f.maxRequests != nil && service.Usage.RequestCount > *f.maxRequests {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f queryFilter) anyService(services []domain.Service) bool {
+	for _, service := range services {
+		if f.matchesService(service) {
+			return true
+		}
+	}
+	return false
+}
+
+func usageStatusOf(service domain.Service) string {
+	if service.Usage == nil {
+		return usageUnknown
+	}
+
+	switch service.Usage.Status {
+	case domain.UsageStatusSuccess:
+		if service.Usage.RequestCount > 0 {
+			return usageActive
+		}
+		return usageUnused
+	case domain.UsageStatusNoAccess:
+		return usageNoAccess
+	case domain.UsageStatusError:
+		return usageError
+	}
+	return usageUnknown
+}
+
+func newProjectSummary(project domain.Project, services []domain.Service, skipped bool) projectSummary {
+	summary := projectSummary{
+		ID:            project.ID,
+		Name:          project.Name,
+		Labels:        project.Labels,
+		Skipped:       skipped,
+		TotalServices: len(services),
+	}
+
+	for _, service := range services {
+		switch usageStatusOf(service) {
+		case usageActive:
+			summary.ActiveServices++
+			summary.TotalRequests += service.Usage.RequestCount
+		case usageUnused:
+			summary.UnusedServices++
+		case usageNoAccess:
+			summary.NoAccessServices++
+		case usageError:
+			summary.ErrorServices++
+		}
+	}
+
+	return summary
+}
+
+func findProject(report domain.AuditReport, projectID string) (domain.Project, bool) {
+	for _, project := range report.Projects {
+		if project.ID == projectID {
+			return project, true
+		}
+	}
+	return domain.Project{}, false
+}
+
+// writePage writes one page of items of the run. Page tokens are opaque to clients and encode
+// the run and the offset, so that a client paging across a newer audit restarts instead of
+// mixing items of two runs.
+func writePage[T any](w http.ResponseWriter, r *http.Request, runID string, items []T) {
+	query := r.URL.Query()
+
+	pageSize := defaultPageSize
+	if raw := query.Get("page_size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size <= 0 || size > maxPageSize {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid page_size %q, must be between 1 and %d", raw, maxPageSize))
+			return
+		}
+		pageSize = size
+	}
+
+	offset := 0
+	if token := query.Get("page_token"); token != "" {
+		tokenRun, tokenOffset, err := decodePageToken(token)
+		if err != nil || tokenOffset < 0 {
+			writeError(w, http.StatusBadRequest, "invalid page_token")
+			return
+		}
+		if tokenRun != runID {
+			writeError(w, http.StatusGone, fmt.Sprintf("page_token belongs to audit run %s, the latest run is %s: restart from the first page", tokenRun, runID))
+			return
+		}
+		if tokenOffset > len(items) {
+			writeError(w, http.StatusBadRequest, "invalid page_token")
+			return
+		}
+		offset = tokenOffset
+	}
+
+	end := offset + pageSize
+	if end > len(items) {
+		end = len(items)
+	}
+
+	page := items[offset:end]
+	if page == nil {
+		page = []T{}
+	}
+
+	resp := listResponse{
+		Items:     page,
+		TotalSize: len(items),
+	}
+	if end < len(items) {
+		resp.NextPageToken = encodePageToken(runID, end)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func encodePageToken(runID string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("run:%s,offset:%d", runID, offset)))
+}
+
+func decodePageToken(token string) (string, int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", 0, err
+	}
+	run, offset, found := strings.Cut(string(decoded), ",offset:")
+	if !found || !strings.HasPrefix(run, "run:") {
+		return "", 0, fmt.Errorf("malformed page token")
+	}
+	n, err := strconv.Atoi(offset)
+	if err != nil {
+		return "", 0, err
+	}
+	return strings.TrimPrefix(run, "run:"), n, nil
+}
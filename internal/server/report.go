@@ -0,0 +1,148 @@
+// internal/server/report.go
+package server
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// reportResponse is the JSON representation of a domain.AuditReport
+type reportResponse struct {
+	Profile          string            `json:"profile,omitempty"`
+	StartTime        time.Time         `json:"startTime"`
+	GeneratedAt      time.Time         `json:"generatedAt"`
+	PeriodDays       int               `json:"periodDays"`
+	Statistics       statistics        `json:"statistics"`
+	Projects         []projectResponse `json:"projects"`
+	SkippedProjects  map[string]string `json:"skippedProjects"`
+	ServiceSummaries []serviceSummary  `json:"serviceSummaries"`
+}
+
+type statistics struct {
+	TotalProjects       int `json:"totalProjects"`
+	ValidProjects       int `json:"validProjects"`
+	ExcludedProjects    int `json:"excludedProjects"`
+	SkippedProjects     int `json:"skippedProjects"`
+	UniqueServices      int `json:"uniqueServices"`
+	ServicesWithNoUsage int `json:"servicesWithNoUsage"`
+}
+
+type projectResponse struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name,omitempty"`
+	ProjectNumber   int64             `json:"projectNumber,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	CreateTime      *time.Time        `json:"createTime,omitempty"`
+	DurationSeconds float64           `json:"durationSeconds"`
+	Skipped         bool              `json:"skipped"`
+	Services        []serviceResponse `json:"services"`
+}
+
+type serviceResponse struct {
+	Name         string     `json:"name"`
+	Title        string     `json:"title,omitempty"`
+	State        string     `json:"state"`
+	UsageStatus  string     `json:"usageStatus,omitempty"`
+	RequestCount int64      `json:"requestCount"`
+	Error        string     `json:"error,omitempty"`
+	LastUpdated  *time.Time `json:"lastUpdated,omitempty"`
+}
+
+type serviceSummary struct {
+	Name          string   `json:"name"`
+	ProjectCount  int      `json:"projectCount"`
+	TotalRequests int64    `json:"totalRequests"`
+	EnabledIn     []string `json:"enabledIn"`
+}
+
+func newReportResponse(report domain.AuditReport) reportResponse {
+	resp := reportResponse{
+		Profile:     report.Profile,
+		StartTime:   report.StartTime,
+		GeneratedAt: report.GeneratedAt,
+		PeriodDays:  int(report.Period / (24 * time.Hour)),
+		Statistics: statistics{
+			TotalProjects:       report.Statistics.TotalProjects,
+			ValidProjects:       report.Statistics.ValidProjects,
+			ExcludedProjects:    report.Statistics.ExcludedProjects,
+			SkippedProjects:     report.Statistics.SkippedProjects,
+			UniqueServices:      report.Statistics.UniqueServices,
+			ServicesWithNoUsage: report.Statistics.ServicesWithNoUsage,
+		},
+		Projects:         make([]projectResponse, 0, len(report.Projects)),
+		SkippedProjects:  make(map[string]string, len(report.SkippedProjects)),
+		ServiceSummaries: make([]serviceSummary, 0, len(report.Statistics.ServiceDetails)),
+	}
+
+	for projectID, err := range report.SkippedProjects {
+		if err != nil {
+			resp.SkippedProjects[projectID] = err.Error()
+		} else {
+			resp.SkippedProjects[projectID] = ""
+		}
+	}
+
+	for _, project := range report.Projects {
+		resp.Projects = append(resp.Projects, newProjectResponse(report, project))
+	}
+	sort.Slice(resp.Projects, func(i, j int) bool {
+		return resp.Projects[i].ID < resp.Projects[j].ID
+	})
+
+	for _, detail := range report.Statistics.ServiceDetails {
+		resp.ServiceSummaries = append(resp.ServiceSummaries, serviceSummary{
+			Name:          detail.Name,
+			ProjectCount:  detail.ProjectCount,
+			TotalRequests: detail.TotalRequests,
+			EnabledIn:     detail.EnabledIn,
+		})
+	}
+
+	return resp
+}
+
+func newProjectResponse(report domain.AuditReport, project domain.Project) projectResponse {
+	_, skipped := report.SkippedProjects[project.ID]
+	resp := projectResponse{
+		ID:              project.ID,
+		Name:            project.Name,
+		ProjectNumber:   project.ProjectNum,
+		Labels:          project.Labels,
+		DurationSeconds: report.ProjectDurations[project.ID].Seconds(),
+		Skipped:         skipped,
+		Services:        make([]serviceResponse, 0, len(report.Services[project.ID])),
+	}
+	if !project.CreateTime.IsZero() {
+		createTime := project.CreateTime
+		resp.CreateTime = &createTime
+	}
+
+	for _, service := range report.Services[project.ID] {
+		resp.Services = append(resp.Services, newServiceResponse(service))
+	}
+	sort.Slice(resp.Services, func(i, j int) bool {
+		return resp.Services[i].Name < resp.Services[j].Name
+	})
+
+	return resp
+}
+
+func newServiceResponse(service domain.Service) serviceResponse {
+	resp := serviceResponse{
+		Name:  service.Name,
+		Title: service.Title,
+		State: service.State,
+	}
+	if service.Usage != nil {
+		resp.UsageStatus = string(service.Usage.Status)
+		resp.RequestCount = service.Usage.RequestCount
+		resp.Error = service.Usage.Error
+		if !service.Usage.LastUpdated.IsZero() {
+			lastUpdated := service.Usage.LastUpdated
+			resp.LastUpdated = &lastUpdated
+		}
+	}
+	return resp
+}
@@ -0,0 +1,349 @@
+// internal/server/server.go
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/report"
+	"github.com/ybonda/gcp-auditor/pkg/logging"
+)
+
+// Server runs audits on a schedule or on demand and serves the latest results over HTTP
+type Server struct {
+	auditor      domain.Auditor
+	interval     time.Duration
+	auditTimeout time.Duration
+	logger       *slog.Logger
+
+	// ctx bounds the lifetime of audits started by the scheduler or the API
+	ctx context.Context
+
+	mu       sync.RWMutex
+	latest   *domain.AuditReport
+	lastRun  *auditRun
+	running  *auditRun
+	nextID   int64
+	stopping bool // Set on shutdown, after which no audit starts
+}
+
+// errShuttingDown is the error of audits triggered while the server shuts down
+var errShuttingDown = errors.New("server is shutting down")
+
+// shutdownTimeout bounds the wait for HTTP requests and the running audit on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// auditRun tracks a single audit execution. Concurrent triggers share the same run.
+type auditRun struct {
+	ID         int64
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Err        error
+	done       chan struct{}
+}
+
+type Option func(*Server)
+
+func WithInterval(interval time.Duration) Option {
+	return func(s *Server) {
+		s.interval = interval
+	}
+}
+
+func WithAuditTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		if timeout > 0 {
+			s.auditTimeout = timeout
+		}
+	}
+}
+
+// NewServer returns a server running audits with auditor. A nil logger discards log records.
+func NewServer(auditor domain.Auditor, logger *slog.Logger, opts ...Option) *Server {
+	s := &Server{
+		auditor:      auditor,
+		interval:     24 * time.Hour,
+		auditTimeout: 30 * time.Minute,
+		logger:       logging.OrDiscard(logger),
+		ctx:          context.Background(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Run starts the scheduler and the HTTP server and blocks until ctx is cancelled and the
+// running audit, cancelled with it, has stopped
+func (s *Server) Run(ctx context.Context, addr string) error {
+	s.ctx = ctx
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Info("Listening", "addr", addr)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	go s.schedule()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("HTTP server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("Shutting down server")
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := httpServer.Shutdown(shutdownCtx)
+
+	// The audit uses the GCP clients and writes reports, so the caller may only release them
+	// once it has stopped
+	s.mu.RLock()
+	running := s.running
+	s.mu.RUnlock()
+	if running != nil {
+		s.logger.Info("Waiting for the running audit to stop", "audit", running.ID)
+		select {
+		case <-running.done:
+		case <-shutdownCtx.Done():
+			s.logger.Warn("Audit did not stop before the shutdown timeout", "audit", running.ID)
+			if err == nil {
+				err = fmt.Errorf("audit %d did not stop within %s", running.ID, shutdownTimeout)
+			}
+		}
+	}
+	return err
+}
+
+// schedule runs an audit immediately and then on every interval tick
+func (s *Server) schedule() {
+	s.Trigger()
+	if s.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Trigger()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// Trigger starts an audit unless one is already running, in which case the running audit is
+// returned. The second return value reports whether the trigger was coalesced. Once the server
+// shuts down, the returned audit has failed without running.
+func (s *Server) Trigger() (*auditRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopping {
+		now := time.Now()
+		run := &auditRun{StartedAt: now, FinishedAt: now, Err: errShuttingDown, done: make(chan struct{})}
+		close(run.done)
+		return run, false
+	}
+
+	if s.running != nil {
+		s.logger.Debug("Audit already running, coalescing trigger", "audit", s.running.ID)
+		return s.running, true
+	}
+
+	s.nextID++
+	run := &auditRun{
+		ID:        s.nextID,
+		StartedAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+	s.running = run
+
+	// The audit outlives the triggering request, so only the server lifetime bounds it
+	go s.execute(s.ctx, run)
+
+	return run, false
+}
+
+func (s *Server) execute(ctx context.Context, run *auditRun) {
+	ctx, cancel := context.WithTimeout(ctx, s.auditTimeout)
+	defer cancel()
+
+	s.logger.Info("Starting audit", "audit", run.ID)
+	auditReport, err := s.auditor.Audit(ctx)
+
+	s.mu.Lock()
+	run.FinishedAt = time.Now()
+	run.Err = err
+	if err != nil {
+		s.logger.Error("Audit failed", "audit", run.ID, "error", err)
+	} else {
+		s.latest = &auditReport
+		s.logger.Info("Audit completed", "audit", run.ID, "duration", run.FinishedAt.Sub(run.StartedAt).Round(time.Second))
+	}
+	s.lastRun = run
+	s.running = nil
+	s.mu.Unlock()
+
+	close(run.done)
+}
+
+// Latest returns the report of the last successful audit, or nil if there is none yet
+func (s *Server) Latest() *domain.AuditReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest
+}
+
+// Handler returns the HTTP handler exposing metrics, health and the JSON API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("GET /api/v1/report", s.handleReport)
+	mux.HandleFunc("POST /api/v1/audits", s.handleTriggerAudit)
+	s.registerAPI(mux)
+	return mux
+}
+
+type runStatus struct {
+	ID         int64      `json:"id"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+func (s *Server) statusOf(run *auditRun) *runStatus {
+	if run == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := &runStatus{
+		ID:        run.ID,
+		State:     "running",
+		StartedAt: run.StartedAt,
+	}
+	if !run.FinishedAt.IsZero() {
+		finished := run.FinishedAt
+		status.FinishedAt = &finished
+		status.State = "succeeded"
+		if run.Err != nil {
+			status.State = "failed"
+			status.Error = run.Err.Error()
+		}
+	}
+	return status
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	running, lastRun := s.running, s.lastRun
+	hasReport := s.latest != nil
+	s.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, struct {
+		Status    string     `json:"status"`
+		HasReport bool       `json:"hasReport"`
+		Running   *runStatus `json:"running,omitempty"`
+		LastRun   *runStatus `json:"lastRun,omitempty"`
+	}{
+		Status:    "ok",
+		HasReport: hasReport,
+		Running:   s.statusOf(running),
+		LastRun:   s.statusOf(lastRun),
+	})
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	latest := s.Latest()
+	if latest == nil {
+		http.Error(w, "no audit has completed yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", report.OpenMetricsContentType)
+	if err := report.WriteOpenMetrics(w, *latest); err != nil {
+		s.logger.Error("Failed to write metrics", "error", err)
+	}
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	latest := s.Latest()
+	if latest == nil {
+		writeError(w, http.StatusServiceUnavailable, "no audit has completed yet")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newReportResponse(*latest))
+}
+
+// handleTriggerAudit starts an audit, or joins the running one. With ?wait=true the
+// response is delayed until the audit finishes.
+func (s *Server) handleTriggerAudit(w http.ResponseWriter, r *http.Request) {
+	run, coalesced := s.Trigger()
+
+	if r.URL.Query().Get("wait") == "true" {
+		select {
+		case <-run.done:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	status := http.StatusAccepted
+	if s.statusOf(run).State != "running" {
+		status = http.StatusOK
+	}
+
+	writeJSON(w, status, struct {
+		Coalesced bool       `json:"coalesced"`
+		Audit     *runStatus `json:"audit"`
+	}{
+		Coalesced: coalesced,
+		Audit:     s.statusOf(run),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{Error: message})
+}
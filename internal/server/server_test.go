@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// slowAuditor runs until its context is cancelled, then takes a while to stop, like an audit
+// finishing its reports
+type slowAuditor struct {
+	started  chan struct{}
+	audits   atomic.Int32
+	finished atomic.Bool
+}
+
+func (a *slowAuditor) Audit(ctx context.Context) (domain.AuditReport, error) {
+	if a.audits.Add(1) == 1 {
+		close(a.started)
+	}
+	<-ctx.Done()
+	time.Sleep(100 * time.Millisecond)
+	a.finished.Store(true)
+	return domain.AuditReport{}, ctx.Err()
+}
+
+func TestRunWaitsForRunningAudit(t *testing.T) {
+	auditor := &slowAuditor{started: make(chan struct{})}
+	srv := NewServer(auditor, nil, WithInterval(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Run(ctx, "127.0.0.1:0")
+	}()
+
+	select {
+	case <-auditor.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the scheduled audit did not start")
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(shutdownTimeout + 5*time.Second):
+		t.Fatal("Run() did not return after shutdown")
+	}
+	if !auditor.finished.Load() {
+		t.Error("Run() returned before the running audit stopped")
+	}
+
+	run, coalesced := srv.Trigger()
+	<-run.done
+	if coalesced || !errors.Is(run.Err, errShuttingDown) {
+		t.Errorf("Trigger() after shutdown = %v, coalesced %t; want %v", run.Err, coalesced, errShuttingDown)
+	}
+	if got := auditor.audits.Load(); got != 1 {
+		t.Errorf("%d audits ran, want 1", got)
+	}
+}
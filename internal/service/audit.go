@@ -2,67 +2,128 @@ package service
 
 import (
 	"context"
-	"sort"
+	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/ybonda/gcp-auditor/internal/config"
 	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/hygiene"
+	"github.com/ybonda/gcp-auditor/internal/policy"
+	"github.com/ybonda/gcp-auditor/internal/tracing"
 	"github.com/ybonda/gcp-auditor/pkg/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 )
 
+var tracer = tracing.Tracer("github.com/ybonda/gcp-auditor/internal/service")
+
 type AuditService struct {
 	projectRepo domain.ProjectRepository
 	serviceRepo domain.ServiceRepository
 	reporters   []domain.Reporter
+	notifiers   []domain.Notifier
 	config      *config.Config
-	logger      *logging.Logger
+	logger      *slog.Logger
+	progress    domain.ProgressObserver // Nil logs progress lines instead
+
+	mu       sync.Mutex
+	baseline *domain.AuditReport // Previous audit used to detect changes for notifications
+}
+
+type Option func(*AuditService)
+
+// WithLogger sets the logger of the audit service; without it log records are discarded
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *AuditService) {
+		s.logger = logging.OrDiscard(logger)
+	}
+}
+
+// WithProgress reports the progress of every audit to observer. The per-project progress
+// records are then only logged at debug level.
+func WithProgress(observer domain.ProgressObserver) Option {
+	return func(s *AuditService) {
+		s.progress = observer
+	}
 }
 
 func NewAuditService(
 	projectRepo domain.ProjectRepository,
 	serviceRepo domain.ServiceRepository,
 	reporters []domain.Reporter,
+	notifiers []domain.Notifier,
 	cfg *config.Config,
+	opts ...Option,
 ) *AuditService {
-	return &AuditService{
+	s := &AuditService{
 		projectRepo: projectRepo,
 		serviceRepo: serviceRepo,
 		reporters:   reporters,
+		notifiers:   notifiers,
 		config:      cfg,
-		logger:      logging.NewLogger(cfg.Verbose),
+		logger:      logging.Discard(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 func (s *AuditService) Audit(ctx context.Context) (domain.AuditReport, error) {
+	ctx, span := tracer.Start(ctx, "Audit", trace.WithAttributes(
+		attribute.Int("audit.period_days", s.config.DaysToAudit),
+		attribute.String("audit.profile", s.config.Profile),
+	))
+	if span.SpanContext().IsValid() {
+		s.logger.Debug("Tracing audit", "trace_id", span.SpanContext().TraceID().String())
+	}
+
+	report, err := s.audit(ctx)
+	span.SetAttributes(
+		attribute.Int("audit.projects.total", report.Statistics.TotalProjects),
+		attribute.Int("audit.projects.valid", report.Statistics.ValidProjects),
+		attribute.Int("audit.projects.skipped", report.Statistics.SkippedProjects),
+		attribute.Int("audit.services.unique", report.Statistics.UniqueServices),
+	)
+	tracing.End(span, err)
+	return report, err
+}
+
+func (s *AuditService) audit(ctx context.Context) (domain.AuditReport, error) {
 	startTime := time.Now()
 	report := domain.AuditReport{
+		Profile:          s.config.Profile,
 		StartTime:        startTime,
 		Period:           s.config.Period,
 		Services:         make(map[string][]domain.Service),
 		SkippedProjects:  make(map[string]error),
 		ProjectDurations: make(map[string]time.Duration),
+		Hygiene:          make(map[string]domain.HygieneScore),
 	}
 
-	s.logger.Info("Starting GCP services audit for the last %d days...", s.config.DaysToAudit)
+	s.logger.Info("Starting GCP services audit", "days", s.config.DaysToAudit)
 
 	// Get all projects
-	s.logger.Info("Discovering GCP projects...")
+	s.logger.Info("Discovering GCP projects")
 	projects, err := s.projectRepo.ListProjects(ctx)
 	if err != nil {
-		s.logger.Error("Failed to list projects: %v", err)
+		s.logger.Error("Failed to list projects", "error", err)
 		return report, err
 	}
-	s.logger.Info("Found %d projects", len(projects))
+	s.logger.Info("Found projects", "projects", len(projects))
 
 	// Initialize statistics
 	report.Statistics.TotalProjects = len(projects)
 
-	// Filter valid projects
+	// Filter valid projects that match the configured filters
 	var validProjects []domain.Project
 	for _, project := range projects {
-		if s.projectRepo.IsValidProject(project) {
+		if s.projectRepo.IsValidProject(project) && s.config.Filters.Matches(project) {
 			validProjects = append(validProjects, project)
 		} else {
 			report.Statistics.ExcludedProjects++
@@ -71,9 +132,35 @@ func (s *AuditService) Audit(ctx context.Context) (domain.AuditReport, error) {
 	report.Projects = validProjects
 	report.Statistics.ValidProjects = len(validProjects)
 
-	s.logger.Info("Processing %d valid projects (excluded %d)...",
-		len(validProjects),
-		report.Statistics.ExcludedProjects)
+	s.logger.Info("Processing valid projects",
+		"projects", len(validProjects),
+		"excluded", report.Statistics.ExcludedProjects)
+	if s.progress != nil {
+		s.progress.AuditStarted(len(validProjects))
+	}
+
+	// Incremental reporters receive every project as it completes
+	var incremental []domain.IncrementalReporter
+	retainServices := true
+	if s.config.Stream {
+		var streaming []domain.IncrementalReporter
+		streaming, retainServices = s.streamingReporters()
+
+		// Reports that were begun but not finished because the audit failed are discarded
+		defer func() {
+			for _, reporter := range incremental {
+				reporter.Abort()
+			}
+		}()
+		for _, reporter := range streaming {
+			if err := reporter.Begin(report); err != nil {
+				s.logger.Error("Failed to start report", "reporter", fmt.Sprintf("%T", reporter), "error", err)
+				return report, err
+			}
+			incremental = append(incremental, reporter)
+		}
+	}
+	summary := newSummary(s.config.GroupBy)
 
 	// Process projects with error group
 	g, ctx := errgroup.WithContext(ctx)
@@ -90,28 +177,53 @@ func (s *AuditService) Audit(ctx context.Context) (domain.AuditReport, error) {
 			defer func() { <-semaphore }()
 
 			projectStart := time.Now()
-			s.logger.Debug("Processing project: %s", project.ID)
+			s.logger.Debug("Processing project", "project", project.ID)
+			if s.progress != nil {
+				s.progress.ProjectStarted(project.ID)
+			}
 
 			services, err := s.serviceRepo.ListServices(ctx, project.ID, s.config.Period)
 			processingDuration := time.Since(projectStart)
 
 			mutex.Lock()
+			defer mutex.Unlock()
 			processed++
 			report.ProjectDurations[project.ID] = processingDuration
 			if err != nil {
-				s.logger.Error("Failed to process project %s: %v", project.ID, err)
+				s.logger.Error("Failed to process project", "project", project.ID, "error", err)
 				report.SkippedProjects[project.ID] = err
 				report.Statistics.SkippedProjects++
+				services = nil
 			} else {
+				level := slog.LevelInfo
+				if s.progress != nil {
+					level = slog.LevelDebug
+				}
+				s.logger.Log(ctx, level, "Project completed",
+					"project", project.ID,
+					"duration", processingDuration.Round(time.Millisecond),
+					"processed", processed,
+					"total", totalProjects,
+					"percent", (processed*100)/totalProjects)
+			}
+			if s.progress != nil {
+				s.progress.ProjectFinished(project.ID, err)
+			}
+
+			result := s.evaluateProject(report.StartTime, project, services, err, processingDuration)
+			report.PolicyViolations = append(report.PolicyViolations, result.PolicyViolations...)
+			report.Hygiene[project.ID] = result.Hygiene
+			summary.add(project, services, err != nil)
+			if err == nil && retainServices {
 				report.Services[project.ID] = services
-				s.logger.Info("Progress: %d/%d projects processed (%d%%) - %s completed in %s",
-					processed,
-					totalProjects,
-					(processed*100)/totalProjects,
-					project.ID,
-					processingDuration.Round(time.Second))
 			}
-			mutex.Unlock()
+
+			for _, reporter := range incremental {
+				if err := reporter.AddProject(result); err != nil {
+					s.logger.Error("Failed to report project", "project", project.ID, "error", err)
+					return fmt.Errorf("failed to report project %s: %w", project.ID, err)
+				}
+			}
 			return nil
 		})
 	}
@@ -121,76 +233,65 @@ func (s *AuditService) Audit(ctx context.Context) (domain.AuditReport, error) {
 	}
 
 	report.GeneratedAt = time.Now()
-	s.calculateStatistics(&report)
+	summary.finish(&report.Statistics)
+	policy.Sort(report.PolicyViolations)
 
 	// Generate reports using all configured reporters
-	s.logger.Info("Generating reports...")
+	s.logger.Info("Generating reports")
 	for _, reporter := range s.reporters {
-		s.logger.Debug("Using reporter: %T", reporter)
+		s.logger.Debug("Using reporter", "reporter", fmt.Sprintf("%T", reporter))
 		if err := reporter.GenerateReport(report); err != nil {
-			s.logger.Error("Failed to generate report: %v", err)
+			s.logger.Error("Failed to generate report", "reporter", fmt.Sprintf("%T", reporter), "error", err)
 			return report, err
 		}
 	}
 
 	duration := report.GeneratedAt.Sub(report.StartTime).Round(time.Second)
-	s.logger.Info("Audit completed in %s", duration)
-	s.logger.Info("Found %d unique services across %d projects",
-		report.Statistics.UniqueServices,
-		len(validProjects))
-	s.logger.Info("Reports generated in: %s", s.config.OutputDir)
+	s.logger.Info("Audit completed",
+		"duration", duration,
+		"services", report.Statistics.UniqueServices,
+		"projects", len(validProjects),
+		"output_dir", s.config.OutputDir)
+
+	s.notify(ctx, report)
 
 	return report, nil
 }
 
-func (s *AuditService) calculateStatistics(report *domain.AuditReport) {
-	uniqueServices := make(map[string]*domain.ServiceDetail)
-	servicesWithNoUsage := 0
-
-	// Calculate service details
-	for projectID, services := range report.Services {
-		for _, service := range services {
-			// Get or create service detail
-			detail, exists := uniqueServices[service.Name]
-			if !exists {
-				detail = &domain.ServiceDetail{
-					Name: service.Name,
-				}
-				uniqueServices[service.Name] = detail
-			}
-
-			detail.ProjectCount++
-			detail.EnabledIn = append(detail.EnabledIn, projectID)
-
-			if service.Usage != nil &&
-				service.Usage.Status == domain.UsageStatusSuccess {
-				detail.TotalRequests += service.Usage.RequestCount
-				if service.Usage.RequestCount == 0 {
-					servicesWithNoUsage++
-				}
-			}
+// streamingReporters returns the reporters that accept projects as they complete, and
+// whether the services of every project must still be kept in the report for the other
+// reporters or the notifiers
+func (s *AuditService) streamingReporters() ([]domain.IncrementalReporter, bool) {
+	var incremental []domain.IncrementalReporter
+	retainServices := len(s.notifiers) > 0
+	for _, reporter := range s.reporters {
+		if r, ok := reporter.(domain.IncrementalReporter); ok {
+			incremental = append(incremental, r)
+			continue
 		}
+		s.logger.Info("Reporter does not stream; services are kept in memory until the audit ends",
+			"reporter", fmt.Sprintf("%T", reporter))
+		retainServices = true
 	}
+	return incremental, retainServices
+}
 
-	// Convert map to sorted slice
-	serviceDetails := make([]*domain.ServiceDetail, 0, len(uniqueServices))
-	for _, detail := range uniqueServices {
-		// Sort the projects list for consistent output
-		sort.Strings(detail.EnabledIn)
-		serviceDetails = append(serviceDetails, detail)
+// evaluateProject checks the policy rules and scores the hygiene of a completed project
+func (s *AuditService) evaluateProject(
+	startTime time.Time,
+	project domain.Project,
+	services []domain.Service,
+	err error,
+	duration time.Duration,
+) domain.ProjectResult {
+	violations := policy.EvaluateProject(project, services, s.config.Policy)
+	return domain.ProjectResult{
+		Project:          project,
+		Services:         services,
+		Err:              err,
+		Duration:         duration,
+		PolicyViolations: violations,
+		Hygiene: hygiene.ScoreProject(project, services, err != nil, violations, startTime,
+			s.config.Policy, s.config.Hygiene),
 	}
-
-	// Sort by projects count (descending), and by total requests for equal project counts
-	sort.Slice(serviceDetails, func(i, j int) bool {
-		if serviceDetails[i].ProjectCount != serviceDetails[j].ProjectCount {
-			return serviceDetails[i].ProjectCount > serviceDetails[j].ProjectCount
-		}
-		// Secondary sort by total requests when project counts are equal
-		return serviceDetails[i].TotalRequests > serviceDetails[j].TotalRequests
-	})
-
-	// Update statistics
-	report.Statistics.UniqueServices = len(uniqueServices)
-	report.Statistics.ServicesWithNoUsage = servicesWithNoUsage
-	report.Statistics.ServiceDetails = serviceDetails
 }
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// SetBaseline sets the previous audit that the next audit is compared against.
+// After every audit the baseline is replaced by the audit's own report.
+func (s *AuditService) SetBaseline(baseline *domain.AuditReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.baseline = baseline
+}
+
+// notify sends the audit summary to all notifiers. Notification failures are logged
+// and never fail the audit.
+func (s *AuditService) notify(ctx context.Context, report domain.AuditReport) {
+	s.mu.Lock()
+	baseline := s.baseline
+	s.baseline = &report
+	s.mu.Unlock()
+
+	if len(s.notifiers) == 0 {
+		return
+	}
+
+	notification := buildNotification(report, baseline, s.config.ReportURL)
+	s.logger.Debug("Sending notification", "severity", notification.Severity, "channels", len(s.notifiers))
+
+	for _, notifier := range s.notifiers {
+		if err := notifier.Notify(ctx, notification); err != nil {
+			s.logger.Error("Failed to send notification", "notifier", fmt.Sprintf("%T", notifier), "error", err)
+		}
+	}
+}
+
+func buildNotification(report domain.AuditReport, baseline *domain.AuditReport, reportURL string) domain.Notification {
+	notification := domain.Notification{
+		Report:      report,
+		Severity:    domain.SeverityInfo,
+		HasBaseline: baseline != nil,
+		ReportURL:   strings.ReplaceAll(reportURL, "{run}", report.RunID()),
+	}
+
+	if baseline != nil {
+		notification.NewlyUnused, notification.NewlyEnabled = diffServices(baseline.Services, report.Services)
+	}
+
+	if len(notification.NewlyUnused) > 0 || len(notification.NewlyEnabled) > 0 {
+		notification.Severity = domain.SeverityWarning
+	}
+	if len(report.SkippedProjects) > 0 {
+		notification.Severity = domain.SeverityCritical
+	}
+
+	return notification
+}
+
+// diffServices compares two audits. Only projects present in both audits are compared,
+// so new projects do not flood the newly-enabled list.
+func diffServices(previous, current map[string][]domain.Service) (newlyUnused, newlyEnabled []domain.ServiceChange) {
+	for projectID, services := range current {
+		previousServices, exists := previous[projectID]
+		if !exists {
+			continue
+		}
+
+		before := make(map[string]domain.Service, len(previousServices))
+		for _, service := range previousServices {
+			before[service.Name] = service
+		}
+
+		for _, service := range services {
+			change := domain.ServiceChange{ProjectID: projectID, Service: service.Name}
+
+			old, wasEnabled := before[service.Name]
+			if !wasEnabled {
+				newlyEnabled = append(newlyEnabled, change)
+				continue
+			}
+
+			if isActive(old) && isUnused(service) {
+				newlyUnused = append(newlyUnused, change)
+			}
+		}
+	}
+
+	sortChanges(newlyUnused)
+	sortChanges(newlyEnabled)
+	return newlyUnused, newlyEnabled
+}
+
+func isActive(service domain.Service) bool {
+	return service.Usage != nil && service.Usage.Status == domain.UsageStatusSuccess && service.Usage.RequestCount > 0
+}
+
+func isUnused(service domain.Service) bool {
+	return service.Usage != nil && service.Usage.Status == domain.UsageStatusSuccess && service.Usage.RequestCount == 0
+}
+
+func sortChanges(changes []domain.ServiceChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].ProjectID != changes[j].ProjectID {
+			return changes[i].ProjectID < changes[j].ProjectID
+		}
+		return changes[i].Service < changes[j].Service
+	})
+}
@@ -0,0 +1,144 @@
+// internal/service/summary.go
+package service
+
+import (
+	"sort"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+)
+
+// summary accumulates the audit statistics project by project, so that the services of a
+// project are no longer needed once it has been added
+type summary struct {
+	groupBy             domain.GroupBy
+	services            map[string]*domain.ServiceDetail
+	servicesWithNoUsage int
+	groups              map[string]*domain.GroupRollup
+	ungrouped           *domain.GroupRollup
+}
+
+func newSummary(groupBy domain.GroupBy) *summary {
+	return &summary{
+		groupBy:  groupBy,
+		services: make(map[string]*domain.ServiceDetail),
+		groups:   make(map[string]*domain.GroupRollup),
+	}
+}
+
+// add counts the services of a completed or skipped project
+func (s *summary) add(project domain.Project, services []domain.Service, skipped bool) {
+	for _, service := range services {
+		// Get or create service detail
+		detail, exists := s.services[service.Name]
+		if !exists {
+			detail = &domain.ServiceDetail{
+				Name: service.Name,
+			}
+			s.services[service.Name] = detail
+		}
+
+		detail.ProjectCount++
+		detail.EnabledIn = append(detail.EnabledIn, project.ID)
+
+		if service.Usage != nil &&
+			service.Usage.Status == domain.UsageStatusSuccess {
+			detail.TotalRequests += service.Usage.RequestCount
+			if service.Usage.RequestCount == 0 {
+				s.servicesWithNoUsage++
+			}
+		}
+	}
+
+	if !s.groupBy.IsZero() {
+		s.addToGroup(project, services, skipped)
+	}
+}
+
+// addToGroup rolls the project up into its group. Projects without a group are collected
+// in a separate rollup.
+func (s *summary) addToGroup(project domain.Project, services []domain.Service, skipped bool) {
+	var rollup *domain.GroupRollup
+	if key, ok := s.groupBy.Key(project); ok {
+		rollup = s.groups[key]
+		if rollup == nil {
+			rollup = &domain.GroupRollup{ID: key, Name: s.groupBy.Name(project)}
+			s.groups[key] = rollup
+		}
+	} else {
+		if s.ungrouped == nil {
+			s.ungrouped = &domain.GroupRollup{}
+		}
+		rollup = s.ungrouped
+	}
+
+	rollup.Projects = append(rollup.Projects, project.ID)
+	if skipped {
+		rollup.SkippedProjects++
+	}
+
+	for _, service := range services {
+		rollup.EnabledServices++
+		if service.Usage == nil || service.Usage.Status != domain.UsageStatusSuccess {
+			continue
+		}
+		rollup.TotalRequests += service.Usage.RequestCount
+		if service.Usage.RequestCount > 0 {
+			rollup.ActiveServices++
+		} else {
+			rollup.InactiveServices++
+		}
+	}
+}
+
+// finish sorts the accumulated details and stores them in the statistics
+func (s *summary) finish(statistics *domain.AuditStatistics) {
+	// Convert map to sorted slice
+	serviceDetails := make([]*domain.ServiceDetail, 0, len(s.services))
+	for _, detail := range s.services {
+		// Sort the projects list for consistent output
+		sort.Strings(detail.EnabledIn)
+		serviceDetails = append(serviceDetails, detail)
+	}
+
+	// Sort by projects count (descending), and by total requests for equal project counts
+	sort.Slice(serviceDetails, func(i, j int) bool {
+		if serviceDetails[i].ProjectCount != serviceDetails[j].ProjectCount {
+			return serviceDetails[i].ProjectCount > serviceDetails[j].ProjectCount
+		}
+		// Secondary sort by total requests when project counts are equal
+		if serviceDetails[i].TotalRequests != serviceDetails[j].TotalRequests {
+			return serviceDetails[i].TotalRequests > serviceDetails[j].TotalRequests
+		}
+		// Finally by name so that reports are reproducible
+		return serviceDetails[i].Name < serviceDetails[j].Name
+	})
+
+	statistics.UniqueServices = len(s.services)
+	statistics.ServicesWithNoUsage = s.servicesWithNoUsage
+	statistics.ServiceDetails = serviceDetails
+
+	if s.groupBy.IsZero() {
+		return
+	}
+
+	// Roll up projects per group
+	rollups := make([]*domain.GroupRollup, 0, len(s.groups))
+	for _, rollup := range s.groups {
+		sort.Strings(rollup.Projects)
+		rollups = append(rollups, rollup)
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		// Folders may share a display name
+		if rollups[i].Name != rollups[j].Name {
+			return rollups[i].Name < rollups[j].Name
+		}
+		return rollups[i].ID < rollups[j].ID
+	})
+	if s.ungrouped != nil {
+		sort.Strings(s.ungrouped.Projects)
+	}
+
+	statistics.GroupBy = s.groupBy
+	statistics.Groups = rollups
+	statistics.Ungrouped = s.ungrouped
+}
@@ -0,0 +1,153 @@
+// internal/tracing/tracing.go
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters of spans
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp" // OTLP over gRPC to a collector
+	ExporterFile = "file" // One JSON object per span, appended to a local file
+)
+
+// ServiceName is the service.name resource attribute of exported spans
+const ServiceName = "gcp-auditor"
+
+// Attributes of the audit spans
+const (
+	AttrProject  = attribute.Key("gcp.project_id")
+	AttrService  = attribute.Key("gcp.service")
+	AttrPage     = attribute.Key("page")
+	AttrRetries  = attribute.Key("retries")
+	AttrStatus   = attribute.Key("status")
+	AttrCount    = attribute.Key("count")
+	AttrRequests = attribute.Key("request_count")
+)
+
+// Config selects where the spans of audits are exported
+type Config struct {
+	Exporter string // ExporterNone, ExporterOTLP or ExporterFile
+	Endpoint string // host:port of the OTLP collector; empty uses OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317
+	Insecure bool   // Connect to the OTLP collector without TLS
+	File     string // Destination of ExporterFile
+}
+
+// Validate checks the exporter and its destination
+func (c Config) Validate() error {
+	switch strings.ToLower(c.Exporter) {
+	case "", ExporterNone, ExporterOTLP:
+		return nil
+	case ExporterFile:
+		if c.File == "" {
+			return fmt.Errorf("tracing.file is required by the file exporter")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown tracing exporter %q (none, otlp, file)", c.Exporter)
+}
+
+// Enabled reports whether spans are exported
+func (c Config) Enabled() bool {
+	exporter := strings.ToLower(c.Exporter)
+	return exporter != "" && exporter != ExporterNone
+}
+
+// Setup installs the global tracer provider that exports spans as configured. The returned
+// function flushes the pending spans and shuts the exporter down; it must be called before
+// the process exits. Without an exporter spans are not recorded.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing resource: %w", err)
+	}
+
+	var processor sdktrace.SpanProcessor
+	closeFile := func() error { return nil }
+	switch strings.ToLower(cfg.Exporter) {
+	case ExporterOTLP:
+		opts := []otlptracegrpc.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		// Spans are dropped rather than slowing the audit down when the collector lags
+		processor = sdktrace.NewBatchSpanProcessor(exporter)
+	case ExporterFile:
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+		// Writing to a local file keeps up, so offline analysis gets every span
+		processor = sdktrace.NewBatchSpanProcessor(exporter, sdktrace.WithBlocking())
+		closeFile = file.Close
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSpanProcessor(processor),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeErr := closeFile(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to flush spans: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// Tracer returns the tracer of an instrumented package. It follows the global tracer
+// provider, so it can be created before Setup is called.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// End records err on the span, if any, and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -2,45 +2,115 @@ package logging
 
 import (
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"time"
+	"strings"
+	"sync"
 )
 
-type LogLevel int
-
+// Formats of log records
 const (
-	LevelError LogLevel = iota
-	LevelInfo
-	LevelDebug
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options configures a logger
+type Options struct {
+	Level  slog.Level
+	Format string // FormatText or FormatJSON
+	File   string // Log records are appended to this file; empty logs to standard error
+}
+
+var (
+	outputMu sync.RWMutex
+	stderr   io.Writer // Replaces standard error for all loggers when set
 )
 
-type Logger struct {
-	verbose bool
+// SetOutput redirects the loggers writing to standard error, e.g. to print log records
+// above a live progress display. Nil restores standard error.
+func SetOutput(w io.Writer) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	stderr = w
 }
 
-func NewLogger(verbose bool) *Logger {
-	return &Logger{
-		verbose: verbose,
+// stderrWriter writes to standard error or to the writer set with SetOutput
+type stderrWriter struct{}
+
+func (stderrWriter) Write(p []byte) (int, error) {
+	outputMu.RLock()
+	w := stderr
+	outputMu.RUnlock()
+
+	if w == nil {
+		w = os.Stderr
 	}
+	return w.Write(p)
 }
 
-func (l *Logger) Info(format string, args ...interface{}) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	fmt.Printf("%s [INFO] %s\n", timestamp, fmt.Sprintf(format, args...))
+// ParseLevel parses a level name: debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (debug, info, warn, error)", name)
+	}
+	return level, nil
 }
 
-func (l *Logger) Debug(format string, args ...interface{}) {
-	if l.verbose {
-		timestamp := time.Now().Format("2006-01-02 15:04:05")
-		fmt.Printf("%s [DEBUG] %s\n", timestamp, fmt.Sprintf(format, args...))
+// ValidateFormat checks that format is FormatText or FormatJSON
+func ValidateFormat(format string) error {
+	switch strings.ToLower(format) {
+	case FormatText, FormatJSON:
+		return nil
 	}
+	return fmt.Errorf("unknown log format %q (text, json)", format)
 }
 
-func (l *Logger) Error(format string, args ...interface{}) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	fmt.Fprintf(os.Stderr, "%s [ERROR] %s\n", timestamp, fmt.Sprintf(format, args...))
+// New returns a logger configured by opts, and a function that closes its log file
+func New(opts Options) (*slog.Logger, func() error, error) {
+	if err := ValidateFormat(opts.Format); err != nil {
+		return nil, nil, err
+	}
+
+	var out io.Writer = stderrWriter{}
+	closeFile := func() error { return nil }
+	if opts.File != "" {
+		file, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		out = file
+		closeFile = file.Close
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level, ReplaceAttr: formatDuration}
+	var handler slog.Handler
+	if strings.ToLower(opts.Format) == FormatJSON {
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(out, handlerOpts)
+	}
+	return slog.New(handler), closeFile, nil
 }
 
-func (l *Logger) Progress(format string, args ...interface{}) {
-	fmt.Printf(format+"\n", args...)
+// formatDuration writes durations as text, e.g. 1m30s, instead of nanoseconds in JSON records
+func formatDuration(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindDuration {
+		a.Value = slog.StringValue(a.Value.Duration().String())
+	}
+	return a
+}
+
+// Discard returns a logger that drops all records, for components created without one
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// OrDiscard returns logger, or a logger that drops all records when it is nil
+func OrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return Discard()
+	}
+	return logger
 }
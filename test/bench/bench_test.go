@@ -0,0 +1,163 @@
+package bench
+
+import (
+	"context"
+	"flag"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/ybonda/gcp-auditor/internal/config"
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/report"
+	"github.com/ybonda/gcp-auditor/internal/repository/gcp"
+	"github.com/ybonda/gcp-auditor/internal/repository/stub"
+	"github.com/ybonda/gcp-auditor/internal/service"
+)
+
+// The organization is either loaded from -scenario or generated from the other flags, e.g.
+//
+//	go test ./test/bench -run '^$' -bench . -benchtime 1x -args -projects 10000 -services 200 -service-pool 500
+var (
+	scenarioPath = flag.String("scenario", "", "scenario file generated by gcp-auditor scenario generate")
+	projects     = flag.Int("projects", 100, "projects of the generated organization")
+	services     = flag.Int("services", 20, "mean services per project of the generated organization")
+	servicePool  = flag.Int("service-pool", 100, "distinct services of the generated organization")
+	concurrency  = flag.Int("concurrency", 3, "projects audited in parallel")
+	workers      = flag.Int("workers", 10, "usage lookups in parallel per project")
+)
+
+var (
+	setupOnce sync.Once
+	scenario  *stub.Scenario
+	setupErr  error
+)
+
+// loadScenario returns the benchmarked organization, built once per test binary
+func loadScenario(b *testing.B) *stub.Scenario {
+	b.Helper()
+
+	setupOnce.Do(func() {
+		if *scenarioPath != "" {
+			scenario, setupErr = stub.LoadScenario(*scenarioPath)
+			return
+		}
+		opts := stub.DefaultSyntheticOptions()
+		opts.Projects = *projects
+		opts.Services = *services
+		opts.ServicePool = *servicePool
+		scenario, setupErr = stub.Generate(opts)
+	})
+	if setupErr != nil {
+		b.Fatalf("failed to set up scenario: %v", setupErr)
+	}
+	return scenario
+}
+
+// newAuditService returns an audit service reading from the stub server through the real GCP
+// repositories
+func newAuditService(b *testing.B, server *stub.Server, reporters []domain.Reporter) *service.AuditService {
+	b.Helper()
+
+	client, err := gcp.NewClient(context.Background(), gcp.WithEmulator(server.URL, server.GRPCAddr))
+	if err != nil {
+		b.Fatalf("NewClient() error = %v", err)
+	}
+	b.Cleanup(func() { client.Close() })
+
+	cfg := config.NewConfig(
+		config.WithOutputDir(b.TempDir()),
+		config.WithConcurrency(*concurrency),
+		config.WithWorkerCount(*workers),
+	)
+	projectRepo := gcp.NewProjectRepository(client.ResourceManager, nil)
+	serviceRepo := gcp.NewServiceRepository(client.ServiceUsage, client.Monitoring, nil, gcp.WithWorkerCount(*workers))
+	return service.NewAuditService(projectRepo, serviceRepo, reporters, nil, cfg)
+}
+
+// reportCounts reports the size of the audited organization
+func reportCounts(b *testing.B, auditReport domain.AuditReport) {
+	enabled := 0
+	for _, projectServices := range auditReport.Services {
+		enabled += len(projectServices)
+	}
+	b.ReportMetric(float64(auditReport.Statistics.ValidProjects), "projects")
+	b.ReportMetric(float64(enabled), "services")
+}
+
+// BenchmarkAudit measures a complete audit without reporters: wall time, allocations, the
+// heap retained by the report, and the API calls made
+func BenchmarkAudit(b *testing.B) {
+	server, err := stub.NewServer(loadScenario(b))
+	if err != nil {
+		b.Fatalf("NewServer() error = %v", err)
+	}
+	defer server.Close()
+
+	audit := newAuditService(b, server, nil)
+
+	var auditReport domain.AuditReport
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		auditReport, err = audit.Audit(context.Background())
+		if err != nil {
+			b.Fatalf("Audit() error = %v", err)
+		}
+	}
+	b.StopTimer()
+
+	// The heap still in use after the audit is mostly the report of the last iteration
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	reportCounts(b, auditReport)
+
+	b.ReportMetric(float64(server.TotalRequests())/float64(b.N), "api-calls/op")
+	b.ReportMetric(float64(server.Requests(stub.MethodListTimeSeries))/float64(b.N), "usage-calls/op")
+	b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/(1<<20), "retained-MB")
+}
+
+// BenchmarkReporters measures every report format on the report of one audit
+func BenchmarkReporters(b *testing.B) {
+	server, err := stub.NewServer(loadScenario(b))
+	if err != nil {
+		b.Fatalf("NewServer() error = %v", err)
+	}
+	auditReport, err := newAuditService(b, server, nil).Audit(context.Background())
+	server.Close()
+	if err != nil {
+		b.Fatalf("Audit() error = %v", err)
+	}
+
+	reporters := []struct {
+		name string
+		new  func(outputDir string) domain.Reporter
+	}{
+		{"markdown", func(dir string) domain.Reporter { return report.NewMarkdownReporter(dir) }},
+		{"json", func(dir string) domain.Reporter { return report.NewJSONReporter(dir) }},
+		{"csv", func(dir string) domain.Reporter { return report.NewCSVReporter(dir) }},
+		{"html", func(dir string) domain.Reporter { return report.NewHTMLReporter(dir) }},
+		{"xlsx", func(dir string) domain.Reporter { return report.NewXLSXReporter(dir) }},
+		{"ndjson", func(dir string) domain.Reporter { return report.NewNDJSONReporter(dir) }},
+		{"openmetrics", func(dir string) domain.Reporter { return report.NewOpenMetricsReporter(dir, "") }},
+	}
+
+	for _, r := range reporters {
+		b.Run(r.name, func(b *testing.B) {
+			reporter := r.new(b.TempDir())
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := reporter.GenerateReport(auditReport); err != nil {
+					b.Fatalf("GenerateReport() error = %v", err)
+				}
+			}
+			b.StopTimer()
+			reportCounts(b, auditReport)
+		})
+	}
+}
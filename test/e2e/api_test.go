@@ -0,0 +1,110 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/server"
+)
+
+// sequenceAuditor returns its reports in turn, one per audit
+type sequenceAuditor struct {
+	mu      sync.Mutex
+	reports []domain.AuditReport
+}
+
+func (a *sequenceAuditor) Audit(ctx context.Context) (domain.AuditReport, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	next := a.reports[0]
+	a.reports = a.reports[1:]
+	return next, nil
+}
+
+func TestAPIPageTokenOfOlderRun(t *testing.T) {
+	first := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	newReport := func(start time.Time, projectIDs ...string) domain.AuditReport {
+		auditReport := domain.AuditReport{StartTime: start, GeneratedAt: start.Add(time.Minute)}
+		for _, id := range projectIDs {
+			auditReport.Projects = append(auditReport.Projects, project(id, nil))
+		}
+		return auditReport
+	}
+	auditor := &sequenceAuditor{reports: []domain.AuditReport{
+		newReport(first, "alpha", "beta", "gamma"),
+		newReport(first.Add(24*time.Hour), "alpha", "gamma"),
+	}}
+
+	srv := server.NewServer(auditor, nil)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	audit(t, srv)
+	page := listProjects(t, ts.URL, url.Values{"page_size": {"1"}}, http.StatusOK)
+	if page.NextPageToken == "" {
+		t.Fatal("first page has no nextPageToken")
+	}
+	token := page.NextPageToken
+
+	page = listProjects(t, ts.URL, url.Values{"page_size": {"1"}, "page_token": {token}}, http.StatusOK)
+	if len(page.Items) != 1 || page.Items[0].ID != "beta" {
+		t.Errorf("second page = %+v, want beta", page.Items)
+	}
+
+	// A newer run must not be paged with the offsets of the older one
+	audit(t, srv)
+	listProjects(t, ts.URL, url.Values{"page_size": {"1"}, "page_token": {token}}, http.StatusGone)
+
+	listProjects(t, ts.URL, url.Values{"page_token": {"not-a-token"}}, http.StatusBadRequest)
+}
+
+type projectPage struct {
+	Items []struct {
+		ID string `json:"id"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// audit runs an audit on the server and waits for its report
+func audit(t *testing.T, srv *server.Server) {
+	t.Helper()
+
+	previous := srv.Latest()
+	srv.Trigger()
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Latest() == previous {
+		if time.Now().After(deadline) {
+			t.Fatal("audit did not complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func listProjects(t *testing.T, baseURL string, query url.Values, wantStatus int) projectPage {
+	t.Helper()
+
+	resp, err := http.Get(baseURL + "/api/v1/projects?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("GET /api/v1/projects?%s status = %d, want %d", query.Encode(), resp.StatusCode, wantStatus)
+	}
+	var page projectPage
+	if wantStatus == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return page
+}
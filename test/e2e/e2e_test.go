@@ -0,0 +1,590 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ybonda/gcp-auditor/internal/config"
+	"github.com/ybonda/gcp-auditor/internal/domain"
+	"github.com/ybonda/gcp-auditor/internal/report"
+	"github.com/ybonda/gcp-auditor/internal/repository/fake"
+	"github.com/ybonda/gcp-auditor/internal/service"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current output")
+
+// created is old enough for every project to get the full age penalty, so hygiene scores do
+// not change as time passes
+var created = time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestAuditGolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		projects []fake.Project
+		timeout  time.Duration // Deadline of the audit, none when zero
+		opts     []config.Option
+	}{
+		{
+			name: "basic",
+			opts: []config.Option{config.WithProfile("prod")},
+			projects: []fake.Project{
+				{
+					Project: project("alpha", map[string]string{"env": "prod"}),
+					Services: []fake.Service{
+						{Name: "bigquery.googleapis.com", Title: "BigQuery API", RequestCount: 42},
+						{Name: "compute.googleapis.com", Title: "Compute Engine API", RequestCount: 1000},
+						{Name: "storage.googleapis.com", Title: "Cloud Storage API"},
+					},
+					Latency: 20 * time.Millisecond, // Slowest project in the timing statistics
+				},
+				{
+					Project: project("beta", nil),
+					Services: []fake.Service{
+						{Name: "pubsub.googleapis.com", Title: "Cloud Pub/Sub API"},
+						{Name: "storage.googleapis.com", Title: "Cloud Storage API", RequestCount: 7},
+					},
+				},
+				{Project: project("gamma", nil)},
+				{
+					Project:  project("sys-12345678901234567890", nil),
+					Services: []fake.Service{{Name: "storage.googleapis.com", RequestCount: 1}},
+				},
+			},
+		},
+		{
+			name: "skipped_projects",
+			projects: []fake.Project{
+				{
+					Project:  project("alpha", nil),
+					Services: []fake.Service{{Name: "bigquery.googleapis.com", Title: "BigQuery API", RequestCount: 3}},
+				},
+				{
+					Project:   project("denied", nil),
+					ListError: errors.New("failed to list services: googleapi: Error 403: Permission denied on resource project denied"),
+				},
+				{
+					Project:   project("deleted", nil),
+					ListError: errors.New("failed to list services: googleapi: Error 404: Project deleted not found"),
+				},
+			},
+		},
+		{
+			name: "no_access_metrics",
+			projects: []fake.Project{
+				{
+					Project: project("alpha", nil),
+					Services: []fake.Service{
+						{Name: "bigquery.googleapis.com", Title: "BigQuery API", RequestCount: 12},
+						{
+							Name:        "compute.googleapis.com",
+							Title:       "Compute Engine API",
+							UsageStatus: domain.UsageStatusNoAccess,
+							UsageError:  "No access to monitoring data",
+						},
+						{
+							Name:        "storage.googleapis.com",
+							Title:       "Cloud Storage API",
+							UsageStatus: domain.UsageStatusError,
+							UsageError:  "rpc error: code = Unavailable desc = backend unavailable",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "folder_groups",
+			opts: []config.Option{config.WithGroupBy(domain.GroupBy{Folder: true})},
+			projects: []fake.Project{
+				{
+					Project:  inFolder(project("alpha", nil), "100", "Analytics"),
+					Services: []fake.Service{{Name: "bigquery.googleapis.com", Title: "BigQuery API", RequestCount: 5}},
+					Latency:  20 * time.Millisecond,
+				},
+				{
+					Project:  inFolder(project("beta", nil), "100", "Analytics"),
+					Services: []fake.Service{{Name: "storage.googleapis.com", Title: "Cloud Storage API"}},
+				},
+				{
+					// Another team's folder of the same name
+					Project:  inFolder(project("gamma", nil), "300", "Analytics"),
+					Services: []fake.Service{{Name: "storage.googleapis.com", Title: "Cloud Storage API", RequestCount: 2}},
+				},
+				// A folder the caller may not read has no name
+				{Project: inFolder(project("delta", nil), "200", "")},
+				{Project: project("epsilon", nil)},
+			},
+		},
+		{
+			name:    "cancelled",
+			timeout: 50 * time.Millisecond,
+			projects: []fake.Project{
+				{
+					Project:  project("alpha", nil),
+					Services: []fake.Service{{Name: "bigquery.googleapis.com", RequestCount: 1}},
+					Latency:  time.Hour,
+				},
+				{
+					Project:  project("beta", nil),
+					Services: []fake.Service{{Name: "storage.googleapis.com", RequestCount: 1}},
+					Latency:  time.Hour,
+				},
+			},
+		},
+	}
+
+	// Streamed reports must match the golden files too, except for the order of the projects
+	// they list in completion order
+	modes := []struct {
+		name string
+		opts []config.Option
+	}{
+		{name: "batch"},
+		{name: "stream", opts: []config.Option{config.WithStream(true)}},
+	}
+
+	for _, mode := range modes {
+		for _, tt := range tests {
+			t.Run(mode.name+"/"+tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				if tt.timeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+					defer cancel()
+				}
+
+				outputDir := t.TempDir()
+				repo := fake.NewRepository(fake.WithProjects(tt.projects...))
+				audit := newAuditService(repo, outputDir, append(tt.opts, mode.opts...)...)
+
+				start := time.Now()
+				auditReport, err := audit.Audit(ctx)
+				if err != nil {
+					t.Fatalf("Audit() error = %v", err)
+				}
+				if tt.timeout > 0 {
+					if elapsed := time.Since(start); elapsed > 10*time.Second {
+						t.Errorf("Audit() took %s after the deadline of %s", elapsed, tt.timeout)
+					}
+					for projectID, err := range auditReport.SkippedProjects {
+						if !errors.Is(err, context.DeadlineExceeded) {
+							t.Errorf("project %s skipped with %v, want %v", projectID, err, context.DeadlineExceeded)
+						}
+					}
+				}
+				if mode.name == "stream" && len(auditReport.Services) > 0 {
+					t.Errorf("streamed audit kept the services of %d projects", len(auditReport.Services))
+				}
+
+				compareGolden(t, runDir(t, outputDir), filepath.Join("testdata", "golden", tt.name), mode.name == "stream")
+			})
+		}
+	}
+}
+
+func TestAuditListProjectsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	outputDir := t.TempDir()
+	repo := fake.NewRepository(
+		fake.WithProjects(fake.Project{Project: project("alpha", nil)}),
+		fake.WithListProjectsLatency(time.Hour),
+	)
+
+	_, err := newAuditService(repo, outputDir).Audit(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Audit() error = %v, want %v", err, context.Canceled)
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Audit() wrote %d reports, want none", len(entries))
+	}
+}
+
+// failingReporter is a streaming reporter that fails to Begin or to add a project
+type failingReporter struct {
+	failBegin bool
+}
+
+func (r *failingReporter) GenerateReport(domain.AuditReport) error { return nil }
+func (r *failingReporter) Abort()                                  {}
+
+func (r *failingReporter) Begin(domain.AuditReport) error {
+	if r.failBegin {
+		return errors.New("begin failed")
+	}
+	return nil
+}
+
+func (r *failingReporter) AddProject(domain.ProjectResult) error {
+	return errors.New("add project failed")
+}
+
+// TestAuditStreamAborted checks that streamed reports of a failed audit are discarded, and
+// that their reporters can stream the next audit
+func TestAuditStreamAborted(t *testing.T) {
+	tests := []struct {
+		name     string
+		reporter *failingReporter
+	}{
+		{name: "later reporter fails to begin", reporter: &failingReporter{failBegin: true}},
+		{name: "reporter fails to add a project", reporter: &failingReporter{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			repo := fake.NewRepository(fake.WithProjects(
+				fake.Project{Project: project("alpha", nil), Services: []fake.Service{{Name: "bigquery.googleapis.com", RequestCount: 1}}},
+				fake.Project{Project: project("beta", nil), Services: []fake.Service{{Name: "storage.googleapis.com"}}},
+			))
+			cfg := config.NewConfig(config.WithOutputDir(outputDir), config.WithDays(30), config.WithStream(true))
+			streaming := []domain.Reporter{
+				report.NewMarkdownReporter(outputDir),
+				report.NewJSONReporter(outputDir),
+				report.NewNDJSONReporter(outputDir),
+			}
+
+			_, err := service.NewAuditService(repo, repo, append(streaming, tt.reporter), nil, cfg).Audit(context.Background())
+			if err == nil {
+				t.Fatal("Audit() error = nil")
+			}
+			entries, err := os.ReadDir(outputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("failed audit left %d entries in %s, want none", len(entries), outputDir)
+			}
+
+			// The next run dates its directory to the second, so it must not start in the same one
+			time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+			if _, err := service.NewAuditService(repo, repo, streaming, nil, cfg).Audit(context.Background()); err != nil {
+				t.Fatalf("next Audit() error = %v", err)
+			}
+			var projects report.ProjectsReport
+			readJSON(t, filepath.Join(runDir(t, outputDir), "projects.json"), &projects)
+			if len(projects.Projects) != 2 {
+				t.Errorf("next audit streamed %d projects, want 2", len(projects.Projects))
+			}
+		})
+	}
+}
+
+// TestAuditRunMetadata checks that every report records the run and the profile it was
+// audited with
+func TestAuditRunMetadata(t *testing.T) {
+	outputDir := t.TempDir()
+	repo := fake.NewRepository(fake.WithProjects(fake.Project{
+		Project:  project("alpha", nil),
+		Services: []fake.Service{{Name: "bigquery.googleapis.com", RequestCount: 1}},
+	}))
+	cfg := config.NewConfig(
+		config.WithOutputDir(outputDir),
+		config.WithDays(30),
+		config.WithProfile("prod"),
+	)
+	reporters := []domain.Reporter{
+		report.NewMarkdownReporter(outputDir),
+		report.NewJSONReporter(outputDir),
+		report.NewCSVReporter(outputDir),
+		report.NewOpenMetricsReporter(outputDir, ""),
+	}
+
+	auditReport, err := service.NewAuditService(repo, repo, reporters, nil, cfg).Audit(context.Background())
+	if err != nil {
+		t.Fatalf("Audit() error = %v", err)
+	}
+
+	runID := auditReport.RunID()
+	dir := runDir(t, outputDir)
+	want := map[string][]string{
+		"projects.json":            {`"runId": "` + runID + `"`, `"profile": "prod"`},
+		"services.json":            {`"runId": "` + runID + `"`, `"profile": "prod"`},
+		"projects.csv":             {"run_id,profile,project_id", runID + ",prod,alpha"},
+		"services.csv":             {"run_id,profile,project_id", runID + ",prod,alpha"},
+		"metrics.prom":             {`gcp_auditor_run_info{profile="prod",run_id="` + runID + `"} 1`, `gcp_auditor_projects{profile="prod"} 1`},
+		"projects_report/alpha.md": {"- Audit run: " + runID, "- Profile: prod"},
+	}
+	for name, contents := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, content := range contents {
+			if !strings.Contains(string(data), content) {
+				t.Errorf("%s does not contain %q:\n%s", name, content, data)
+			}
+		}
+	}
+}
+
+// TestAuditNDJSONGeneratedAt checks that NDJSON records carry the end of the run as
+// run_generated_at, also when they were streamed before it
+func TestAuditNDJSONGeneratedAt(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%t", stream), func(t *testing.T) {
+			outputDir := t.TempDir()
+			repo := fake.NewRepository(fake.WithProjects(
+				fake.Project{Project: project("alpha", nil), Services: []fake.Service{{Name: "bigquery.googleapis.com", RequestCount: 1}}},
+				fake.Project{Project: project("beta", nil), Services: []fake.Service{{Name: "storage.googleapis.com"}}},
+				fake.Project{Project: project("gamma", nil), ListError: errors.New("permission denied")},
+			))
+			cfg := config.NewConfig(config.WithOutputDir(outputDir), config.WithDays(30), config.WithStream(stream))
+			reporters := []domain.Reporter{report.NewNDJSONReporter(outputDir)}
+
+			auditReport, err := service.NewAuditService(repo, repo, reporters, nil, cfg).Audit(context.Background())
+			if err != nil {
+				t.Fatalf("Audit() error = %v", err)
+			}
+
+			dir := runDir(t, outputDir)
+			data, err := os.ReadFile(filepath.Join(dir, "services.ndjson"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := auditReport.GeneratedAt.UTC().Format(time.RFC3339)
+			lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			if len(lines) != 3 {
+				t.Fatalf("services.ndjson has %d records, want 3:\n%s", len(lines), data)
+			}
+			for _, line := range lines {
+				var record report.NDJSONRecord
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("invalid record %s: %v", line, err)
+				}
+				if record.RunGeneratedAt != want {
+					t.Errorf("project %s run_generated_at = %s, want %s", record.ProjectID, record.RunGeneratedAt, want)
+				}
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), ".") {
+					t.Errorf("temporary file %s left in the run directory", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func newAuditService(repo *fake.Repository, outputDir string, opts ...config.Option) *service.AuditService {
+	cfg := config.NewConfig(append([]config.Option{
+		config.WithOutputDir(outputDir),
+		config.WithDays(30),
+		config.WithConcurrency(2),
+	}, opts...)...)
+	reporters := []domain.Reporter{
+		report.NewMarkdownReporter(outputDir),
+		report.NewJSONReporter(outputDir),
+	}
+	return service.NewAuditService(repo, repo, reporters, nil, cfg)
+}
+
+func project(id string, labels map[string]string) domain.Project {
+	return domain.Project{
+		ID:         id,
+		Name:       id,
+		Labels:     labels,
+		CreateTime: created,
+		Parent:     "organizations/123456789",
+	}
+}
+
+// inFolder moves a project into a folder, named as the project repository resolves it
+func inFolder(project domain.Project, folderID, folderName string) domain.Project {
+	project.Parent = "folders/" + folderID
+	project.FolderName = folderName
+	return project
+}
+
+// runDir returns the directory of the single audit run written to outputDir
+func runDir(t *testing.T, outputDir string) string {
+	t.Helper()
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !entries[0].IsDir() {
+		t.Fatalf("want one run directory in %s, got %d entries", outputDir, len(entries))
+	}
+	return filepath.Join(outputDir, entries[0].Name())
+}
+
+func readJSON(t *testing.T, path string, value any) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		t.Fatalf("invalid JSON in %s: %v", path, err)
+	}
+}
+
+// streamedOrder lists the arrays of the streamed JSON reports that are in completion order,
+// by the path of their parent objects in the report; their items are ordered by projectId
+var streamedOrder = map[string][]string{
+	"projects.json": {"projects"},
+	"services.json": {"services", "*", "projects"},
+}
+
+// equalStreamed reports whether the streamed JSON report got has the content of want, in any
+// project order
+func equalStreamed(t *testing.T, rel string, got, want []byte) bool {
+	t.Helper()
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON in %s: %v", rel, err)
+	}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatalf("invalid JSON in the golden file of %s: %v", rel, err)
+	}
+	sortByProjectID(gotValue, streamedOrder[rel])
+	sortByProjectID(wantValue, streamedOrder[rel])
+	return reflect.DeepEqual(gotValue, wantValue)
+}
+
+// sortByProjectID orders by projectId the objects of the array at path in value, where *
+// stands for every item of an array
+func sortByProjectID(value any, path []string) {
+	if len(path) == 0 {
+		items, _ := value.([]any)
+		sort.SliceStable(items, func(i, j int) bool {
+			return projectIDOf(items[i]) < projectIDOf(items[j])
+		})
+		return
+	}
+	if path[0] == "*" {
+		items, _ := value.([]any)
+		for _, item := range items {
+			sortByProjectID(item, path[1:])
+		}
+		return
+	}
+	if object, ok := value.(map[string]any); ok {
+		sortByProjectID(object[path[0]], path[1:])
+	}
+}
+
+func projectIDOf(value any) string {
+	object, _ := value.(map[string]any)
+	id, _ := object["projectId"].(string)
+	return id
+}
+
+// volatile matches the parts of the reports that change between runs, in order of application
+var volatile = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\b\d{8}_\d{6}\b`), "<run>"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`), "<timestamp>"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`), "<timestamp>"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}`), "<date>"},
+	{regexp.MustCompile(`\b(\d+h)?(\d+m)?\d+(\.\d+)?s\b`), "<duration>"},
+}
+
+func normalize(data []byte) []byte {
+	for _, v := range volatile {
+		data = v.pattern.ReplaceAll(data, []byte(v.replacement))
+	}
+	return data
+}
+
+// compareGolden compares every report in dir with its golden file, or rewrites the golden
+// files when -update is set. Streamed reports are only compared, with their projects in any
+// order, since the batch reports of the same audit write the golden files
+func compareGolden(t *testing.T, dir, goldenDir string, streamed bool) {
+	t.Helper()
+
+	rewrite := *update && !streamed
+	if rewrite {
+		if err := os.RemoveAll(goldenDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		got[rel] = true
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		data = normalize(data)
+
+		goldenPath := filepath.Join(goldenDir, rel)
+		if rewrite {
+			if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+				return err
+			}
+			return os.WriteFile(goldenPath, data, 0644)
+		}
+
+		want, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Errorf("unexpected report %s (run with -update to accept it): %v", rel, err)
+			return nil
+		}
+		if _, ok := streamedOrder[rel]; ok && streamed {
+			if !equalStreamed(t, rel, data, want) {
+				t.Errorf("report %s differs from %s in more than project order (run with -update to accept it)\ngot:\n%s\nwant:\n%s", rel, goldenPath, data, want)
+			}
+			return nil
+		}
+		if string(data) != string(want) {
+			t.Errorf("report %s differs from %s (run with -update to accept it)\ngot:\n%s\nwant:\n%s", rel, goldenPath, data, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = filepath.WalkDir(goldenDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(goldenDir, path)
+		if err != nil {
+			return err
+		}
+		if !got[rel] {
+			t.Errorf("report %s was not generated", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}